@@ -2,49 +2,148 @@ package cmd
 
 import (
 	"fmt"
+	"log/slog"
 
+	"github.com/caoenergy/watchman/internal/admin"
+	"github.com/caoenergy/watchman/internal/history"
 	"github.com/caoenergy/watchman/internal/loader"
+	"github.com/caoenergy/watchman/internal/metrics"
 	"github.com/caoenergy/watchman/internal/settings"
 	"github.com/caoenergy/watchman/internal/watcher"
 	"github.com/caoenergy/watchman/platform/linux"
+	"github.com/caoenergy/watchman/platform/linux/nsenter"
 
 	"golang.org/x/sys/unix"
 )
 
 const (
-	// 定义: 内核版本最低版本要求
+	// 定义: 内核版本最低版本要求(FAN_REPORT_FID)；低于 5.9 时没有 FAN_REPORT_DFID_NAME，事件不带文件名，见 watcher.report-mode
 	MinSupportedKernelMajor = 5
-	MinSupportedKernelMinor = 9
+	MinSupportedKernelMinor = 1
 	// 定义: 所需的特权
 	requiredCaps = uint32((1 << unix.CAP_SYS_ADMIN) | (1 << unix.CAP_DAC_READ_SEARCH))
 )
 
 // Initialize 初始化监控引擎;检查内核版本&所需权限和加载设置
 func Initialize() (*watcher.Watchman, error) {
-	major, minor, err := linux.KernelVersion()
+	// 不满足时 watcher.backend 为 auto 则降级为 inotify，fanotify 则启动失败
+	fanotifyErr := checkFanotifyRequirements()
+	if !nsenter.Entered() {
+		ns, err := settings.MountNs()
+		if err != nil {
+			return nil, err
+		}
+		if ns != "" {
+			// 成功时以新进程重新执行，不会返回
+			if err := nsenter.Exec(ns, settings.ConfigPath()); err != nil {
+				return nil, fmt.Errorf("enter mount namespace %s: %w", ns, err)
+			}
+		}
+	}
+	setting, err := settings.Load()
 	if err != nil {
 		return nil, err
 	}
-	if major < MinSupportedKernelMajor || (major == MinSupportedKernelMajor && minor < MinSupportedKernelMinor) {
-		return nil, fmt.Errorf("expected kernel version >=%d.%d, actual:%d.%d", MinSupportedKernelMajor, MinSupportedKernelMinor, major, minor)
+	if fanotifyErr != nil {
+		switch setting.Watchman.Watcher.Backend {
+		case watcher.BackendFanotify:
+			return nil, fanotifyErr
+		case watcher.BackendAuto:
+			slog.Warn("fanotify unavailable, falling back to inotify backend", "err", fanotifyErr)
+			setting.Watchman.Watcher.Backend = watcher.BackendInotify
+		}
 	}
-	caps, err := linux.Capabilities()
+	wm, err := watcher.Initialize(setting)
 	if err != nil {
 		return nil, err
 	}
-	if caps&requiredCaps != requiredCaps {
-		return nil, fmt.Errorf("insufficient capabilities. try: sudo setcap cap_sys_admin,cap_dac_read_search+ep watchman")
+	if pq := setting.Watchman.PluginQueue; pq.QueueSize > 0 {
+		if err := wm.SetPluginQueue(pq.QueueSize, retryPolicy(pq.Retry), pq.DeadLetterFile); err != nil {
+			wm.Stop()
+			return nil, err
+		}
 	}
-	setting, err := settings.Load()
-	if err != nil {
+	if err := loader.Load(setting.Watchman.PluginRoot, wm); err != nil {
+		wm.Stop()
 		return nil, err
 	}
-	wm, err := watcher.Initialize(setting)
-	if err != nil {
+	if err := registerGroups(wm, setting.Watchman.Groups); err != nil {
+		wm.Stop()
 		return nil, err
 	}
-	if err := loader.Load(setting.Watchman.PluginRoot, wm); err != nil {
-		return nil, err
+	if cfg := setting.Watchman.Admin; cfg.Listen != "" {
+		srv, err := admin.New(wm, cfg.Listen, admin.Options{
+			UI: cfg.UI,
+			ReloadPlugins: func() ([]string, []string, error) {
+				return loader.Reload(setting.Watchman.PluginRoot, wm)
+			},
+		})
+		if err != nil {
+			wm.Stop()
+			return nil, err
+		}
+		wm.AddCloser(srv)
+		srv.Start()
+	}
+	if addr := setting.Watchman.Metrics.Listen; addr != "" {
+		srv, err := metrics.New(wm, addr)
+		if err != nil {
+			wm.Stop()
+			return nil, err
+		}
+		wm.AddCloser(srv)
+		srv.Start()
+	}
+	if path := setting.Watchman.History.Socket; path != "" {
+		srv, err := history.New(wm, path)
+		if err != nil {
+			wm.Stop()
+			return nil, err
+		}
+		wm.AddCloser(srv)
+		srv.Start()
 	}
 	return wm, nil
 }
+
+// ReloadPaths 重新读取配置文件并替换监控路径与排除规则，其余配置项的修改需重启生效；
+// 配置无效时返回错误，当前规则保持不变
+func ReloadPaths(wm *watcher.Watchman) error {
+	setting, err := settings.Load()
+	if err != nil {
+		return err
+	}
+	if err := wm.ReloadConfigPaths(setting); err != nil {
+		return err
+	}
+	return wm.ReloadExclude(setting.Watchman.Watcher.Exclude)
+}
+
+// PersistPaths 将当前生效的监控路径写入配置文件的 watchman.watcher.paths，见 Watchman.ConfigPaths
+func PersistPaths(wm *watcher.Watchman) error {
+	paths := wm.ConfigPaths()
+	if err := settings.SavePaths(paths); err != nil {
+		return err
+	}
+	slog.Info("watch paths persisted", "paths", paths)
+	return nil
+}
+
+// checkFanotifyRequirements 检查 fanotify 后端所需的内核版本与特权
+func checkFanotifyRequirements() error {
+	major, minor, err := linux.KernelVersion()
+	if err != nil {
+		return err
+	}
+	if major < MinSupportedKernelMajor || (major == MinSupportedKernelMajor && minor < MinSupportedKernelMinor) {
+		return fmt.Errorf("expected kernel version >=%d.%d, actual:%d.%d", MinSupportedKernelMajor, MinSupportedKernelMinor, major, minor)
+	}
+	caps, err := linux.Capabilities()
+	if err != nil {
+		return err
+	}
+	if caps&requiredCaps != requiredCaps {
+		return fmt.Errorf("insufficient capabilities. try: sudo setcap cap_sys_admin,cap_dac_read_search+ep watchman")
+	}
+	return nil
+}
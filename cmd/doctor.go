@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/caoenergy/watchman/internal/settings"
+	"github.com/caoenergy/watchman/internal/watcher"
+	"github.com/caoenergy/watchman/platform/linux"
+
+	"golang.org/x/sys/unix"
+	"gopkg.in/yaml.v3"
+)
+
+// check 单项预检结果
+type check struct {
+	name string
+	err  error
+	hint string // 失败时的处理建议
+}
+
+// doctor 预检内核版本、权限、fanotify 与 /proc 是否可用，不读取配置也不启动监控
+func doctor(_ []string) error {
+	return runChecks(environmentChecks())
+}
+
+// checkConfig 在 doctor 的预检之外读取并校验配置文件，通过时输出补全默认值、规范化路径后实际生效的配置，不启动监控
+func checkConfig(_ []string) error {
+	setting, loadErr := settings.Load()
+	err := runChecks(append(environmentChecks(), check{name: "config " + settings.ConfigPath(), err: loadErr,
+		hint: "fix the reported setting; run `watchman schema` for the full list of options"}))
+	if loadErr != nil {
+		return err
+	}
+	data, merr := yaml.Marshal(setting)
+	if merr != nil {
+		return merr
+	}
+	fmt.Printf("\n# resolved configuration\n%s", data)
+	return err
+}
+
+func environmentChecks() []check {
+	return []check{
+		{name: "kernel version", err: checkKernel(),
+			hint: fmt.Sprintf("upgrade to Linux >= %d.%d (FAN_REPORT_FID; 5.9 for file names)", MinSupportedKernelMajor, MinSupportedKernelMinor)},
+		{name: "capabilities", err: checkCapabilities(),
+			hint: "sudo setcap cap_sys_admin,cap_dac_read_search+ep watchman"},
+		{name: "fanotify", err: checkFanotify(),
+			hint: "run with CAP_SYS_ADMIN on a kernel built with CONFIG_FANOTIFY; containers need --cap-add SYS_ADMIN; otherwise set watcher.backend: inotify"},
+		{name: "/proc", err: checkProc(),
+			hint: "mount procfs at /proc; paths are resolved via /proc/self/fd"},
+	}
+}
+
+func runChecks(checks []check) error {
+	failed := 0
+	for _, c := range checks {
+		if c.err == nil {
+			fmt.Printf("[PASS] %s\n", c.name)
+			continue
+		}
+		failed++
+		fmt.Printf("[FAIL] %s: %v\n       hint: %s\n", c.name, c.err, c.hint)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(checks))
+	}
+	return nil
+}
+
+func checkKernel() error {
+	major, minor, err := linux.KernelVersion()
+	if err != nil {
+		return err
+	}
+	if major < MinSupportedKernelMajor || (major == MinSupportedKernelMajor && minor < MinSupportedKernelMinor) {
+		return fmt.Errorf("expected kernel version >=%d.%d, actual:%d.%d", MinSupportedKernelMajor, MinSupportedKernelMinor, major, minor)
+	}
+	return nil
+}
+
+func checkCapabilities() error {
+	caps, err := linux.Capabilities()
+	if err != nil {
+		return err
+	}
+	if caps&requiredCaps != requiredCaps {
+		return errors.New("missing CAP_SYS_ADMIN or CAP_DAC_READ_SEARCH")
+	}
+	return nil
+}
+
+// checkFanotify 以与 watcher.Initialize 相同的参数初始化并标记 "/"，随即关闭；
+// 标记时订阅 watcher.events 可配置的全部事件，确认配置中无论选择哪些事件内核都能接受
+func checkFanotify() error {
+	ffd, err := unix.FanotifyInit(unix.FAN_REPORT_DFID_NAME|unix.FAN_CLOEXEC, unix.O_RDONLY)
+	if err != nil {
+		return fmt.Errorf("init: %w", err)
+	}
+	defer func() { _ = unix.Close(ffd) }()
+	if err := unix.FanotifyMark(ffd, unix.FAN_MARK_ADD|unix.FAN_MARK_FILESYSTEM,
+		watcher.EventMask(settings.MarkableEvents)|unix.FAN_ONDIR|unix.FAN_EVENT_ON_CHILD, unix.AT_FDCWD, "/"); err != nil {
+		return fmt.Errorf("mark: %w", err)
+	}
+	return nil
+}
+
+func checkProc() error {
+	f, err := os.Open("/")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := os.Readlink(fmt.Sprintf("/proc/self/fd/%d", f.Fd())); err != nil {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/caoenergy/watchman/internal/codec"
+	"github.com/caoenergy/watchman/internal/listener"
+	"github.com/caoenergy/watchman/internal/retry"
+	"github.com/caoenergy/watchman/internal/settings"
+	"github.com/caoenergy/watchman/internal/watcher"
+)
+
+// sinkFactory 根据监听组配置创建输出
+type sinkFactory func(wm *watcher.Watchman, g settings.Group) (watcher.EventListener, error)
+
+// sinks 内置输出，可在 watchman.groups[].sink 中按名称引用
+var sinks = map[string]sinkFactory{
+	"logging": func(_ *watcher.Watchman, _ settings.Group) (watcher.EventListener, error) {
+		return watcher.Adapt(listener.LoggingHandler), nil
+	},
+	"jsonl": func(_ *watcher.Watchman, _ settings.Group) (watcher.EventListener, error) {
+		return listener.JSONHandler(nil), nil
+	},
+	"journald": func(_ *watcher.Watchman, _ settings.Group) (watcher.EventListener, error) {
+		return listener.JournaldHandler(), nil
+	},
+	"webhook": func(wm *watcher.Watchman, g settings.Group) (watcher.EventListener, error) {
+		if g.URL == "" {
+			return nil, fmt.Errorf("webhook sink requires url")
+		}
+		enc, err := codec.New(g.Format, g.Template)
+		if err != nil {
+			return nil, err
+		}
+		w := listener.NewWebhook(g.URL, enc, retryPolicy(g.Retry))
+		w.SetBlocking(wm.Lockstep())
+		w.SetErrorReporter(wm.ErrorReporterFor("group:" + g.Name))
+		wm.AddCloser(w)
+		wm.AddStatsSource("group:"+g.Name, func() any { return w.Stats() })
+		return w.Handle, nil
+	},
+	"file": func(wm *watcher.Watchman, g settings.Group) (watcher.EventListener, error) {
+		if g.File == "" {
+			return nil, fmt.Errorf("file sink requires file")
+		}
+		enc, err := codec.New(g.Format, g.Template)
+		if err != nil {
+			return nil, err
+		}
+		f, err := listener.NewFileSink(g.File, enc, codec.Binary(g.Format))
+		if err != nil {
+			return nil, err
+		}
+		f.SetErrorReporter(wm.ErrorReporterFor("group:" + g.Name))
+		wm.AddCloser(f)
+		wm.ExcludeSelf(g.File)
+		return f.Handle, nil
+	},
+	"exec": func(wm *watcher.Watchman, g settings.Group) (watcher.EventListener, error) {
+		var stdin codec.Encoder
+		if g.Format != "" {
+			enc, err := codec.New(g.Format, g.Template)
+			if err != nil {
+				return nil, err
+			}
+			stdin = enc
+		}
+		x := g.Exec
+		e, err := listener.NewExec(x.Command, x.Args, listener.ExecOptions{
+			Concurrency: x.Concurrency,
+			Timeout:     time.Duration(x.TimeoutMs) * time.Millisecond,
+			QueueSize:   x.QueueSize,
+			Stdin:       stdin,
+			Binary:      codec.Binary(g.Format),
+		})
+		if err != nil {
+			return nil, err
+		}
+		e.SetBlocking(wm.Lockstep())
+		e.SetErrorReporter(wm.ErrorReporterFor("group:" + g.Name))
+		wm.AddCloser(e)
+		wm.AddStatsSource("group:"+g.Name, func() any { return e.Stats() })
+		if x.BatchSize == 0 && x.BatchIntervalMs == 0 {
+			return e.Handle, nil
+		}
+		b := listener.NewBuffered(e.HandleBatch, x.BatchSize, time.Duration(x.BatchIntervalMs)*time.Millisecond)
+		wm.AddFlusher(b)
+		return b.Handle, nil
+	},
+}
+
+// retryPolicy 将配置转换为重试策略，未配置的字段取默认值
+func retryPolicy(r settings.Retry) retry.Policy {
+	p := retry.DefaultPolicy()
+	if r.MaxAttempts > 0 {
+		p.MaxAttempts = r.MaxAttempts
+	}
+	if r.BaseBackoffMs > 0 {
+		p.BaseBackoff = time.Duration(r.BaseBackoffMs) * time.Millisecond
+	}
+	if r.MaxBackoffMs > 0 {
+		p.MaxBackoff = time.Duration(r.MaxBackoffMs) * time.Millisecond
+	}
+	p.Jitter = r.Jitter
+	if r.BreakerThreshold > 0 {
+		p.BreakerThreshold = r.BreakerThreshold
+	}
+	if r.BreakerCooldownSec > 0 {
+		p.BreakerCooldown = time.Duration(r.BreakerCooldownSec) * time.Second
+	}
+	return p
+}
+
+// registerGroups 为每个监听组组装独立的过滤链并注册为监听器，identify 为 "group:<name>"
+func registerGroups(wm *watcher.Watchman, groups []settings.Group) error {
+	for _, g := range groups {
+		factory, ok := sinks[g.Sink]
+		if !ok {
+			return fmt.Errorf("group %s: unknown sink %s", g.Name, g.Sink)
+		}
+		var h *listener.HashEnricher
+		if g.Hash.Algo != "" {
+			var err error
+			if h, err = listener.NewHashEnricher(g.Hash.Algo, g.Hash.MaxBytes); err != nil {
+				return fmt.Errorf("group %s: %w", g.Name, err)
+			}
+			// 先于 sink 注册：Stop 时先把排队的事件交给 sink，再刷新 sink 自身的攒批
+			wm.AddFlusher(h)
+		}
+		sink, err := factory(wm, g)
+		if err != nil {
+			return fmt.Errorf("group %s: %w", g.Name, err)
+		}
+		if h != nil {
+			// 放在过滤链之后，只为最终投递的事件计算
+			sink = h.Wrap(sink)
+		}
+		err = wm.AddEventListenerUnique("group:"+g.Name, watcher.Chain(sink,
+			watcher.WithPathFilter(g.Include, g.Exclude),
+			watcher.WithEventFilter(g.Events),
+			watcher.WithRateLimit(g.RateLimit, wm.Clock()),
+		))
+		if err != nil {
+			return fmt.Errorf("group %s: %w", g.Name, err)
+		}
+	}
+	return nil
+}
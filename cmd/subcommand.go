@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/caoenergy/watchman/internal/settings"
+	"github.com/caoenergy/watchman/internal/watcher"
+)
+
+// subcommands 不启动监控、直接执行后退出的子命令
+var subcommands = map[string]func(args []string) error{
+	"schema": schema,
+	"doctor": doctor,
+	"check":  checkConfig,
+	"replay": replay,
+}
+
+// Exec 执行子命令，name 为 os.Args[1]
+func Exec(name string, args []string) error {
+	fn, ok := subcommands[name]
+	if !ok {
+		return fmt.Errorf("unknown subcommand: %s", name)
+	}
+	return fn(args)
+}
+
+// schema 输出配置文件的 JSON Schema
+func schema(_ []string) error {
+	data, err := settings.Schema()
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(os.Stdout, string(data))
+	return err
+}
+
+// replay 解析 --record 录制的文件并逐条输出事件，用于复现解析问题
+func replay(args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: watchman replay <file>")
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+	return watcher.Replay(f, func(at time.Time, event watcher.Event) {
+		fmt.Printf("%s %s pid=%d dir=%t handle=%s\n", at.Format(time.RFC3339Nano), watcher.MaskString(event.Mask),
+			event.Pid, event.IsDir, base64.StdEncoding.EncodeToString(event.Handle))
+	})
+}
@@ -0,0 +1,190 @@
+package admin
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/caoenergy/watchman/internal/codec"
+	"github.com/caoenergy/watchman/internal/settings"
+	"github.com/caoenergy/watchman/internal/watcher"
+)
+
+const (
+	streamBuffer    = 256
+	shutdownTimeout = 3 * time.Second
+)
+
+//go:embed ui
+var uiFiles embed.FS
+
+// Options 管理接口的可选功能，依赖配置或加载器的操作由调用方以回调形式提供
+type Options struct {
+	UI bool // 提供实时事件页面(/)和对应的 SSE 事件流(/events)
+	// ReloadPlugins 重新扫描插件目录，返回新增和移除的插件名；为 nil 时不提供 POST /plugins/reload
+	ReloadPlugins func() (added, removed []string, err error)
+}
+
+// Server 本地管理接口
+type Server struct {
+	wm   *watcher.Watchman
+	opts Options
+	srv  *http.Server
+	ln   net.Listener
+}
+
+// New 监听 addr 并创建管理接口，调用 Start 后开始服务
+func New(wm *watcher.Watchman, addr string, opts Options) (*Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("admin listen: %w", err)
+	}
+	s := &Server{wm: wm, opts: opts, ln: ln}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /plugins", s.plugins)
+	mux.HandleFunc("GET /paths", s.paths)
+	mux.HandleFunc("POST /paths", s.addPath)
+	mux.HandleFunc("DELETE /paths", s.removePath)
+	mux.HandleFunc("GET /caches", s.caches)
+	mux.HandleFunc("POST /caches/flush", s.flushCaches)
+	if opts.ReloadPlugins != nil {
+		mux.HandleFunc("POST /plugins/reload", s.reloadPlugins)
+	}
+	if opts.UI {
+		static, _ := fs.Sub(uiFiles, "ui")
+		mux.Handle("GET /", http.FileServerFS(static))
+		mux.HandleFunc("GET /events", s.events)
+	}
+	s.srv = &http.Server{Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	return s, nil
+}
+
+func (s *Server) Start() {
+	go func() {
+		if err := s.srv.Serve(s.ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("admin server stopped", "err", err)
+		}
+	}()
+	slog.Info("admin server listening", "addr", s.ln.Addr().String())
+}
+
+// Close 实现 io.Closer，等待进行中的请求结束，超时后强制关闭(SSE 连接不会自行结束)
+func (s *Server) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := s.srv.Shutdown(ctx); err != nil {
+		return s.srv.Close()
+	}
+	return nil
+}
+
+func (s *Server) plugins(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.wm.Plugins())
+}
+
+// reloadPlugins 只重新加载插件，不重新读取配置
+func (s *Server) reloadPlugins(w http.ResponseWriter, _ *http.Request) {
+	added, removed, err := s.opts.ReloadPlugins()
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{"added": added, "removed": removed})
+}
+
+// watchPath 监控路径的 JSON 形式，字段名与配置文件相同
+type watchPath struct {
+	Path      string `json:"path"`
+	MatchMode string `json:"match-mode,omitempty"`
+}
+
+func (s *Server) paths(w http.ResponseWriter, _ *http.Request) {
+	current := s.wm.ExportWatchPaths()
+	paths := make([]watchPath, len(current))
+	for i, p := range current {
+		paths[i] = watchPath(p)
+	}
+	writeJSON(w, http.StatusOK, paths)
+}
+
+// addPath 追加一条监控路径，请求体为 {"path": ..., "match-mode": ...}；与 SIGHUP 重载一样不写回配置文件
+func (s *Server) addPath(w http.ResponseWriter, r *http.Request) {
+	var p watchPath
+	if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err := s.wm.AddWatchPath(settings.WatchPath(p)); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	s.paths(w, r)
+}
+
+// removePath 移除 ?path= 指定的监控路径
+func (s *Server) removePath(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Query().Get("path")
+	if err := s.wm.RemoveWatchPath(path); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, watcher.ErrPathNotWatched) {
+			status = http.StatusNotFound
+		}
+		writeJSON(w, status, map[string]string{"error": err.Error()})
+		return
+	}
+	s.paths(w, r)
+}
+
+func (s *Server) caches(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.wm.CacheStats())
+}
+
+func (s *Server) flushCaches(w http.ResponseWriter, r *http.Request) {
+	s.wm.FlushCaches()
+	s.caches(w, r)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// events 以 server-sent events 推送实时事件，每条 data 为一条 JSON 记录；客户端消费过慢时丢弃事件
+func (s *Server) events(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	ch, cancel := s.wm.Subscribe(streamBuffer)
+	defer cancel()
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher.Flush()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case info, ok := <-ch:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(codec.NewRecord(info))
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
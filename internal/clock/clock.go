@@ -0,0 +1,116 @@
+package clock
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock 时间来源。去重、防抖、熔断等依赖时间的逻辑通过它取时间，测试中可替换为 Fake 以避免 sleep。
+type Clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+	AfterFunc(d time.Duration, f func()) Timer
+}
+
+// Timer AfterFunc 返回的定时器
+type Timer interface {
+	Stop() bool
+}
+
+// Real 基于 time 包的实现
+type Real struct{}
+
+func (Real) Now() time.Time {
+	return time.Now()
+}
+
+func (Real) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (Real) AfterFunc(d time.Duration, f func()) Timer {
+	return time.AfterFunc(d, f)
+}
+
+// Fake 手动推进的时钟，只有调用 Advance 时时间才会前进并触发到期的定时器
+type Fake struct {
+	mu      sync.Mutex
+	now     time.Time
+	waiters []*fakeTimer
+}
+
+func NewFake(now time.Time) *Fake {
+	return &Fake{now: now}
+}
+
+func (c *Fake) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *Fake) After(d time.Duration) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	c.add(d, func(t time.Time) { ch <- t })
+	return ch
+}
+
+func (c *Fake) AfterFunc(d time.Duration, f func()) Timer {
+	return c.add(d, func(time.Time) { f() })
+}
+
+// Advance 推进时间，并按到期顺序同步触发到期的定时器
+func (c *Fake) Advance(d time.Duration) {
+	c.mu.Lock()
+	c.now = c.now.Add(d)
+	now := c.now
+	var due, pending []*fakeTimer
+	for _, w := range c.waiters {
+		if !w.deadline.After(now) {
+			due = append(due, w)
+		} else {
+			pending = append(pending, w)
+		}
+	}
+	c.waiters = pending
+	c.mu.Unlock()
+	for _, w := range due {
+		w.fire(now)
+	}
+}
+
+func (c *Fake) add(d time.Duration, fn func(time.Time)) *fakeTimer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &fakeTimer{clock: c, deadline: c.now.Add(d), fn: fn}
+	if d <= 0 {
+		go t.fire(c.now)
+		return t
+	}
+	c.waiters = append(c.waiters, t)
+	return t
+}
+
+type fakeTimer struct {
+	clock    *Fake
+	deadline time.Time
+	fn       func(time.Time)
+	once     sync.Once
+}
+
+func (t *fakeTimer) fire(now time.Time) {
+	t.once.Do(func() { t.fn(now) })
+}
+
+func (t *fakeTimer) Stop() bool {
+	c := t.clock
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i, w := range c.waiters {
+		if w == t {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
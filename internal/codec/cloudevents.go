@@ -0,0 +1,46 @@
+package codec
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/caoenergy/watchman/internal/watcher"
+)
+
+const cloudEventsSource = "watchman"
+
+// cloudEvent CloudEvents 1.0 结构化模式的 JSON 表示，data 为 Record
+type cloudEvent struct {
+	SpecVersion     string    `json:"specversion"`
+	ID              string    `json:"id"`
+	Source          string    `json:"source"`
+	Type            string    `json:"type"`
+	Subject         string    `json:"subject"`
+	Time            time.Time `json:"time"`
+	DataContentType string    `json:"datacontenttype"`
+	Data            Record    `json:"data"`
+}
+
+type cloudEventsEncoder struct{}
+
+func (cloudEventsEncoder) Encode(info *watcher.EventInfo) ([]byte, error) {
+	var id [16]byte
+	_, _ = rand.Read(id[:])
+	return json.Marshal(cloudEvent{
+		SpecVersion:     "1.0",
+		ID:              hex.EncodeToString(id[:]),
+		Source:          cloudEventsSource,
+		Type:            "watchman." + strings.ToLower(strings.ReplaceAll(info.Type, "|", ".")),
+		Subject:         info.Path,
+		Time:            info.Time,
+		DataContentType: "application/json",
+		Data:            NewRecord(info),
+	})
+}
+
+func (cloudEventsEncoder) ContentType() string {
+	return "application/cloudevents+json"
+}
@@ -0,0 +1,100 @@
+package codec
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/caoenergy/watchman/internal/watcher"
+)
+
+const (
+	FormatJSON        = "json"
+	FormatCloudEvents = "cloudevents"
+	FormatProtobuf    = "protobuf"
+	FormatTemplate    = "template"
+)
+
+// Encoder 将事件序列化为线上格式
+type Encoder interface {
+	Encode(info *watcher.EventInfo) ([]byte, error)
+	ContentType() string
+}
+
+// New 按格式名创建编码器，空字符串为 JSON；tmpl 仅 template 格式使用
+func New(format, tmpl string) (Encoder, error) {
+	switch format {
+	case "", FormatJSON:
+		return jsonEncoder{}, nil
+	case FormatCloudEvents:
+		return cloudEventsEncoder{}, nil
+	case FormatProtobuf:
+		return protobufEncoder{}, nil
+	case FormatTemplate:
+		return newTemplateEncoder(tmpl)
+	}
+	return nil, fmt.Errorf("unknown format: %s", format)
+}
+
+// Binary 判断格式是否为二进制；流式输出中二进制消息以长度前缀分隔，文本消息以换行分隔
+func Binary(format string) bool {
+	return format == FormatProtobuf
+}
+
+// WriteDelimited 以 varint 长度前缀写入一条消息，用于在流式输出中分隔 protobuf 消息
+func WriteDelimited(w io.Writer, msg []byte) error {
+	var prefix [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(prefix[:], uint64(len(msg)))
+	if _, err := w.Write(prefix[:n]); err != nil {
+		return err
+	}
+	_, err := w.Write(msg)
+	return err
+}
+
+// Record 事件的 JSON 表示
+type Record struct {
+	Type      string         `json:"type"`
+	Dir       string         `json:"dir"`
+	Name      string         `json:"name"`
+	Path      string         `json:"path"`
+	OldPath   string         `json:"old_path,omitempty"`
+	Root      string         `json:"root,omitempty"`
+	RelPath   string         `json:"rel_path,omitempty"`
+	IsDir     bool           `json:"is_dir"`
+	Pid       int32          `json:"pid,omitempty"`
+	Uid       *uint32        `json:"uid,omitempty"`
+	Time      time.Time      `json:"time"`
+	Monotonic int64          `json:"monotonic_ns"`
+	Attrs     map[string]any `json:"attrs,omitempty"`
+}
+
+func NewRecord(info *watcher.EventInfo) Record {
+	return Record{
+		Type:      info.Type,
+		Dir:       info.Dir,
+		Name:      info.Name,
+		Path:      info.Path,
+		OldPath:   info.OldPath,
+		Root:      info.Root,
+		RelPath:   info.RelPath,
+		IsDir:     info.IsDir,
+		Pid:       info.Pid,
+		Uid:       info.Uid,
+		Time:      info.Time,
+		Monotonic: info.Monotonic,
+		Attrs:     info.Attrs,
+	}
+}
+
+type jsonEncoder struct{}
+
+func (jsonEncoder) Encode(info *watcher.EventInfo) ([]byte, error) {
+	return json.Marshal(NewRecord(info))
+}
+
+func (jsonEncoder) ContentType() string {
+	return "application/json"
+}
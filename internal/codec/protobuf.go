@@ -0,0 +1,93 @@
+package codec
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/caoenergy/watchman/internal/watcher"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// event.proto 中 Event 的字段号
+const (
+	fieldType         = 1
+	fieldDir          = 2
+	fieldName         = 3
+	fieldPath         = 4
+	fieldIsDir        = 5
+	fieldMask         = 6
+	fieldPid          = 7
+	fieldTimeUnixNano = 8
+	fieldAttrs        = 9
+	fieldRoot         = 10
+	fieldRelPath      = 11
+	fieldMonotonicNs  = 12
+	fieldUid          = 13
+	fieldOldPath      = 14
+)
+
+// protobufEncoder 按 event.proto 编码，输出与 protoc 生成代码兼容；零值字段按 proto3 语义省略
+type protobufEncoder struct{}
+
+func (protobufEncoder) Encode(info *watcher.EventInfo) ([]byte, error) {
+	b := make([]byte, 0, 64+len(info.Path)*2)
+	b = appendString(b, fieldType, info.Type)
+	b = appendString(b, fieldDir, info.Dir)
+	b = appendString(b, fieldName, info.Name)
+	b = appendString(b, fieldPath, info.Path)
+	if info.IsDir {
+		b = protowire.AppendTag(b, fieldIsDir, protowire.VarintType)
+		b = protowire.AppendVarint(b, 1)
+	}
+	if info.Mask != 0 {
+		b = protowire.AppendTag(b, fieldMask, protowire.VarintType)
+		b = protowire.AppendVarint(b, info.Mask)
+	}
+	if info.Pid != 0 {
+		b = protowire.AppendTag(b, fieldPid, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(info.Pid))
+	}
+	if !info.Time.IsZero() {
+		b = protowire.AppendTag(b, fieldTimeUnixNano, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(info.Time.UnixNano()))
+	}
+	// map 字段按键排序编码，保证输出稳定
+	keys := make([]string, 0, len(info.Attrs))
+	for k := range info.Attrs {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		var entry []byte
+		entry = appendString(entry, 1, k)
+		entry = appendString(entry, 2, fmt.Sprint(info.Attrs[k]))
+		b = protowire.AppendTag(b, fieldAttrs, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	b = appendString(b, fieldRoot, info.Root)
+	b = appendString(b, fieldRelPath, info.RelPath)
+	if info.Monotonic != 0 {
+		b = protowire.AppendTag(b, fieldMonotonicNs, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(info.Monotonic))
+	}
+	if info.Uid != nil {
+		// optional 字段，0(root) 也需要编码
+		b = protowire.AppendTag(b, fieldUid, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(*info.Uid))
+	}
+	b = appendString(b, fieldOldPath, info.OldPath)
+	return b, nil
+}
+
+func (protobufEncoder) ContentType() string {
+	return "application/x-protobuf"
+}
+
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
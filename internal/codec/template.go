@@ -0,0 +1,33 @@
+package codec
+
+import (
+	"bytes"
+	"text/template"
+
+	"github.com/caoenergy/watchman/internal/watcher"
+)
+
+// templateEncoder 用 text/template 渲染 Record，如 "{{.Type}} {{.Path}}"
+type templateEncoder struct {
+	tmpl *template.Template
+}
+
+func newTemplateEncoder(text string) (*templateEncoder, error) {
+	tmpl, err := template.New("event").Option("missingkey=zero").Parse(text)
+	if err != nil {
+		return nil, err
+	}
+	return &templateEncoder{tmpl: tmpl}, nil
+}
+
+func (e *templateEncoder) Encode(info *watcher.EventInfo) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := e.tmpl.Execute(&buf, NewRecord(info)); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (e *templateEncoder) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
@@ -0,0 +1,94 @@
+package history
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caoenergy/watchman/internal/watcher"
+)
+
+// 客户端连接后须在该时间内发送请求行
+const requestTimeout = 3 * time.Second
+
+// Server 在 Unix 域套接字上提供最近事件查询：客户端发送一行事件数(空行表示全部)，
+// 服务端返回 JSON 数组后关闭连接，如 echo 100 | nc -U /run/watchman/history.sock
+type Server struct {
+	wm   *watcher.Watchman
+	path string
+	ln   net.Listener
+	wg   sync.WaitGroup
+}
+
+// New 监听 path 并创建查询接口，调用 Start 后开始服务；path 上残留的套接字文件(如进程被 kill -9)会被替换
+func New(wm *watcher.Watchman, path string) (*Server, error) {
+	if fi, err := os.Lstat(path); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("history socket %s exists and is not a socket", path)
+		}
+		_ = os.Remove(path)
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("history listen: %w", err)
+	}
+	// 事件路径可能含敏感信息，仅属主可连接
+	if err := os.Chmod(path, 0600); err != nil {
+		_ = ln.Close()
+		return nil, fmt.Errorf("history socket: %w", err)
+	}
+	return &Server{wm: wm, path: path, ln: ln}, nil
+}
+
+func (s *Server) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := s.ln.Accept()
+			if err != nil {
+				if !errors.Is(err, net.ErrClosed) {
+					slog.Error("history server stopped", "err", err)
+				}
+				return
+			}
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.serve(conn)
+			}()
+		}
+	}()
+	slog.Info("history server listening", "socket", s.path)
+}
+
+// Close 实现 io.Closer，关闭监听(同时删除套接字文件)并等待进行中的请求结束
+func (s *Server) Close() error {
+	err := s.ln.Close()
+	s.wg.Wait()
+	return err
+}
+
+func (s *Server) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	_ = conn.SetDeadline(time.Now().Add(requestTimeout))
+	// 未换行就关闭写端(如 printf 100 | nc -U)时 line 为已读取的部分，都未发送时按全部处理
+	line, _ := bufio.NewReader(conn).ReadString('\n')
+	n := 0
+	if line = strings.TrimSpace(line); line != "" {
+		var err error
+		if n, err = strconv.Atoi(line); err != nil || n < 0 {
+			_ = json.NewEncoder(conn).Encode(map[string]string{"error": "request must be an event count"})
+			return
+		}
+	}
+	_ = json.NewEncoder(conn).Encode(s.wm.RecentEvents(n))
+}
@@ -0,0 +1,83 @@
+package listener
+
+import (
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/caoenergy/watchman/internal/clock"
+	"github.com/caoenergy/watchman/internal/watcher"
+)
+
+// BatchListener 见 watcher.BatchListener
+type BatchListener = watcher.BatchListener
+
+// Buffered 攒批装饰器：事件先缓存，数量达到 size 或第一条事件缓存满 interval 时整批交给 inner。
+// 实现 watcher.Flusher，Stop 时会先 Flush 剩余事件再关闭其他资源。
+type Buffered struct {
+	inner    BatchListener
+	size     int
+	interval time.Duration
+	clock    clock.Clock
+
+	mu    sync.Mutex
+	batch []*watcher.EventInfo
+	timer clock.Timer
+}
+
+// NewBuffered size <= 0 表示只按时间刷新，interval <= 0 表示只按数量刷新
+func NewBuffered(inner BatchListener, size int, interval time.Duration) *Buffered {
+	return &Buffered{inner: inner, size: size, interval: interval, clock: clock.Real{}}
+}
+
+// SetClock 替换时间来源，用于测试按时间刷新
+func (b *Buffered) SetClock(c clock.Clock) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.clock = c
+}
+
+// Handle 实现 watcher.EventListener。缓存的是事件副本，inner 可在任意协程中读写收到的事件
+func (b *Buffered) Handle(info *watcher.EventInfo) {
+	c := *info
+	c.Attrs = maps.Clone(info.Attrs)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.batch = append(b.batch, &c)
+	if b.size > 0 && len(b.batch) >= b.size {
+		b.flushLocked()
+		return
+	}
+	if len(b.batch) == 1 && b.interval > 0 {
+		b.timer = b.clock.AfterFunc(b.interval, b.onTimer)
+	}
+}
+
+func (b *Buffered) onTimer() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.timer = nil
+	b.flushLocked()
+}
+
+// Flush 立即交付已缓存的事件
+func (b *Buffered) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.flushLocked()
+	return nil
+}
+
+// flushLocked 持锁调用 inner，保证批次按顺序交付
+func (b *Buffered) flushLocked() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.batch) == 0 {
+		return
+	}
+	batch := b.batch
+	b.batch = nil
+	b.inner(batch)
+}
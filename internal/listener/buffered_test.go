@@ -0,0 +1,106 @@
+package listener
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/caoenergy/watchman/internal/clock"
+	"github.com/caoenergy/watchman/internal/watcher"
+)
+
+// newTestBuffered 返回使用假时钟的 Buffered，以及已交付批次的大小
+func newTestBuffered(size int, interval time.Duration) (*Buffered, *clock.Fake, *[]int) {
+	var batches []int
+	b := NewBuffered(func(batch []*watcher.EventInfo) { batches = append(batches, len(batch)) }, size, interval)
+	clk := clock.NewFake(time.Now())
+	b.SetClock(clk)
+	return b, clk, &batches
+}
+
+func handleN(b *Buffered, n int) {
+	for range n {
+		b.Handle(&watcher.EventInfo{Type: "CREATE", Path: "/a"})
+	}
+}
+
+func expectBatches(t *testing.T, got *[]int, want ...int) {
+	t.Helper()
+	if !slices.Equal(*got, want) {
+		t.Fatalf("batches %v, want %v", *got, want)
+	}
+}
+
+func TestBufferedSizeFlush(t *testing.T) {
+	b, _, got := newTestBuffered(3, 0)
+	handleN(b, 2)
+	expectBatches(t, got)
+	handleN(b, 5)
+	expectBatches(t, got, 3, 3)
+	_ = b.Flush()
+	expectBatches(t, got, 3, 3, 1)
+	_ = b.Flush()
+	expectBatches(t, got, 3, 3, 1)
+}
+
+func TestBufferedIntervalFlush(t *testing.T) {
+	b, clk, got := newTestBuffered(0, time.Second)
+	handleN(b, 1)
+	clk.Advance(500 * time.Millisecond)
+	// 间隔从批次的第一条事件起算，后续事件不重置
+	handleN(b, 1)
+	clk.Advance(499 * time.Millisecond)
+	expectBatches(t, got)
+	clk.Advance(time.Millisecond)
+	expectBatches(t, got, 2)
+	clk.Advance(time.Hour)
+	expectBatches(t, got, 2)
+	handleN(b, 1)
+	clk.Advance(time.Second)
+	expectBatches(t, got, 2, 1)
+}
+
+// 按数量刷新后停止该批次的定时器，下一批重新计时
+func TestBufferedSizeThenInterval(t *testing.T) {
+	b, clk, got := newTestBuffered(2, time.Second)
+	handleN(b, 2)
+	expectBatches(t, got, 2)
+	handleN(b, 1)
+	clk.Advance(999 * time.Millisecond)
+	expectBatches(t, got, 2)
+	clk.Advance(time.Millisecond)
+	expectBatches(t, got, 2, 1)
+	handleN(b, 1)
+	_ = b.Flush()
+	clk.Advance(time.Second)
+	expectBatches(t, got, 2, 1, 1)
+}
+
+// 缓存的是事件副本：按时间刷新在定时器协程中交付，期间后续监听器仍可写 Attrs；配合 -race 运行
+func TestBufferedCopiesEvent(t *testing.T) {
+	const n = 50
+	delivered := make(chan []*watcher.EventInfo, n)
+	b := NewBuffered(func(batch []*watcher.EventInfo) { delivered <- batch }, 0, time.Millisecond)
+	for range n {
+		info := &watcher.EventInfo{Type: "CREATE", Path: "/a"}
+		info.SetAttr("k", "queued")
+		b.Handle(info)
+		for i := range 10 {
+			info.SetAttr("k", i)
+		}
+	}
+	_ = b.Flush()
+	close(delivered)
+	count := 0
+	for batch := range delivered {
+		for _, info := range batch {
+			if v, _ := info.Attr("k"); v != "queued" {
+				t.Fatalf("batched attrs %v, want the attrs at Handle time", v)
+			}
+			count++
+		}
+	}
+	if count != n {
+		t.Errorf("delivered %d events, want %d", count, n)
+	}
+}
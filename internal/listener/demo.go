@@ -7,9 +7,18 @@ import (
 )
 
 func LoggingHandler(eventType string, eventDirectory string, eventFile string, _ bool) {
-	filename := eventFile
+	if eventFile == "" {
+		// 会话标记等不关联文件的事件
+		fmt.Println(eventType)
+		return
+	}
+	fmt.Println(filepath.Join(eventDirectory, trimDeleted(eventType, eventFile)))
+}
+
+// trimDeleted 去掉删除事件中 readlink 附加的 " (deleted)" 后缀
+func trimDeleted(eventType, filename string) string {
 	if (eventType == "DELETE" || eventType == "DELETE_SELF") && strings.Contains(filename, " (deleted)") {
-		filename = filename[:strings.LastIndex(eventFile, " (deleted)")]
+		return filename[:strings.LastIndex(filename, " (deleted)")]
 	}
-	fmt.Println(filepath.Join(eventDirectory, filename))
+	return filename
 }
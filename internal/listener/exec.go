@@ -0,0 +1,231 @@
+package listener
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/caoenergy/watchman/internal/codec"
+	"github.com/caoenergy/watchman/internal/watcher"
+)
+
+const (
+	defaultExecConcurrency = 4
+	defaultExecTimeout     = 30 * time.Second
+	defaultExecQueueSize   = 1024
+	// 超时杀死进程组后等待输出管道关闭的时间，防止孙进程持有管道导致 Wait 不返回
+	execWaitDelay = time.Second
+)
+
+// ExecOptions 命令执行参数，零值字段取默认值
+type ExecOptions struct {
+	Concurrency int           // 同时运行的命令数，默认 4
+	Timeout     time.Duration // 单次执行超时，超时后杀死整个进程组，默认 30s
+	QueueSize   int           // 等待执行的队列长度，满时丢弃并计数，默认 1024
+	// Stdin 批量模式下将批次内的事件逐条写入 stdin 的编码器(文本格式换行分隔，二进制格式长度前缀分隔)，默认 JSON
+	Stdin  codec.Encoder
+	Binary bool
+}
+
+// ExecStats exec sink 的运行状态
+type ExecStats struct {
+	Started  uint64 `json:"started"`
+	Failed   uint64 `json:"failed"`    // 非零退出或无法启动，含超时
+	TimedOut uint64 `json:"timed_out"` // 超时被杀死
+	Dropped  uint64 `json:"dropped"`   // 队列满丢弃的事件数
+}
+
+// Exec 为每个事件(或每批事件)执行一次命令，参数为 text/template 模板，字段同 JSON 输出，如 "{{.Path}}"。
+// 命令在后台 worker 中执行，默认不阻塞事件处理：队列满时丢弃，超时的命令连同其子进程一起被杀死。
+type Exec struct {
+	path    string
+	args    []codec.Encoder
+	opts    ExecOptions
+	queue   chan execJob
+	cancel  context.CancelFunc
+	done    <-chan struct{}
+	wg      sync.WaitGroup
+	block   bool
+	onError watcher.ErrorReporter
+
+	started, failed, timedOut, dropped atomic.Uint64
+}
+
+type execJob struct {
+	info  *watcher.EventInfo // 参数渲染与失败归类使用的事件，批量模式下为批次第一个
+	stdin []byte
+	count int
+}
+
+// NewExec 解析参数模板并启动 worker；path 按 PATH 查找
+func NewExec(path string, args []string, opts ExecOptions) (*Exec, error) {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultExecConcurrency
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultExecTimeout
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = defaultExecQueueSize
+	}
+	if opts.Stdin == nil {
+		opts.Stdin, _ = codec.New(codec.FormatJSON, "")
+	}
+	e := &Exec{path: path, opts: opts, queue: make(chan execJob, opts.QueueSize)}
+	for i, a := range args {
+		tmpl, err := codec.New(codec.FormatTemplate, a)
+		if err != nil {
+			return nil, fmt.Errorf("exec args[%d]: %w", i, err)
+		}
+		e.args = append(e.args, tmpl)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancel, e.done = cancel, ctx.Done()
+	for range opts.Concurrency {
+		e.wg.Add(1)
+		go e.run(ctx)
+	}
+	return e, nil
+}
+
+// SetBlocking 设置队列满时阻塞等待而不是丢弃，用于 lockstep 投递；须在开始投递前调用
+func (e *Exec) SetBlocking(block bool) {
+	e.block = block
+}
+
+// SetErrorReporter 设置命令失败时的上报函数；须在开始投递前调用
+func (e *Exec) SetErrorReporter(r watcher.ErrorReporter) {
+	e.onError = r
+}
+
+// Handle 实现 watcher.EventListener，每个事件执行一次命令
+func (e *Exec) Handle(info *watcher.EventInfo) {
+	e.enqueue(execJob{info: copyEvent(info), count: 1})
+}
+
+// HandleBatch 实现 BatchListener，每批执行一次命令：参数按批次第一个事件渲染，全部事件写入 stdin
+func (e *Exec) HandleBatch(batch []*watcher.EventInfo) {
+	var buf bytes.Buffer
+	for _, info := range batch {
+		data, err := e.opts.Stdin.Encode(info)
+		if err != nil {
+			slog.Warn("exec sink encode failed", "path", info.Path, "err", err)
+			continue
+		}
+		if e.opts.Binary {
+			_ = codec.WriteDelimited(&buf, data)
+		} else {
+			buf.Write(data)
+			buf.WriteByte('\n')
+		}
+	}
+	e.enqueue(execJob{info: copyEvent(batch[0]), stdin: buf.Bytes(), count: len(batch)})
+}
+
+// copyEvent worker 渲染参数时后续监听器可能仍在读写 Attrs，入队副本
+func copyEvent(info *watcher.EventInfo) *watcher.EventInfo {
+	c := *info
+	c.Attrs = maps.Clone(info.Attrs)
+	return &c
+}
+
+func (e *Exec) enqueue(job execJob) {
+	if e.block {
+		select {
+		case e.queue <- job:
+		case <-e.done:
+			e.dropped.Add(uint64(job.count))
+		}
+		return
+	}
+	select {
+	case e.queue <- job:
+	default:
+		e.dropped.Add(uint64(job.count))
+	}
+}
+
+func (e *Exec) run(ctx context.Context) {
+	defer e.wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case job := <-e.queue:
+			if err := e.exec(ctx, job); err != nil {
+				e.failed.Add(1)
+				slog.Warn("exec sink command failed", "command", e.path, "path", job.info.Path, "err", err)
+				if e.onError != nil {
+					e.onError(job.info, err)
+				}
+			}
+		}
+	}
+}
+
+func (e *Exec) exec(ctx context.Context, job execJob) error {
+	args := make([]string, len(e.args))
+	for i, tmpl := range e.args {
+		arg, err := tmpl.Encode(job.info)
+		if err != nil {
+			return fmt.Errorf("render args[%d]: %w", i, err)
+		}
+		args[i] = string(arg)
+	}
+	ctx, cancel := context.WithTimeout(ctx, e.opts.Timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, e.path, args...)
+	// 独立进程组，超时时连同脚本启动的子进程一起杀死
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = execWaitDelay
+	if job.stdin != nil {
+		cmd.Stdin = bytes.NewReader(job.stdin)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	e.started.Add(1)
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		e.timedOut.Add(1)
+		return fmt.Errorf("killed after %s", e.opts.Timeout)
+	}
+	if err != nil && stderr.Len() > 0 {
+		return fmt.Errorf("%w: %s", err, bytes.TrimSpace(tail(stderr.Bytes(), 512)))
+	}
+	return err
+}
+
+// tail 返回 b 的最后 n 个字节，避免把大段输出写入日志
+func tail(b []byte, n int) []byte {
+	if len(b) > n {
+		return b[len(b)-n:]
+	}
+	return b
+}
+
+func (e *Exec) Stats() ExecStats {
+	return ExecStats{
+		Started:  e.started.Load(),
+		Failed:   e.failed.Load(),
+		TimedOut: e.timedOut.Load(),
+		Dropped:  e.dropped.Load(),
+	}
+}
+
+// Close 停止 worker 并杀死正在执行的命令，队列中未执行的事件被丢弃
+func (e *Exec) Close() error {
+	e.cancel()
+	e.wg.Wait()
+	return nil
+}
@@ -0,0 +1,172 @@
+package listener
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/caoenergy/watchman/internal/watcher"
+	"golang.org/x/sys/unix"
+)
+
+func newTestExec(t *testing.T, args []string, opts ExecOptions) *Exec {
+	t.Helper()
+	e, err := NewExec("/bin/sh", args, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = e.Close() })
+	return e
+}
+
+// waitExec 等待 n 次执行结束，超时则失败
+func waitExec(t *testing.T, e *Exec, done func(ExecStats) bool) ExecStats {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		st := e.Stats()
+		if done(st) {
+			return st
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out, stats %+v", st)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// readFileEventually 等待命令写出文件；命令先写临时文件再改名，读到的总是完整内容
+func readFileEventually(t *testing.T, path string) string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if data, err := os.ReadFile(path); err == nil && len(data) > 0 {
+			return string(data)
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%s not written", path)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestExecArgsTemplate(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	// $0 之后的参数逐行写入 out，每个模板渲染为一个参数，含空格也不拆分
+	e := newTestExec(t, []string{"-c", `printf '%s\n' "$@" > ` + out + `.tmp && mv ` + out + `.tmp ` + out, "sh",
+		"{{.Type}}", "{{.Path}}", "{{.Name}}", `{{if .IsDir}}dir{{else}}file{{end}}`, "{{.Attrs.tag}}", "{{.Attrs.missing}}"},
+		ExecOptions{Concurrency: 1})
+	info := &watcher.EventInfo{Type: "CLOSE_WRITE", Dir: "/data/in", Name: "a b.txt", Path: "/data/in/a b.txt"}
+	info.SetAttr("tag", "x")
+	e.Handle(info)
+	got := strings.Split(strings.TrimSuffix(readFileEventually(t, out), "\n"), "\n")
+	want := []string{"CLOSE_WRITE", "/data/in/a b.txt", "a b.txt", "file", "x", "<no value>"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("args %q, want %q", got, want)
+	}
+	if st := waitExec(t, e, func(st ExecStats) bool { return st.Started == 1 }); st.Failed != 0 {
+		t.Errorf("stats %+v", st)
+	}
+
+	if _, err := NewExec("/bin/sh", []string{"{{.Path"}, ExecOptions{}); err == nil {
+		t.Error("NewExec accepted an unterminated template")
+	}
+}
+
+// 批量模式下参数按第一个事件渲染，全部事件逐行写入 stdin
+func TestExecBatch(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	e := newTestExec(t, []string{"-c", `{ echo "$1"; cat; } > ` + out + `.tmp && mv ` + out + `.tmp ` + out, "sh", "{{.Path}}"}, ExecOptions{Concurrency: 1})
+	e.HandleBatch([]*watcher.EventInfo{{Type: "CREATE", Path: "/a"}, {Type: "CREATE", Path: "/b"}})
+	lines := strings.Split(strings.TrimSuffix(readFileEventually(t, out), "\n"), "\n")
+	if len(lines) != 3 || lines[0] != "/a" || !strings.Contains(lines[2], `"path":"/b"`) {
+		t.Fatalf("output %q", lines)
+	}
+}
+
+// 超时杀死整个进程组，包括命令在后台启动的子进程
+func TestExecTimeoutKillsChild(t *testing.T) {
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	e := newTestExec(t, []string{"-c", `sleep 30 & echo $! > ` + pidFile + `; wait`}, ExecOptions{Concurrency: 1, Timeout: 200 * time.Millisecond})
+	var reported atomic.Value
+	e.SetErrorReporter(func(_ *watcher.EventInfo, err error) { reported.Store(err) })
+	start := time.Now()
+	e.Handle(&watcher.EventInfo{Type: "CREATE", Path: "/a"})
+	st := waitExec(t, e, func(st ExecStats) bool { return st.Failed == 1 })
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("command ran for %v with a 200ms timeout", elapsed)
+	}
+	if st.TimedOut != 1 {
+		t.Errorf("stats %+v", st)
+	}
+	if err, _ := reported.Load().(error); err == nil {
+		t.Error("timeout not reported")
+	}
+
+	pid, err := strconv.Atoi(strings.TrimSpace(readFileEventually(t, pidFile)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for childAlive(pid) {
+		if time.Now().After(deadline) {
+			_ = unix.Kill(pid, unix.SIGKILL)
+			t.Fatalf("background child %d survived the timeout", pid)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// childAlive 进程存在且不是僵尸(孤儿进程由 init 回收前为僵尸状态)
+func childAlive(pid int) bool {
+	if err := unix.Kill(pid, 0); errors.Is(err, unix.ESRCH) {
+		return false
+	}
+	stat, err := os.ReadFile("/proc/" + strconv.Itoa(pid) + "/stat")
+	if err != nil {
+		return false
+	}
+	// 格式为 "pid (comm) state ..."
+	fields := strings.Fields(string(stat[strings.LastIndexByte(string(stat), ')')+1:]))
+	return len(fields) > 0 && fields[0] != "Z"
+}
+
+// 入队的是事件副本，worker 渲染参数时后续监听器仍可写 Attrs；配合 -race 运行
+func TestExecCopiesEvent(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	e := newTestExec(t, []string{"-c", `echo "$1" >> ` + out, "sh", "{{.Attrs.k}}"}, ExecOptions{Concurrency: 1})
+	const n = 20
+	for range n {
+		info := &watcher.EventInfo{Type: "CREATE", Path: "/a"}
+		info.SetAttr("k", "queued")
+		e.Handle(info)
+		batch := []*watcher.EventInfo{{Type: "CREATE", Path: "/b"}}
+		batch[0].SetAttr("k", "queued")
+		e.HandleBatch(batch)
+		for i := range 10 {
+			info.SetAttr("k", i)
+			batch[0].SetAttr("k", i)
+		}
+	}
+	var lines []string
+	deadline := time.Now().Add(10 * time.Second)
+	for len(lines) < 2*n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d commands wrote output, want %d", len(lines), 2*n)
+		}
+		time.Sleep(5 * time.Millisecond)
+		data, _ := os.ReadFile(out)
+		lines = strings.Fields(string(data))
+	}
+	for _, line := range lines {
+		if line != "queued" {
+			t.Fatalf("rendered %q, want the attrs at Handle time", line)
+		}
+	}
+}
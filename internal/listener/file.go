@@ -0,0 +1,66 @@
+package listener
+
+import (
+	"log/slog"
+	"os"
+	"sync"
+
+	"github.com/caoenergy/watchman/internal/codec"
+	"github.com/caoenergy/watchman/internal/watcher"
+)
+
+// FileSink 将事件追加写入文件：文本格式每行一条，二进制格式以 varint 长度前缀分隔。
+// 写入在事件循环中同步进行，速度取决于磁盘。
+type FileSink struct {
+	mu      sync.Mutex
+	f       *os.File
+	encoder codec.Encoder
+	binary  bool
+	onError watcher.ErrorReporter
+}
+
+func NewFileSink(path string, encoder codec.Encoder, binary bool) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{f: f, encoder: encoder, binary: binary}, nil
+}
+
+// SetErrorReporter 设置编码或写入失败时的上报函数；须在开始投递前调用
+func (s *FileSink) SetErrorReporter(r watcher.ErrorReporter) {
+	s.onError = r
+}
+
+// Handle 实现 watcher.EventListener
+func (s *FileSink) Handle(info *watcher.EventInfo) {
+	data, err := s.encoder.Encode(info)
+	if err != nil {
+		slog.Warn("file sink encode failed", "path", info.Path, "err", err)
+		s.reportError(info, err)
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.binary {
+		err = codec.WriteDelimited(s.f, data)
+	} else {
+		_, err = s.f.Write(append(data, '\n'))
+	}
+	if err != nil {
+		slog.Warn("file sink write failed", "file", s.f.Name(), "err", err)
+		s.reportError(info, err)
+	}
+}
+
+func (s *FileSink) reportError(info *watcher.EventInfo, err error) {
+	if s.onError != nil {
+		s.onError(info, err)
+	}
+}
+
+func (s *FileSink) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.f.Close()
+}
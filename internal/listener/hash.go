@@ -0,0 +1,142 @@
+package listener
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"io"
+	"maps"
+	"os"
+	"runtime"
+	"sync"
+	"syscall"
+
+	"github.com/caoenergy/watchman/internal/watcher"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	defaultHashMaxBytes = 64 << 20
+	hashQueueSize       = 256
+)
+
+var hashAlgos = map[string]func() hash.Hash{
+	"md5":    md5.New,
+	"sha1":   sha1.New,
+	"sha256": sha256.New,
+	"sha512": sha512.New,
+}
+
+// HashEnricher 为 CLOSE_WRITE 事件计算文件内容摘要，写入 Attrs["hash"](十六进制)与 Attrs["hash_algo"] 后交给下游监听器。
+// 摘要在 worker 池中计算，不阻塞事件循环；事件按路径分片，同一路径的事件(含无需计算的)保持顺序，跨路径不保证，
+// 下游可能被多个 worker 并发调用。下游收到的是事件的副本，不影响同一事件的其他监听器。
+// 超过 maxBytes、非普通文件或读取前已被删除的文件不填充 hash。实现 watcher.Flusher，Stop 时等待排队的事件交付完毕。
+type HashEnricher struct {
+	algo     string
+	newHash  func() hash.Hash
+	maxBytes int64
+
+	mu     sync.RWMutex
+	shards []chan hashJob
+	wg     sync.WaitGroup
+}
+
+type hashJob struct {
+	info *watcher.EventInfo
+	next watcher.EventListener
+}
+
+// NewHashEnricher algo 为 md5、sha1、sha256 或 sha512；maxBytes <= 0 时为 64MiB
+func NewHashEnricher(algo string, maxBytes int64) (*HashEnricher, error) {
+	newHash, ok := hashAlgos[algo]
+	if !ok {
+		return nil, fmt.Errorf("unknown hash algorithm %q", algo)
+	}
+	if maxBytes <= 0 {
+		maxBytes = defaultHashMaxBytes
+	}
+	h := &HashEnricher{algo: algo, newHash: newHash, maxBytes: maxBytes, shards: make([]chan hashJob, runtime.GOMAXPROCS(0))}
+	for i := range h.shards {
+		ch := make(chan hashJob, hashQueueSize)
+		h.shards[i] = ch
+		h.wg.Add(1)
+		go func() {
+			defer h.wg.Done()
+			for job := range ch {
+				h.handle(job)
+			}
+		}()
+	}
+	return h, nil
+}
+
+// Wrap 返回经过摘要计算再调用 next 的监听器；队列满时阻塞，以背压代替丢弃
+func (h *HashEnricher) Wrap(next watcher.EventListener) watcher.EventListener {
+	return func(info *watcher.EventInfo) {
+		// 在调用方协程中复制：下游异步处理时同一事件的其他监听器可能仍在读写 Attrs
+		c := *info
+		c.Attrs = maps.Clone(info.Attrs)
+		job := hashJob{info: &c, next: next}
+		h.mu.RLock()
+		defer h.mu.RUnlock()
+		if h.shards == nil {
+			// 已 Flush，直接在调用方协程中计算
+			h.handle(job)
+			return
+		}
+		f := fnv.New32a()
+		_, _ = f.Write([]byte(info.Path))
+		h.shards[f.Sum32()%uint32(len(h.shards))] <- job
+	}
+}
+
+// Flush 实现 watcher.Flusher：关闭 worker 并等待已排队的事件交付
+func (h *HashEnricher) Flush() error {
+	h.mu.Lock()
+	shards := h.shards
+	h.shards = nil
+	h.mu.Unlock()
+	for _, ch := range shards {
+		close(ch)
+	}
+	h.wg.Wait()
+	return nil
+}
+
+func (h *HashEnricher) handle(job hashJob) {
+	info := job.info
+	if info.Mask&unix.FAN_CLOSE_WRITE == 0 || info.IsDir {
+		job.next(info)
+		return
+	}
+	// 开启 path-translation 时 Path 在本机不存在
+	if sum, ok := h.sum(info.HostPath()); ok {
+		info.SetAttr("hash", sum)
+		info.SetAttr("hash_algo", h.algo)
+	}
+	job.next(info)
+}
+
+// sum 读取时文件可能仍在被写入，读到超过 maxBytes 的内容同样放弃
+func (h *HashEnricher) sum(path string) (string, bool) {
+	f, err := os.OpenFile(path, os.O_RDONLY|syscall.O_NOFOLLOW|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil || !fi.Mode().IsRegular() || fi.Size() > h.maxBytes {
+		return "", false
+	}
+	d := h.newHash()
+	n, err := io.Copy(d, io.LimitReader(f, h.maxBytes+1))
+	if err != nil || n > h.maxBytes {
+		return "", false
+	}
+	return hex.EncodeToString(d.Sum(nil)), true
+}
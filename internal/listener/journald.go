@@ -0,0 +1,63 @@
+package listener
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/caoenergy/watchman/internal/watcher"
+)
+
+const journalSocket = "/run/systemd/journal/socket"
+
+// JournaldHandler 通过 journald 原生协议写入带索引字段的日志，可用 journalctl WATCHMAN_EVENT=DELETE 查询。
+// journal socket 不可用或单条消息超过数据报上限时退回到 stderr 输出。
+func JournaldHandler() watcher.EventListener {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journalSocket, Net: "unixgram"})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "journald unavailable, falling back to stderr: %v\n", err)
+	}
+	return func(info *watcher.EventInfo) {
+		filename := trimDeleted(info.Type, info.Name)
+		fullPath := filepath.Join(info.Dir, filename)
+		if conn != nil {
+			var buf bytes.Buffer
+			writeJournalField(&buf, "MESSAGE", info.Type+" "+fullPath)
+			writeJournalField(&buf, "PRIORITY", "6")
+			writeJournalField(&buf, "SYSLOG_IDENTIFIER", "watchman")
+			writeJournalField(&buf, "WATCHMAN_EVENT", info.Type)
+			writeJournalField(&buf, "WATCHMAN_PATH", fullPath)
+			writeJournalField(&buf, "WATCHMAN_DIR", info.Dir)
+			writeJournalField(&buf, "WATCHMAN_NAME", filename)
+			writeJournalField(&buf, "WATCHMAN_IS_DIR", strconv.FormatBool(info.IsDir))
+			if info.Pid != 0 {
+				writeJournalField(&buf, "WATCHMAN_PID", strconv.Itoa(int(info.Pid)))
+			}
+			if _, err := conn.Write(buf.Bytes()); err == nil {
+				return
+			}
+		}
+		fmt.Fprintln(os.Stderr, info.Type, fullPath)
+	}
+}
+
+// writeJournalField 按原生协议写入字段；值含换行时使用 "KEY\n<64位小端长度><值>\n" 的二进制格式
+func writeJournalField(buf *bytes.Buffer, key, value string) {
+	if !strings.Contains(value, "\n") {
+		buf.WriteString(key)
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+	buf.WriteString(key)
+	buf.WriteByte('\n')
+	_ = binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
@@ -0,0 +1,80 @@
+package listener
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/caoenergy/watchman/internal/watcher"
+)
+
+// jsonLine JSON 输出每行的字段
+type jsonLine struct {
+	Type  string `json:"type"`
+	Dir   string `json:"dir"`
+	Name  string `json:"name"`
+	Path  string `json:"path"`
+	IsDir bool   `json:"is_dir"`
+	Time  string `json:"time,omitempty"` // RFC3339
+}
+
+// JSONOption NewJSONHandler 的可选参数
+type JSONOption func(*jsonWriter)
+
+// JSONTimestamp 设置是否输出 time 字段，默认输出
+func JSONTimestamp(enabled bool) JSONOption {
+	return func(j *jsonWriter) { j.timestamp = enabled }
+}
+
+type jsonWriter struct {
+	mu        sync.Mutex
+	enc       *json.Encoder
+	timestamp bool
+}
+
+func newJSONWriter(w io.Writer, opts []JSONOption) *jsonWriter {
+	if w == nil {
+		w = os.Stdout
+	}
+	j := &jsonWriter{enc: json.NewEncoder(w), timestamp: true}
+	j.enc.SetEscapeHTML(false)
+	for _, opt := range opts {
+		opt(j)
+	}
+	return j
+}
+
+// write 去掉删除事件文件名中的 " (deleted)" 后缀后输出一行；可被多个投递 worker 并发调用
+func (j *jsonWriter) write(eventType, dir, name string, isDir bool, t time.Time) {
+	name = trimDeleted(eventType, name)
+	line := jsonLine{Type: eventType, Dir: dir, Name: name, Path: filepath.Join(dir, name), IsDir: isDir}
+	if j.timestamp {
+		line.Time = t.Format(time.RFC3339)
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if err := j.enc.Encode(line); err != nil {
+		slog.Warn("json handler write failed", "err", err)
+	}
+}
+
+// NewJSONHandler 返回每个事件向 w 写入一行 JSON 的 Listener，可用 AddListener("json", ...) 注册，
+// w 可以是 stdout、文件或管道，为 nil 时写到 os.Stdout。Listener 不携带事件时间，time 取回调时刻。
+func NewJSONHandler(w io.Writer, opts ...JSONOption) watcher.Listener {
+	j := newJSONWriter(w, opts)
+	return func(eventType, dir, filename string, isDir bool) {
+		j.write(eventType, dir, filename, isDir, time.Now())
+	}
+}
+
+// JSONHandler 与 NewJSONHandler 相同，但作为 EventListener 使用事件自身的时间，供 --json 与 jsonl sink 使用
+func JSONHandler(w io.Writer, opts ...JSONOption) watcher.EventListener {
+	j := newJSONWriter(w, opts)
+	return func(info *watcher.EventInfo) {
+		j.write(info.Type, info.Dir, info.Name, info.IsDir, info.Time)
+	}
+}
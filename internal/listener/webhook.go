@@ -0,0 +1,186 @@
+package listener
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/caoenergy/watchman/internal/clock"
+	"github.com/caoenergy/watchman/internal/codec"
+	"github.com/caoenergy/watchman/internal/retry"
+	"github.com/caoenergy/watchman/internal/watcher"
+)
+
+var errCircuitOpen = errors.New("circuit open")
+
+const (
+	defaultWebhookQueueSize = 1024
+	defaultWebhookTimeout   = 5 * time.Second
+)
+
+// WebhookStats webhook sink 的运行状态
+type WebhookStats struct {
+	Circuit   string `json:"circuit"`
+	Sent      uint64 `json:"sent"`
+	Failed    uint64 `json:"failed"`
+	Dropped   uint64 `json:"dropped"`    // 队列满丢弃
+	ShortCirc uint64 `json:"short_circ"` // 熔断打开期间直接丢弃
+}
+
+// Webhook 将事件按 encoder 的格式 POST 到指定 URL。事件先进入有界队列，由后台协程发送，默认不阻塞事件处理；
+// 连续失败达到阈值后熔断，冷却期内的事件直接丢弃并计数。
+type Webhook struct {
+	url     string
+	encoder codec.Encoder
+	client  *http.Client
+	queue   chan *watcher.EventInfo
+	policy  retry.Policy
+	breaker *retry.Breaker
+	clock   clock.Clock
+	cancel  context.CancelFunc
+	done    <-chan struct{}
+	wg      sync.WaitGroup
+	block   bool
+	onError watcher.ErrorReporter
+
+	sent, failed, dropped, shortCirc atomic.Uint64
+}
+
+// NewWebhook policy 决定单个事件的重试退避与连续失败后的熔断
+func NewWebhook(url string, encoder codec.Encoder, policy retry.Policy) *Webhook {
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &Webhook{
+		url:     url,
+		encoder: encoder,
+		client:  &http.Client{Timeout: defaultWebhookTimeout},
+		queue:   make(chan *watcher.EventInfo, defaultWebhookQueueSize),
+		policy:  policy,
+		breaker: policy.NewBreaker(),
+		clock:   clock.Real{},
+		cancel:  cancel,
+		done:    ctx.Done(),
+	}
+	w.wg.Add(1)
+	go w.run(ctx)
+	return w
+}
+
+// SetClock 替换重试退避与熔断冷却的时间来源，用于测试；须在开始投递前调用
+func (w *Webhook) SetClock(c clock.Clock) {
+	w.clock = c
+	w.breaker.SetClock(c)
+}
+
+// SetBlocking 设置队列满时阻塞等待而不是丢弃，用于 lockstep 投递；须在开始投递前调用
+func (w *Webhook) SetBlocking(block bool) {
+	w.block = block
+}
+
+// SetErrorReporter 设置发送失败(重试耗尽或熔断丢弃)时的上报函数；须在开始投递前调用
+func (w *Webhook) SetErrorReporter(r watcher.ErrorReporter) {
+	w.onError = r
+}
+
+// Handle 实现 watcher.EventListener
+func (w *Webhook) Handle(info *watcher.EventInfo) {
+	// 后台协程编码时后续监听器可能仍在读写 Attrs，入队副本
+	c := *info
+	c.Attrs = maps.Clone(info.Attrs)
+	info = &c
+	if w.block {
+		select {
+		case w.queue <- info:
+		case <-w.done:
+			w.dropped.Add(1)
+		}
+		return
+	}
+	select {
+	case w.queue <- info:
+	default:
+		w.dropped.Add(1)
+	}
+}
+
+func (w *Webhook) run(ctx context.Context) {
+	defer w.wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case info := <-w.queue:
+			if !w.breaker.Allow() {
+				w.shortCirc.Add(1)
+				w.reportError(info, errCircuitOpen)
+				continue
+			}
+			if err := w.send(ctx, info); err != nil {
+				w.failed.Add(1)
+				w.breaker.Failure()
+				slog.Warn("webhook send failed", "url", w.url, "path", info.Path, "circuit", w.breaker.State(), "err", err)
+				w.reportError(info, err)
+				continue
+			}
+			w.sent.Add(1)
+			w.breaker.Success()
+		}
+	}
+}
+
+// send 发送单个事件，失败时按策略退避重试
+func (w *Webhook) send(ctx context.Context, info *watcher.EventInfo) error {
+	body, err := w.encoder.Encode(info)
+	if err != nil {
+		return err
+	}
+	return w.policy.Do(ctx, w.clock, func() error {
+		return w.post(ctx, body)
+	})
+}
+
+func (w *Webhook) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", w.encoder.ContentType())
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status: %s", resp.Status)
+	}
+	return nil
+}
+
+func (w *Webhook) reportError(info *watcher.EventInfo, err error) {
+	if w.onError != nil {
+		w.onError(info, err)
+	}
+}
+
+func (w *Webhook) Stats() WebhookStats {
+	return WebhookStats{
+		Circuit:   w.breaker.State(),
+		Sent:      w.sent.Load(),
+		Failed:    w.failed.Load(),
+		Dropped:   w.dropped.Load(),
+		ShortCirc: w.shortCirc.Load(),
+	}
+}
+
+// Close 停止后台发送，队列中未发送的事件被丢弃
+func (w *Webhook) Close() error {
+	w.cancel()
+	w.wg.Wait()
+	return nil
+}
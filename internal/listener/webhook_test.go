@@ -0,0 +1,183 @@
+package listener
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/caoenergy/watchman/internal/clock"
+	"github.com/caoenergy/watchman/internal/codec"
+	"github.com/caoenergy/watchman/internal/retry"
+	"github.com/caoenergy/watchman/internal/watcher"
+)
+
+// flappingServer up 为 false 时返回 503
+type flappingServer struct {
+	*httptest.Server
+	up   atomic.Bool
+	hits atomic.Int64
+}
+
+func newFlappingServer(t *testing.T) *flappingServer {
+	s := &flappingServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		s.hits.Add(1)
+		if !s.up.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// stepClock 退避立即结束并把假时钟推进同样的时长，记录每次退避
+type stepClock struct {
+	*clock.Fake
+	mu    sync.Mutex
+	waits []time.Duration
+}
+
+func (c *stepClock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	c.waits = append(c.waits, d)
+	c.mu.Unlock()
+	c.Advance(d)
+	ch := make(chan time.Time, 1)
+	ch <- c.Now()
+	return ch
+}
+
+func (c *stepClock) backoffs() []time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return slices.Clone(c.waits)
+}
+
+func newTestWebhook(t *testing.T, url string, policy retry.Policy) (*Webhook, *stepClock) {
+	enc, err := codec.New("", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewWebhook(url, enc, policy)
+	clk := &stepClock{Fake: clock.NewFake(time.Now())}
+	w.SetClock(clk)
+	t.Cleanup(func() { _ = w.Close() })
+	return w, clk
+}
+
+// waitStats 等待后台发送协程处理完毕，超时则失败
+func waitStats(t *testing.T, w *Webhook, done func(WebhookStats) bool) WebhookStats {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		st := w.Stats()
+		if done(st) {
+			return st
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out, stats %+v", st)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWebhookBreaker(t *testing.T) {
+	srv := newFlappingServer(t)
+	w, clk := newTestWebhook(t, srv.URL, retry.Policy{MaxAttempts: 1, BreakerThreshold: 3, BreakerCooldown: time.Minute})
+	var reported atomic.Int64
+	w.SetErrorReporter(func(*watcher.EventInfo, error) { reported.Add(1) })
+	send := func(n int) {
+		for range n {
+			w.Handle(&watcher.EventInfo{Type: "CREATE", Path: "/a"})
+		}
+	}
+
+	// 连续 3 次失败后熔断，之后的事件不再请求服务端
+	send(5)
+	st := waitStats(t, w, func(st WebhookStats) bool { return st.Failed+st.ShortCirc == 5 })
+	if st.Failed != 3 || st.ShortCirc != 2 || st.Circuit != retry.CircuitOpen || srv.hits.Load() != 3 {
+		t.Fatalf("after outage: stats %+v, hits %d", st, srv.hits.Load())
+	}
+
+	// 冷却结束后半开，试探失败重新打开
+	clk.Advance(time.Minute)
+	send(2)
+	st = waitStats(t, w, func(st WebhookStats) bool { return st.Failed+st.ShortCirc == 7 })
+	if st.Failed != 4 || st.ShortCirc != 3 || st.Circuit != retry.CircuitOpen || srv.hits.Load() != 4 {
+		t.Fatalf("after failed probe: stats %+v, hits %d", st, srv.hits.Load())
+	}
+
+	// 服务恢复，冷却后的试探成功即关闭
+	srv.up.Store(true)
+	clk.Advance(time.Minute)
+	send(3)
+	st = waitStats(t, w, func(st WebhookStats) bool { return st.Sent == 3 })
+	if st.Circuit != retry.CircuitClosed || st.Failed != 4 || st.ShortCirc != 3 {
+		t.Fatalf("after recovery: stats %+v", st)
+	}
+
+	// 关闭后单次失败不足阈值，不熔断
+	srv.up.Store(false)
+	send(1)
+	// 上报晚于计数更新，一并等待上报完成
+	st = waitStats(t, w, func(st WebhookStats) bool { return st.Failed == 5 && reported.Load() >= 8 })
+	if st.Circuit != retry.CircuitClosed {
+		t.Fatalf("single failure opened the circuit: %+v", st)
+	}
+	if got := reported.Load(); got != 8 {
+		t.Errorf("reported %d errors, want 8", got)
+	}
+}
+
+// 服务端交替失败时由重试兜住，不计入熔断；退避按注入的时钟计时
+func TestWebhookRetryOnFlap(t *testing.T) {
+	var n atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if n.Add(1)%2 == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	}))
+	defer srv.Close()
+	w, clk := newTestWebhook(t, srv.URL, retry.Policy{MaxAttempts: 2, BaseBackoff: time.Minute, BreakerThreshold: 1, BreakerCooldown: time.Hour})
+	for range 5 {
+		w.Handle(&watcher.EventInfo{Type: "CREATE", Path: "/a"})
+	}
+	st := waitStats(t, w, func(st WebhookStats) bool { return st.Sent+st.Failed == 5 })
+	if st.Sent != 5 || st.Circuit != retry.CircuitClosed || n.Load() != 10 {
+		t.Fatalf("stats %+v, requests %d", st, n.Load())
+	}
+	if got := clk.backoffs(); !slices.Equal(got, slices.Repeat([]time.Duration{time.Minute}, 5)) {
+		t.Errorf("backoffs %v, want 5 x 1m", got)
+	}
+}
+
+// 入队的是事件副本，后台编码时后续监听器仍可写 Attrs；配合 -race 运行
+func TestWebhookCopiesEvent(t *testing.T) {
+	bodies := make(chan string, 100)
+	srv := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies <- string(b)
+	}))
+	defer srv.Close()
+	w, _ := newTestWebhook(t, srv.URL, retry.Policy{MaxAttempts: 1, BreakerThreshold: 1, BreakerCooldown: time.Minute})
+	const n = 50
+	for range n {
+		info := &watcher.EventInfo{Type: "CREATE", Path: "/a"}
+		info.SetAttr("k", "queued")
+		w.Handle(info)
+		for i := range 10 {
+			info.SetAttr("k", i)
+		}
+	}
+	for range n {
+		if body := <-bodies; !strings.Contains(body, `"k":"queued"`) {
+			t.Fatalf("body %s, want the attrs at Handle time", body)
+		}
+	}
+}
@@ -3,8 +3,10 @@ package loader
 import (
 	"fmt"
 	"log/slog"
+	"os"
 	"path/filepath"
 	"plugin"
+	"slices"
 
 	wmp "github.com/caoenergy/watchman-plugin"
 	"github.com/caoenergy/watchman/internal/watcher"
@@ -14,12 +16,20 @@ func Load(dir string, wm *watcher.Watchman) error {
 	if dir == "" {
 		return nil
 	}
+	// 目录不存在时 Glob 不报错，需单独区分，避免 plugin-root 写错时静默加载零个插件
+	if _, err := os.Stat(dir); err != nil {
+		slog.Warn("plugin dir unavailable, no plugins loaded", "dir", dir, "err", err)
+		return nil
+	}
 	entries, err := filepath.Glob(filepath.Join(dir, "*.so"))
 	if err != nil {
 		return fmt.Errorf("plugin dir list: %w", err)
 	}
+	if len(entries) == 0 {
+		slog.Info("plugin dir contains no plugins", "dir", dir)
+	}
 	for _, path := range entries {
-		if err := loadOne(path, wm); err != nil {
+		if _, err := loadOne(path, wm); err != nil {
 			slog.Error("load plugin failed", "path", path, "err", err)
 			continue
 		}
@@ -28,30 +38,70 @@ func Load(dir string, wm *watcher.Watchman) error {
 	return nil
 }
 
-func loadOne(path string, wm *watcher.Watchman) error {
+// Reload 重新扫描插件目录：加载新出现的 .so，卸下文件已删除的插件，不影响其他配置。
+// 已加载的文件被原地替换时 Go 运行时仍返回旧插件，不会生效，需使用新的文件名。
+func Reload(dir string, wm *watcher.Watchman) (added, removed []string, err error) {
+	if dir == "" {
+		return nil, nil, nil
+	}
+	entries, err := filepath.Glob(filepath.Join(dir, "*.so"))
+	if err != nil {
+		return nil, nil, fmt.Errorf("plugin dir list: %w", err)
+	}
+	loaded := make(map[string]bool)
+	for _, info := range wm.Plugins() {
+		loaded[info.Path] = true
+		if !slices.Contains(entries, info.Path) && wm.UnregisterPlugin(info.Name) {
+			removed = append(removed, info.Name)
+		}
+	}
+	for _, path := range entries {
+		if loaded[path] {
+			continue
+		}
+		name, err := loadOne(path, wm)
+		if err != nil {
+			slog.Error("load plugin failed", "path", path, "err", err)
+			continue
+		}
+		added = append(added, name)
+	}
+	slog.Info("plugins reloaded", "dir", dir, "added", added, "removed", removed)
+	return added, removed, nil
+}
+
+// loadOne 加载并注册单个插件，返回插件名
+func loadOne(path string, wm *watcher.Watchman) (string, error) {
 	p, err := plugin.Open(path)
 	if err != nil {
-		return fmt.Errorf("plugin open: %w", err)
+		return "", fmt.Errorf("plugin open: %w", err)
 	}
 	sym, err := p.Lookup(wmp.PluginSymbolName)
 	if err != nil {
-		return fmt.Errorf("lookup %s: %w", wmp.PluginSymbolName, err)
+		return "", fmt.Errorf("lookup %s: %w", wmp.PluginSymbolName, err)
 	}
 	handler, ok := sym.(*wmp.Handler)
 	if !ok {
-		return fmt.Errorf("symbol %s is not *plugin.Handler", wmp.PluginSymbolName)
+		return "", fmt.Errorf("symbol %s is not *plugin.Handler", wmp.PluginSymbolName)
 	}
 	if *handler == nil {
-		return fmt.Errorf("symbol %s is nil", wmp.PluginSymbolName)
+		return "", fmt.Errorf("symbol %s is nil", wmp.PluginSymbolName)
 	}
 	h := *handler
 	name := h.Name()
 	if name == "" {
-		return fmt.Errorf("plugin name is empty")
+		return "", fmt.Errorf("plugin name is empty")
+	}
+	// 在 Init 之前按名称拒绝重复注册，避免同名插件的初始化副作用(如打开连接)影响已在运行的插件
+	if slices.ContainsFunc(wm.Plugins(), func(info watcher.PluginInfo) bool { return info.Name == name }) {
+		return "", fmt.Errorf("plugin %s already registered", name)
 	}
 	if err = h.Init(); err != nil {
-		return err
+		return "", err
 	}
-	wm.RegisterPlugin(handler)
-	return nil
+	if err = wm.RegisterPlugin(handler, path); err != nil {
+		_ = h.Close()
+		return "", err
+	}
+	return name, nil
 }
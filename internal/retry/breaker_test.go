@@ -0,0 +1,68 @@
+package retry
+
+import (
+	"testing"
+	"time"
+
+	"github.com/caoenergy/watchman/internal/clock"
+)
+
+func TestBreakerTransitions(t *testing.T) {
+	b := NewBreaker(3, time.Minute)
+	clk := clock.NewFake(time.Now())
+	b.SetClock(clk)
+	state := func(want string) {
+		t.Helper()
+		if got := b.State(); got != want {
+			t.Fatalf("state %s, want %s", got, want)
+		}
+	}
+
+	// 未达阈值时成功清零连续失败计数
+	b.Failure()
+	b.Failure()
+	b.Success()
+	b.Failure()
+	b.Failure()
+	state(CircuitClosed)
+	b.Failure()
+	state(CircuitOpen)
+
+	// 冷却期内拒绝
+	clk.Advance(time.Minute - time.Nanosecond)
+	if b.Allow() {
+		t.Fatal("allowed during cooldown")
+	}
+
+	// 冷却结束后半开，只放行一次试探；试探失败立即重新打开并重新计时
+	clk.Advance(time.Nanosecond)
+	if !b.Allow() {
+		t.Fatal("probe rejected after cooldown")
+	}
+	state(CircuitHalfOpen)
+	if b.Allow() {
+		t.Fatal("second request allowed while probing")
+	}
+	b.Failure()
+	state(CircuitOpen)
+	if b.Allow() {
+		t.Fatal("allowed right after failed probe")
+	}
+
+	// 试探成功则关闭
+	clk.Advance(time.Minute)
+	if !b.Allow() {
+		t.Fatal("probe rejected after second cooldown")
+	}
+	b.Success()
+	state(CircuitClosed)
+	for range 5 {
+		if !b.Allow() {
+			t.Fatal("rejected while closed")
+		}
+	}
+	// 关闭后重新从零计数
+	b.Failure()
+	b.Failure()
+	state(CircuitClosed)
+}
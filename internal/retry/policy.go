@@ -0,0 +1,74 @@
+package retry
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/caoenergy/watchman/internal/clock"
+)
+
+const (
+	DefaultMaxAttempts      = 3
+	DefaultBaseBackoff      = 200 * time.Millisecond
+	DefaultMaxBackoff       = 5 * time.Second
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 30 * time.Second
+)
+
+// Policy 网络类 sink 共用的重试与熔断策略。第 n 次重试前等待 BaseBackoff*2^(n-1)，不超过 MaxBackoff，
+// 再按 Jitter 比例随机缩短(0.2 表示在 [0.8x, x] 之间)，避免多个实例同时重试。
+type Policy struct {
+	MaxAttempts      int // 单个事件的最大尝试次数(含首次)
+	BaseBackoff      time.Duration
+	MaxBackoff       time.Duration
+	Jitter           float64 // 0~1
+	BreakerThreshold int     // 连续失败多少个事件后熔断
+	BreakerCooldown  time.Duration
+}
+
+// DefaultPolicy 未配置时使用的策略，无抖动
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts:      DefaultMaxAttempts,
+		BaseBackoff:      DefaultBaseBackoff,
+		MaxBackoff:       DefaultMaxBackoff,
+		BreakerThreshold: DefaultBreakerThreshold,
+		BreakerCooldown:  DefaultBreakerCooldown,
+	}
+}
+
+// Backoff 返回第 attempt 次重试(从 1 开始)前的等待时间
+func (p Policy) Backoff(attempt int) time.Duration {
+	d := p.BaseBackoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || d < p.MaxBackoff); i++ {
+		d *= 2
+	}
+	if p.MaxBackoff > 0 && d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		d -= time.Duration(rand.Float64() * p.Jitter * float64(d))
+	}
+	return d
+}
+
+// Do 按策略调用 fn 直到成功、次数用尽或 ctx 结束，返回最后一次的错误
+func (p Policy) Do(ctx context.Context, clk clock.Clock, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= p.MaxAttempts {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-clk.After(p.Backoff(attempt)):
+		}
+	}
+}
+
+// NewBreaker 按策略的熔断参数创建熔断器
+func (p Policy) NewBreaker() *Breaker {
+	return NewBreaker(p.BreakerThreshold, p.BreakerCooldown)
+}
@@ -0,0 +1,97 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/caoenergy/watchman/internal/clock"
+)
+
+func TestBackoffGrowth(t *testing.T) {
+	p := Policy{BaseBackoff: 100 * time.Millisecond, MaxBackoff: time.Second}
+	want := []time.Duration{100, 200, 400, 800, 1000, 1000}
+	for i, w := range want {
+		if got := p.Backoff(i + 1); got != w*time.Millisecond {
+			t.Errorf("Backoff(%d) = %v, want %v", i+1, got, w*time.Millisecond)
+		}
+	}
+	// 未设置上限时持续翻倍
+	if got := (Policy{BaseBackoff: time.Millisecond}).Backoff(11); got != 1024*time.Millisecond {
+		t.Errorf("uncapped Backoff(11) = %v", got)
+	}
+}
+
+func TestBackoffJitterBounds(t *testing.T) {
+	p := Policy{BaseBackoff: 100 * time.Millisecond, MaxBackoff: time.Second, Jitter: 0.2}
+	for attempt, full := range map[int]time.Duration{1: 100 * time.Millisecond, 3: 400 * time.Millisecond, 10: time.Second} {
+		lo := full - full/5
+		shortest, longest := full, time.Duration(0)
+		for range 1000 {
+			d := p.Backoff(attempt)
+			if d < lo || d > full {
+				t.Fatalf("Backoff(%d) = %v, want within [%v, %v]", attempt, d, lo, full)
+			}
+			shortest, longest = min(shortest, d), max(longest, d)
+		}
+		// 抖动确实生效，而不是总取同一个值
+		if longest-shortest < (full-lo)/2 {
+			t.Errorf("Backoff(%d) spread [%v, %v] too narrow", attempt, shortest, longest)
+		}
+	}
+}
+
+// recordingClock 记录 Do 的每次退避时长并立即返回
+type recordingClock struct {
+	clock.Real
+	waits []time.Duration
+	block bool // 为 true 时退避永不结束
+}
+
+func (c *recordingClock) After(d time.Duration) <-chan time.Time {
+	c.waits = append(c.waits, d)
+	ch := make(chan time.Time, 1)
+	if !c.block {
+		ch <- time.Now()
+	}
+	return ch
+}
+
+func TestDoRetries(t *testing.T) {
+	p := Policy{MaxAttempts: 4, BaseBackoff: time.Second, MaxBackoff: 3 * time.Second}
+	errFail := errors.New("fail")
+	clk := &recordingClock{}
+	calls := 0
+	err := p.Do(context.Background(), clk, func() error {
+		calls++
+		return errFail
+	})
+	if !errors.Is(err, errFail) || calls != 4 {
+		t.Fatalf("Do = %v after %d calls, want the last error after 4", err, calls)
+	}
+	if want := []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}; !slices.Equal(clk.waits, want) {
+		t.Errorf("backoffs %v, want %v", clk.waits, want)
+	}
+
+	calls = 0
+	err = p.Do(context.Background(), clk, func() error {
+		if calls++; calls < 2 {
+			return errFail
+		}
+		return nil
+	})
+	if err != nil || calls != 2 {
+		t.Errorf("Do = %v after %d calls, want success after 2", err, calls)
+	}
+
+	// 退避期间 ctx 结束
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls = 0
+	err = p.Do(ctx, &recordingClock{block: true}, func() error { calls++; return errFail })
+	if !errors.Is(err, context.Canceled) || calls != 1 {
+		t.Errorf("Do with canceled ctx = %v after %d calls", err, calls)
+	}
+}
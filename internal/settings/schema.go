@@ -0,0 +1,128 @@
+package settings
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+const schemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// zeroDefault 写 0(或不写)时由 applyDefaults 取默认值的字段，schema 须同样接受 0
+const zeroDefault = "0 表示使用默认值"
+
+// schemaRules 以 yaml 路径为键，记录与 Validate/applyDefaults 一致的约束，新增校验时需同步维护。
+var schemaRules = map[string]map[string]any{
+	"watchman.watcher.paths":                     {"minItems": 1, "uniqueItems": true},
+	"watchman.watcher.paths[].match-mode":        {"enum": MatchModes},
+	"watchman.watcher.buffer-max-kb":             {"minimum": 0, "maximum": maxBufferKB},
+	"watchman.watcher.buffer-size-kb":            {"anyOf": []any{map[string]any{"const": 0}, map[string]any{"minimum": minBufferKB}}, "maximum": maxBufferKB, "default": defaultBufferKB, "description": zeroDefault},
+	"watchman.watcher.mark-mode":                 {"enum": MarkModes, "default": defaultMarkMode},
+	"watchman.watcher.report-mode":               {"enum": ReportModes, "default": defaultReportMode},
+	"watchman.watcher.backend":                   {"enum": Backends, "default": defaultBackend},
+	"watchman.watcher.event-targets":             {"enum": EventTargets, "default": defaultEventTargets},
+	"watchman.watcher.ephemeral-window-ms":       {"minimum": 0, "maximum": maxEphemeralWindowMs},
+	"watchman.watcher.debounce-ms":               {"minimum": 0, "maximum": maxDebounceMs},
+	"watchman.watcher.rename-window-ms":          {"minimum": 0, "maximum": maxRenameWindow, "default": defaultRenameWindow, "description": zeroDefault},
+	"watchman.watcher.max-inflight-resolves":     {"minimum": 0, "maximum": maxInflightResolves},
+	"watchman.cache.fd-size":                     {"minimum": 0, "default": defaultFdSize, "description": zeroDefault},
+	"watchman.cache.fd-ttl":                      {"minimum": 0, "maximum": maxCacheTtlSec, "default": defaultFdTtl, "description": zeroDefault},
+	"watchman.cache.fd-negative-ttl-ms":          {"minimum": 0, "maximum": maxFdNegativeTtl, "default": defaultFdNegativeTtl},
+	"watchman.cache.fp-size":                     {"minimum": 0, "default": defaultFpSize, "description": zeroDefault},
+	"watchman.cache.fp-ttl":                      {"minimum": 0, "maximum": maxCacheTtlSec, "default": defaultFpTtl, "description": zeroDefault},
+	"watchman.cache.fp-mode":                     {"enum": FpModes, "default": defaultFpMode},
+	"watchman.cache.fp-quiet-ms":                 {"minimum": 0, "maximum": maxDebounceMs, "default": defaultFpQuietMs, "description": zeroDefault},
+	"watchman.cache.fp-key":                      {"enum": FpKeys, "default": defaultFpKey},
+	"watchman.watcher.resolve-workers":           {"minimum": 0, "maximum": maxWorkers},
+	"watchman.watcher.min-size":                  {"minimum": 0},
+	"watchman.watcher.max-size":                  {"minimum": 0},
+	"watchman.watcher.max-relative-depth":        {"minimum": 0},
+	"watchman.watcher.scan.max-events":           {"minimum": 0, "default": defaultScanMaxEvents},
+	"watchman.watcher.scan.rate":                 {"minimum": 0, "default": defaultScanRate},
+	"watchman.watcher.bulk.threshold":            {"minimum": 0},
+	"watchman.watcher.bulk.window-ms":            {"minimum": 0, "default": defaultBulkWindowMs},
+	"watchman.dispatch.workers":                  {"minimum": 0, "maximum": maxWorkers},
+	"watchman.dispatch.queue-size":               {"minimum": 0, "maximum": maxDispatchQueue, "default": defaultDispatchQueue, "description": zeroDefault},
+	"watchman.dispatch.mode":                     {"enum": DispatchModes, "default": defaultDispatchMode},
+	"watchman.dispatch.shard-by":                 {"enum": ShardKeys, "default": defaultShardBy},
+	"watchman.history.size":                      {"minimum": 0, "maximum": maxHistorySize},
+	"watchman.plugin-queue.queue-size":           {"minimum": 0, "maximum": maxDispatchQueue},
+	"watchman.plugin-queue.retry.jitter":         {"minimum": 0, "maximum": 1},
+	"watchman.watcher.events[]":                  {"enum": MarkableEvents},
+	"watchman.watch-groups[].paths":              {"minItems": 1, "uniqueItems": true},
+	"watchman.watch-groups[].paths[].match-mode": {"enum": MatchModes},
+	"watchman.groups[].events[]":                 {"enum": EventTypes},
+	"watchman.groups[].rate-limit":               {"minimum": 0},
+	"watchman.groups[].hash.algo":                {"enum": HashAlgos},
+	"watchman.groups[].hash.max-bytes":           {"minimum": 0},
+	"watchman.groups[].retry.jitter":             {"minimum": 0, "maximum": 1},
+	"watchman.groups[].format":                   {"enum": SinkFormats, "default": "json"},
+}
+
+// schemaRequired 必填字段，键为父级 yaml 路径（根为空串）。
+var schemaRequired = map[string][]string{
+	"":                                {"watchman"},
+	"watchman":                        {"watcher"},
+	"watchman.watcher":                {"paths"},
+	"watchman.groups[]":               {"name", "sink"},
+	"watchman.watcher.paths[]":        {"path"},
+	"watchman.watch-groups[]":         {"name", "paths"},
+	"watchman.watch-groups[].paths[]": {"path"},
+}
+
+// Schema 根据 Settings 的 yaml 标签与校验常量生成 JSON Schema，供编辑器补全和 CI 校验使用。
+func Schema() ([]byte, error) {
+	root := schemaOf(reflect.TypeOf(Settings{}), "")
+	root["$schema"] = schemaDraft
+	root["title"] = "watchman configuration"
+	return json.MarshalIndent(root, "", "  ")
+}
+
+func schemaOf(t reflect.Type, path string) map[string]any {
+	node := make(map[string]any)
+	switch t.Kind() {
+	case reflect.Struct:
+		props := make(map[string]any)
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+			if name == "" || name == "-" || !f.IsExported() {
+				continue
+			}
+			child := name
+			if path != "" {
+				child = path + "." + name
+			}
+			props[name] = schemaOf(f.Type, child)
+		}
+		node["type"] = "object"
+		node["properties"] = props
+		node["additionalProperties"] = false
+		if req, ok := schemaRequired[path]; ok {
+			node["required"] = req
+		}
+		if t == reflect.TypeOf(WatchPath{}) {
+			// 也可直接写为字符串
+			node = map[string]any{"oneOf": []any{map[string]any{"type": "string"}, node}}
+		}
+	case reflect.Slice:
+		node["type"] = "array"
+		node["items"] = schemaOf(t.Elem(), path+"[]")
+	case reflect.Map:
+		node["type"] = "object"
+		node["additionalProperties"] = schemaOf(t.Elem(), path+"{}")
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		node["type"] = "integer"
+	case reflect.Float32, reflect.Float64:
+		node["type"] = "number"
+	case reflect.Bool:
+		node["type"] = "boolean"
+	case reflect.String:
+		node["type"] = "string"
+	}
+	for k, v := range schemaRules[path] {
+		node[k] = v
+	}
+	return node
+}
@@ -1,47 +1,270 @@
 package settings
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/caoenergy/watchman/platform/linux"
+	"github.com/caoenergy/watchman/platform/linux/nsenter"
 
 	"gopkg.in/yaml.v3"
 )
 
 const (
-	configDirEnvKey = "CONF_DIR"
-	configFilename  = "watchman.yml"
-	defaultBufferKB = 64
-	defaultFdSize   = 4096
-	defaultFdTtl    = 300
-	defaultFpSize   = 5000
-	defaultFpTtl    = 5
-	minBufferKB     = 4
-	maxBufferKB     = 1024
-	minCacheSize    = 1
-	minCacheTtlSec  = 1
-	maxCacheTtlSec  = 86400
+	configDirEnvKey      = "CONF_DIR"
+	configFilename       = "watchman.yml"
+	defaultBufferKB      = 64
+	defaultFdSize        = 4096
+	defaultFdTtl         = 300
+	defaultFdNegativeTtl = 1000
+	maxFdNegativeTtl     = 60000
+	defaultFpSize        = 5000
+	defaultFpTtl         = 5
+	defaultFpKey         = "path"
+	defaultFpMode        = "dedup"
+	defaultFpQuietMs     = 500
+	defaultMarkMode      = "filesystem"
+	defaultReportMode    = "auto"
+	defaultBackend       = "auto"
+	defaultEventTargets  = "files"
+	defaultDispatchQueue = 1024
+	defaultDispatchMode  = "isolated"
+	defaultShardBy       = "path"
+	maxDispatchQueue     = 65536
+	maxHistorySize       = 1 << 20
+	maxEphemeralWindowMs = 60000
+	maxDebounceMs        = 60000
+	maxInflightResolves  = 65536
+	maxWorkers           = 256
+	minBufferKB          = 4
+	maxBufferKB          = 1024
+	minCacheSize         = 1
+	minCacheTtlSec       = 1
+	maxCacheTtlSec       = 86400
+	defaultScanMaxEvents = 100000
+	defaultScanRate      = 1000
+	defaultBulkWindowMs  = 1000
+	defaultRenameWindow  = 100
+	maxRenameWindow      = 10000
 )
 
 type Settings struct {
 	Watchman struct {
+		// 实例名，随会话事件上报，默认主机名
+		Instance   string `yaml:"instance"`
 		PluginRoot string `yaml:"plugin-root"`
-		Watcher    struct {
-			Paths      []string `yaml:"paths"`
+		// plugin-root 不存在时启动失败，否则仅记录警告
+		PluginStrict bool `yaml:"plugin-strict"`
+		Watcher      struct {
+			Paths []WatchPath `yaml:"paths"` // 见 WatchPath
+			// 排除规则：绝对路径为前缀，事件命中 paths 后，若命中的排除前缀比命中的监控路径更长(更具体)则丢弃；
+			// 其余为文件名规则(如 *.swp)，命中即丢弃
+			Exclude    []string `yaml:"exclude"`
 			BufferSize int      `yaml:"buffer-size-kb"`
+			FilterExpr string   `yaml:"filter-expr"`
+			WriterExit bool     `yaml:"writer-exit"` // 写入进程退出时合成 WRITER_EXIT 事件，需内核 >= 5.15
+			// 读取 /proc/<pid>/status 为事件填充触发进程的 uid，按 pid 缓存
+			ReportUid bool `yaml:"report-uid"`
+			// 请求 FAN_REPORT_PIDFD(需内核 >= 5.15，不支持时降级为只有 pid)，report-uid 借助 pidfd 排除 pid 复用
+			ReportPidfd bool `yaml:"report-pidfd"`
+			// 读缓冲区自适应的上限，0 表示固定为 buffer-size-kb；读取持续读满时扩大，空闲时缩回 buffer-size-kb
+			BufferMaxKB int `yaml:"buffer-max-kb"`
+			// 向内核订阅的事件类型，为空时为 CREATE、DELETE、DELETE_SELF、CLOSE_WRITE、MOVED_TO
+			Events []string `yaml:"events"`
+			// 文件名规则(filepath.Match 语法)；name-anywhere 为 true 时不受监控路径限制
+			NamePatterns []string `yaml:"name-patterns"`
+			NameAnywhere bool     `yaml:"name-anywhere"`
+			// 文件扩展名白名单(不区分大小写，可带或不带前导点)，非空时只上报扩展名在其中的文件；不影响目录事件
+			Extensions []string `yaml:"extensions"`
+			// 文件大小范围(字节)，事件时文件大小不在范围内则丢弃；0 表示不限制，文件已不存在(如 DELETE)时总是上报
+			MinSize int64 `yaml:"min-size"`
+			MaxSize int64 `yaml:"max-size"`
+			// 跟踪监控目录内指向树外的符号链接，目标变更时按链接路径上报；启动时需遍历监控目录
+			FollowSymlinks bool `yaml:"follow-symlinks"`
+			// 前缀路径本身(或其上级目录)为符号链接时按真实路径匹配：fanotify 上报的是真实路径，否则永远匹配不到
+			ResolveSymlinks bool `yaml:"resolve-symlinks"`
+			// fanotify 标记方式: filesystem(默认，整个文件系统) | mount(配置路径所在的挂载点，只支持 MountEvents) |
+			// inode(逐个标记配置路径，目录与文件使用不同标志)
+			MarkMode string `yaml:"mark-mode"`
+			// 事件信息格式: auto(默认，优先 FAN_REPORT_DFID_NAME，内核 < 5.9 时降级) | dfid-name | fid(只有对象自身的 handle，没有文件名)
+			ReportMode string `yaml:"report-mode"`
+			// 监控后端: auto(默认，fanotify 不可用时降级为 inotify) | fanotify | inotify(逐目录递归监视，无需特权)
+			Backend string `yaml:"backend"`
+			// 上报哪类对象的事件: files(默认) | dirs | both
+			EventTargets string `yaml:"event-targets"`
+			// 前缀路径不存在时启动失败，否则仅记录警告(路径之后被创建即开始匹配)
+			StrictPaths bool `yaml:"strict-paths"`
+			// 临时文件合并窗口(毫秒)：CREATE 暂存该时长，期间被 DELETE 则两者都不上报；0 表示关闭
+			EphemeralWindowMs int `yaml:"ephemeral-window-ms"`
+			// 防抖安静期(毫秒)：同一路径同一类型的事件连续到达时，安静该时长后只投递最后一个；0 表示关闭
+			DebounceMs int `yaml:"debounce-ms"`
+			// 内核不支持 FAN_RENAME(< 5.17)或使用 inotify 后端时，订阅的 RENAME 由同一次重命名的 MOVED_FROM 与 MOVED_TO 配对得到：
+			// MOVED_FROM 最多等待该时长(毫秒)，期间没有对应的 MOVED_TO 则单独投递；0 表示默认 100
+			RenameWindowMs int `yaml:"rename-window-ms"`
+			// 同时进行的 handle 解析上限(每个占用一个 fd)；0 表示按 RLIMIT_NOFILE 的 1/4 自动取值
+			MaxInflightResolves int `yaml:"max-inflight-resolves"`
+			// 并行解析 handle 的 worker 数，0 表示自动取 GOMAXPROCS(resolve 以系统调用为主)；1 表示在事件循环中串行解析
+			ResolveWorkers int `yaml:"resolve-workers"`
+			// 事件路径相对命中的监控路径的最大层级(直接子项为 1)，对所有路径统一生效；0 表示不限制
+			MaxRelativeDepth int `yaml:"max-relative-depth"`
+			// 为事件填充命中的监控根目录(Root)与相对它的路径(RelPath)
+			RelativePaths bool `yaml:"relative-paths"`
+			// 开始监控时投递 SESSION_START、正常退出时投递 SESSION_END，携带实例名、监控路径与配置摘要
+			SessionEvents bool `yaml:"session-events"`
+			// 路径前缀 → 附加属性的映射文件(YAML 列表，每项含 prefix 与 attrs)，修改后自动重新加载
+			EnrichFile string `yaml:"enrich-file"`
+			// 丢弃这些文件系统类型(statfs 类型名，如 tmpfs、overlay、proc)上的事件
+			ExcludeFsTypes []string `yaml:"exclude-fstypes"`
+			// 启动时加入该挂载命名空间(如 /proc/<pid>/ns/mnt)后再初始化 fanotify，以容器自身的视角监控；
+			// 配置文件仍从宿主机读取，其余路径均按目标命名空间解析
+			MountNs string `yaml:"mount-ns"`
+			// 遍历监控目录为已有文件合成 CREATE 事件：启动时(initial)和/或内核队列溢出之后(on-overflow)，
+			// 每次遍历按 rate 限速，合成 max-events 个事件后截断
+			Scan struct {
+				Initial    bool `yaml:"initial"`
+				OnOverflow bool `yaml:"on-overflow"`
+				MaxEvents  int  `yaml:"max-events"` // 单次遍历合成事件上限，0 表示默认 100000
+				Rate       int  `yaml:"rate"`       // 每秒最多合成的事件数，0 表示默认 1000
+			} `yaml:"scan"`
+			// 批量操作汇总：某个目录在 window-ms 内的事件数达到 threshold 后不再逐个投递，每个窗口投递一个 BULK_CHANGE 汇总，
+			// 事件数回落到阈值以下后恢复；threshold 为 0 表示关闭
+			Bulk struct {
+				Threshold int `yaml:"threshold"`
+				WindowMs  int `yaml:"window-ms"` // 默认 1000
+			} `yaml:"bulk"`
+			// 上报前改写路径(容器内监控宿主机时使用)：去掉 strip 前缀后拼接 prepend，过滤仍使用改写前的路径
+			PathTranslation struct {
+				Strip   string `yaml:"strip"`
+				Prepend string `yaml:"prepend"`
+			} `yaml:"path-translation"`
 		} `yaml:"watcher"`
 		Cache struct {
 			FdSize int `yaml:"fd-size"`
 			FdTtl  int `yaml:"fd-ttl"`
-			FpSize int `yaml:"fp-size"`
-			FpTtl  int `yaml:"fp-ttl"`
+			// 解析失败(文件已删除，ESTALE/ENOENT)的 handle 在该时长(毫秒)内不再 OpenByHandleAt，默认 1000；
+			// inode 被复用后最多延迟该时长即可重新解析
+			FdNegativeTtlMs int `yaml:"fd-negative-ttl-ms"`
+			FpSize          int `yaml:"fp-size"`
+			FpTtl           int `yaml:"fp-ttl"`
+			// 自适应去重：按路径最近的事件间隔调整抑制窗口，fp-ttl 作为上限
+			FpAdaptive bool `yaml:"fp-adaptive"`
+			// 去重键: path(默认) | path+type | dir | inode，决定哪些事件在 fp-ttl 内被合并
+			FpKey string `yaml:"fp-key"`
+			// dedup(默认): 首个事件立即投递，fp-ttl 内的重复被丢弃 | debounce: 同一路径安静 fp-quiet-ms 后投递最后一个事件
+			FpMode    string `yaml:"fp-mode"`
+			FpQuietMs int    `yaml:"fp-quiet-ms"`
 		} `yaml:"cache"`
+		Groups []Group `yaml:"groups"`
+		// 命名监控组：各组有自己的路径，按组注册的监听器(Watchman.AddGroupListener)与插件只收到本组路径的事件；
+		// 组的路径自动并入 watcher.paths
+		WatchGroups []WatchGroup `yaml:"watch-groups"`
+		// 本地管理接口，listen 为空时不启动
+		Admin struct {
+			Listen string `yaml:"listen"` // 监听地址，如 127.0.0.1:9090
+			UI     bool   `yaml:"ui"`     // 提供实时事件页面(/)及 SSE 事件流(/events)
+		} `yaml:"admin"`
+		Metrics struct {
+			Listen string `yaml:"listen"` // Prometheus 指标监听地址，如 127.0.0.1:9100，为空时不启动
+		} `yaml:"metrics"`
+		// 最近事件缓冲：size 为 0 时不记录；socket 非空时在该 Unix 域套接字上提供查询
+		History struct {
+			Size   int    `yaml:"size"`
+			Socket string `yaml:"socket"`
+		} `yaml:"history"`
+		Dispatch struct {
+			// 投递 worker 数，0 表示自动(取 1，保证全局顺序)，1 表示在事件循环中直接调用监听器；
+			// 大于 1 时按路径哈希分片，同一路径的事件保持顺序
+			Workers   int `yaml:"workers"`
+			QueueSize int `yaml:"queue-size"` // 每个 worker 的队列长度
+			// isolated(默认): 自带队列的 sink(如 webhook)队列满时丢弃，慢 sink 不拖累其他 sink；
+			// lockstep: 事件须被所有 sink 接收入队后才投递下一个，各 sink 看到的顺序一致，慢 sink 会阻塞整体
+			Mode string `yaml:"mode"`
+			// 分片键: path(默认，同一路径有序) | dir(同一目录内所有事件有序，目录内事件集中时并行度下降)
+			ShardBy string `yaml:"shard-by"`
+		} `yaml:"dispatch"`
+		// 插件队列：queue-size 为 0(默认)时插件在投递 worker 中同步调用；
+		// 大于 0 时每个插件有独立的有界队列与 worker，失败按 retry 重试，仍失败写入 dead-letter-file(为空时写错误日志)
+		PluginQueue struct {
+			QueueSize      int    `yaml:"queue-size"`
+			Retry          Retry  `yaml:"retry"` // 只使用 max-attempts、base-backoff-ms、max-backoff-ms、jitter
+			DeadLetterFile string `yaml:"dead-letter-file"`
+		} `yaml:"plugin-queue"`
 	} `yaml:"watchman"`
+	written []WatchPath // 见 WrittenPaths，由 normalizePaths 记录
+}
+
+// Group 命名的监听组，每组有独立的过滤条件并输出到指定 sink
+type Group struct {
+	Name      string   `yaml:"name"`
+	Sink      string   `yaml:"sink"`
+	Include   []string `yaml:"include"`
+	Exclude   []string `yaml:"exclude"`
+	Events    []string `yaml:"events"`
+	RateLimit int      `yaml:"rate-limit"` // 每秒最多投递的事件数，0 表示不限制
+	URL       string   `yaml:"url"`        // webhook sink 的目标地址
+	File      string   `yaml:"file"`       // file sink 的输出文件
+	Format    string   `yaml:"format"`     // 序列化格式: json(默认) | cloudevents | protobuf | template
+	Template  string   `yaml:"template"`   // format 为 template 时的 text/template 模板，字段同 JSON 输出
+	Retry     Retry    `yaml:"retry"`      // 网络类 sink(webhook)的重试与熔断策略
+	Exec      Exec     `yaml:"exec"`       // exec sink 的命令与执行参数
+	// 为 CLOSE_WRITE 事件计算文件内容摘要，写入 attrs.hash 后再交给 sink；algo 为空时不计算
+	Hash struct {
+		Algo     string `yaml:"algo"`
+		MaxBytes int64  `yaml:"max-bytes"` // 超过该大小的文件不计算，0 表示默认 64MiB
+	} `yaml:"hash"`
+}
+
+// WatchGroup 命名监控组
+type WatchGroup struct {
+	Name    string      `yaml:"name"`
+	Paths   []WatchPath `yaml:"paths"`
+	Plugins []string    `yaml:"plugins"` // 只接收本组事件的插件名(Handler.Name)
+}
+
+// Exec 每个事件(或每批事件)执行一次的命令，字段为 0 时使用默认值
+type Exec struct {
+	Command     string   `yaml:"command"`
+	Args        []string `yaml:"args"`        // text/template 模板，字段同 JSON 输出，如 "{{.Path}}"
+	Concurrency int      `yaml:"concurrency"` // 同时运行的命令数，默认 4
+	TimeoutMs   int      `yaml:"timeout-ms"`  // 单次执行超时，超时杀死进程组，默认 30000
+	QueueSize   int      `yaml:"queue-size"`  // 等待执行的队列长度，满时丢弃，默认 1024
+	// 批量模式：攒够 batch-size 个或第一个事件等待 batch-interval-ms 后执行一次，事件按 format 写入 stdin
+	BatchSize       int `yaml:"batch-size"`
+	BatchIntervalMs int `yaml:"batch-interval-ms"`
+}
+
+// Retry 网络类 sink 的重试与熔断策略，字段为 0 时使用默认值
+type Retry struct {
+	MaxAttempts        int     `yaml:"max-attempts"`    // 单个事件最大尝试次数(含首次)，默认 3
+	BaseBackoffMs      int     `yaml:"base-backoff-ms"` // 首次重试前的等待，之后每次翻倍，默认 200
+	MaxBackoffMs       int     `yaml:"max-backoff-ms"`  // 单次等待上限，默认 5000
+	Jitter             float64 `yaml:"jitter"`          // 0~1，等待时间随机缩短的最大比例，默认 0
+	BreakerThreshold   int     `yaml:"breaker-threshold"`
+	BreakerCooldownSec int     `yaml:"breaker-cooldown-sec"`
 }
 
+// SinkFormats sink 支持的序列化格式，与 internal/codec 保持一致
+var SinkFormats = []string{"json", "cloudevents", "protobuf", "template"}
+
+// MarkableEvents 可在 watchman.watcher.events 中向内核订阅的事件类型
+var MarkableEvents = []string{"CREATE", "DELETE", "DELETE_SELF", "CLOSE_WRITE", "MOVED_TO", "MOVED_FROM", "RENAME", "MODIFY", "ATTRIB"}
+
+// EventTypes 可在配置中引用的事件类型
+var EventTypes = []string{"CREATE", "DELETE", "DELETE_SELF", "CLOSE_WRITE", "MOVED_TO", "MOVED_FROM", "RENAME", "MODIFY", "ATTRIB", "WRITER_EXIT", "SESSION_START", "SESSION_END", "BULK_CHANGE", "OVERFLOW"}
+
 func Load() (*Settings, error) {
-	data, err := os.ReadFile(getConfigPath())
+	data, err := readConfig()
 	if err != nil {
 		return nil, err
 	}
@@ -57,18 +280,177 @@ func Load() (*Settings, error) {
 	return &s, nil
 }
 
-// normalizePaths 规范化监控路径：Clean 并去掉末尾 '/'，保证与 radix 前缀匹配语义一致。
+// ExcludeFsTypeMagics 返回 exclude-fstypes 对应的 statfs 魔数，Validate 已保证类型名有效
+func (s *Settings) ExcludeFsTypeMagics() []int64 {
+	types := s.Watchman.Watcher.ExcludeFsTypes
+	magics := make([]int64, 0, len(types))
+	for _, t := range types {
+		if m, ok := linux.FsMagic(t); ok {
+			magics = append(magics, m)
+		}
+	}
+	return magics
+}
+
+// Fingerprint 返回生效配置(含默认值)的摘要，配置内容不变时保持不变，用于关联事件与配置版本
+func (s *Settings) Fingerprint() string {
+	data, err := yaml.Marshal(s)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// normalizePaths 规范化监控路径，见 NormalizePath；开启 resolve-symlinks 时前缀路径替换为解析符号链接后的真实路径
 func (s *Settings) normalizePaths() {
+	s.written = slices.Clone(s.Watchman.Watcher.Paths)
 	for i, p := range s.Watchman.Watcher.Paths {
-		p = filepath.Clean(p)
-		for len(p) > 1 && p[len(p)-1] == '/' {
-			p = p[:len(p)-1]
+		s.Watchman.Watcher.Paths[i] = s.normalizeWatchPath(p)
+	}
+	for i, g := range s.Watchman.WatchGroups {
+		for j, p := range g.Paths {
+			p = s.normalizeWatchPath(p)
+			s.Watchman.WatchGroups[i].Paths[j] = p
+			if !slices.ContainsFunc(s.Watchman.Watcher.Paths, func(w WatchPath) bool { return w.Path == p.Path }) {
+				s.Watchman.Watcher.Paths = append(s.Watchman.Watcher.Paths, p)
+				s.written = append(s.written, WatchPath{})
+			}
+		}
+	}
+	for i, p := range s.Watchman.Watcher.Exclude {
+		if filepath.IsAbs(p) {
+			s.Watchman.Watcher.Exclude[i] = NormalizePath(p)
 		}
-		s.Watchman.Watcher.Paths[i] = p
 	}
+	pt := &s.Watchman.Watcher.PathTranslation
+	if pt.Strip != "" {
+		pt.Strip = filepath.Clean(pt.Strip)
+	}
+	if pt.Prepend != "" {
+		pt.Prepend = filepath.Clean(pt.Prepend)
+	}
+}
+
+// MatchModes 监控路径支持的匹配方式
+var MatchModes = []string{MatchPrefix, MatchGlob, MatchRegex}
+
+const (
+	MatchPrefix = "prefix"
+	MatchGlob   = "glob"  // '*' 等匹配单段，'**' 匹配任意多段，作用于完整路径
+	MatchRegex  = "regex" // Go regexp 语法，作用于完整路径，未以 ^ 锚定时可命中路径中任意位置
+)
+
+// WatchPath 监控路径。配置中可直接写字符串(含通配符时按 glob，否则按 prefix 匹配)，
+// 也可写为 {path, match-mode} 显式指定匹配方式，如 {path: '^/var/log/.*\.log$', match-mode: regex}
+type WatchPath struct {
+	Path      string `yaml:"path"`
+	MatchMode string `yaml:"match-mode,omitempty"`
 }
 
+func (p *WatchPath) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		*p = WatchPath{Path: node.Value}
+		return nil
+	}
+	type plain WatchPath
+	return node.Decode((*plain)(p))
+}
+
+// MarshalYAML 未指定匹配方式时写回为字符串，保持与旧配置格式一致
+func (p WatchPath) MarshalYAML() (any, error) {
+	if p.MatchMode == "" {
+		return p.Path, nil
+	}
+	type plain WatchPath
+	return plain(p), nil
+}
+
+// Mode 返回生效的匹配方式
+func (p WatchPath) Mode() string {
+	if p.MatchMode != "" {
+		return p.MatchMode
+	}
+	if strings.ContainsAny(p.Path, "*?[") {
+		return MatchGlob
+	}
+	return MatchPrefix
+}
+
+// MarkModes 支持的 fanotify 标记方式
+var MarkModes = []string{"filesystem", "mount", "inode"}
+
+// ReportModes 支持的 fanotify 事件信息格式
+var ReportModes = []string{"auto", "dfid-name", "fid"}
+
+// Backends 支持的监控后端
+var Backends = []string{"auto", "fanotify", "inotify"}
+
+// HashAlgos groups[].hash 支持的摘要算法
+var HashAlgos = []string{"md5", "sha1", "sha256", "sha512"}
+
+// EventTargets 支持的事件对象
+var EventTargets = []string{"files", "dirs", "both"}
+
+// MountEvents mark-mode 为 mount 时可订阅的事件：FAN_MARK_MOUNT 不支持目录项类事件
+var MountEvents = []string{"CLOSE_WRITE", "MODIFY"}
+
+// FpModes 路径缓存的合并方式
+var FpModes = []string{"dedup", "debounce"}
+
+// FpKeys 支持的去重键策略，与 watcher.KeyPath 等保持一致
+var FpKeys = []string{"path", "path+type", "dir", "inode"}
+
+// DispatchModes 支持的跨 sink 投递方式
+var DispatchModes = []string{"isolated", "lockstep"}
+
+// ShardKeys 支持的投递分片键
+var ShardKeys = []string{"path", "dir"}
+
 func (s *Settings) applyDefaults() {
+	if s.Watchman.Instance == "" {
+		s.Watchman.Instance, _ = os.Hostname()
+	}
+	// worker 数为 0 时按 GOMAXPROCS 自动取值，显式配置优先
+	if s.Watchman.Watcher.ResolveWorkers == 0 {
+		s.Watchman.Watcher.ResolveWorkers = min(runtime.GOMAXPROCS(0), maxWorkers)
+	}
+	if s.Watchman.Dispatch.Workers == 0 {
+		s.Watchman.Dispatch.Workers = 1
+	}
+	if s.Watchman.Dispatch.Mode == "" {
+		s.Watchman.Dispatch.Mode = defaultDispatchMode
+	}
+	if s.Watchman.Dispatch.ShardBy == "" {
+		s.Watchman.Dispatch.ShardBy = defaultShardBy
+	}
+	if s.Watchman.Dispatch.QueueSize <= 0 {
+		s.Watchman.Dispatch.QueueSize = defaultDispatchQueue
+	}
+	if s.Watchman.Watcher.MarkMode == "" {
+		s.Watchman.Watcher.MarkMode = defaultMarkMode
+	}
+	if s.Watchman.Watcher.ReportMode == "" {
+		s.Watchman.Watcher.ReportMode = defaultReportMode
+	}
+	if s.Watchman.Watcher.Backend == "" {
+		s.Watchman.Watcher.Backend = defaultBackend
+	}
+	if s.Watchman.Watcher.EventTargets == "" {
+		s.Watchman.Watcher.EventTargets = defaultEventTargets
+	}
+	if s.Watchman.Watcher.Scan.MaxEvents == 0 {
+		s.Watchman.Watcher.Scan.MaxEvents = defaultScanMaxEvents
+	}
+	if s.Watchman.Watcher.Scan.Rate == 0 {
+		s.Watchman.Watcher.Scan.Rate = defaultScanRate
+	}
+	if s.Watchman.Watcher.RenameWindowMs == 0 {
+		s.Watchman.Watcher.RenameWindowMs = defaultRenameWindow
+	}
+	if s.Watchman.Watcher.Bulk.WindowMs == 0 {
+		s.Watchman.Watcher.Bulk.WindowMs = defaultBulkWindowMs
+	}
 	if s.Watchman.Watcher.BufferSize <= 0 {
 		s.Watchman.Watcher.BufferSize = defaultBufferKB
 	}
@@ -78,45 +460,443 @@ func (s *Settings) applyDefaults() {
 	if s.Watchman.Cache.FdTtl <= 0 {
 		s.Watchman.Cache.FdTtl = defaultFdTtl
 	}
+	if s.Watchman.Cache.FdNegativeTtlMs <= 0 {
+		s.Watchman.Cache.FdNegativeTtlMs = defaultFdNegativeTtl
+	}
 	if s.Watchman.Cache.FpSize <= 0 {
 		s.Watchman.Cache.FpSize = defaultFpSize
 	}
 	if s.Watchman.Cache.FpTtl <= 0 {
 		s.Watchman.Cache.FpTtl = defaultFpTtl
 	}
+	if s.Watchman.Cache.FpKey == "" {
+		s.Watchman.Cache.FpKey = defaultFpKey
+	}
+	if s.Watchman.Cache.FpMode == "" {
+		s.Watchman.Cache.FpMode = defaultFpMode
+	}
+	if s.Watchman.Cache.FpQuietMs == 0 {
+		s.Watchman.Cache.FpQuietMs = defaultFpQuietMs
+	}
 }
 
-// Validate 校验配置合法性，Load 时自动调用。
-func (s *Settings) Validate() error {
-	if len(s.Watchman.Watcher.Paths) == 0 {
+// WrittenPaths 返回与 Watcher.Paths 一一对应的配置文件原始写法(未规范化、未解析符号链接)，
+// 由监控组并入的路径对应空 WatchPath；未经 Load 构造时即为 Watcher.Paths
+func (s *Settings) WrittenPaths() []WatchPath {
+	if len(s.written) != len(s.Watchman.Watcher.Paths) {
+		return s.Watchman.Watcher.Paths
+	}
+	return s.written
+}
+
+func (s *Settings) normalizeWatchPath(p WatchPath) WatchPath {
+	if p.Mode() != MatchRegex {
+		p.Path = NormalizePath(p.Path)
+	}
+	if s.Watchman.Watcher.ResolveSymlinks && p.Mode() == MatchPrefix {
+		// 尚不存在的路径保持原样(见 MissingPaths)，悬空链接由 validateSymlinks 报错
+		if real, err := filepath.EvalSymlinks(p.Path); err == nil {
+			p.Path = real
+		}
+	}
+	return p
+}
+
+// NormalizePath 规范化监控路径：Clean 并去掉末尾 '/'，保证与 radix 前缀匹配语义一致；
+// 空串保持为空(Clean 会得到 ".")，交由 ValidatePaths 拒绝
+func NormalizePath(p string) string {
+	if p == "" {
+		return ""
+	}
+	p = filepath.Clean(p)
+	for len(p) > 1 && p[len(p)-1] == '/' {
+		p = p[:len(p)-1]
+	}
+	return p
+}
+
+// ValidatePaths 校验监控路径：非空、不重复、匹配方式有效且通配/正则模式合法；运行时替换监控路径(如 SIGHUP 重新加载)时复用
+func ValidatePaths(paths []WatchPath) error {
+	if len(paths) == 0 {
 		return errors.New("watchman.watcher.paths cannot be empty")
 	}
 	seen := make(map[string]bool)
-	for _, p := range s.Watchman.Watcher.Paths {
+	for _, wp := range paths {
+		p := wp.Path
 		if p == "" {
 			return errors.New("watchman.watcher.paths contains empty path")
 		}
 		if seen[p] {
 			return fmt.Errorf("watchman.watcher.paths duplicate path: %s", p)
 		}
+		switch wp.Mode() {
+		case MatchGlob:
+			for _, seg := range strings.Split(p, "/") {
+				if _, err := filepath.Match(seg, ""); err != nil {
+					return fmt.Errorf("watchman.watcher.paths invalid pattern: %s", p)
+				}
+			}
+		case MatchRegex:
+			if _, err := regexp.Compile(p); err != nil {
+				return fmt.Errorf("watchman.watcher.paths invalid regex: %w", err)
+			}
+		case MatchPrefix:
+		default:
+			return fmt.Errorf("watchman.watcher.paths[%s].match-mode must be one of %v, got %s", p, MatchModes, wp.MatchMode)
+		}
 		seen[p] = true
 	}
+	return nil
+}
+
+// validateSymlinks 开启 resolve-symlinks 时，解析后仍为符号链接的前缀路径是悬空链接(目标不存在)，
+// 无法确定该匹配哪个真实路径，报错而不是按链接字面路径静默地匹配不到事件
+func (s *Settings) validateSymlinks() error {
+	if !s.Watchman.Watcher.ResolveSymlinks {
+		return nil
+	}
+	for _, p := range s.Watchman.Watcher.Paths {
+		if p.Mode() != MatchPrefix {
+			continue
+		}
+		if fi, err := os.Lstat(p.Path); err == nil && fi.Mode()&os.ModeSymlink != 0 {
+			return fmt.Errorf("watchman.watcher.paths %s is a symlink whose target does not exist; with resolve-symlinks "+
+				"only paths that do not exist at all are kept as written (they match once created)", p.Path)
+		}
+	}
+	return nil
+}
+
+// MissingPaths 返回磁盘上不存在的前缀路径；通配与正则规则本就可匹配之后出现的路径，不检查
+func MissingPaths(paths []WatchPath) []string {
+	var missing []string
+	for _, p := range paths {
+		if p.Mode() != MatchPrefix {
+			continue
+		}
+		if _, err := os.Stat(p.Path); err != nil {
+			missing = append(missing, p.Path)
+		}
+	}
+	return missing
+}
+
+// ValidateExclude 校验排除规则：绝对路径为前缀(不含通配符)，其余为文件名规则(filepath.Match 语法，不含 '/')
+func ValidateExclude(exclude []string) error {
+	for _, p := range exclude {
+		if filepath.IsAbs(p) {
+			if strings.ContainsAny(p, "*?[") {
+				return fmt.Errorf("watchman.watcher.exclude prefix must not contain wildcards: %q", p)
+			}
+			continue
+		}
+		if _, err := filepath.Match(p, ""); err != nil || p == "" || strings.Contains(p, "/") {
+			return fmt.Errorf("watchman.watcher.exclude must be an absolute path prefix or a file name pattern, got %q", p)
+		}
+	}
+	return nil
+}
+
+// Validate 校验配置合法性，Load 时自动调用。
+func (s *Settings) Validate() error {
+	if err := ValidatePaths(s.Watchman.Watcher.Paths); err != nil {
+		return err
+	}
+	if err := s.validatePluginRoot(); err != nil {
+		return err
+	}
+	if err := s.validateMarkMode(); err != nil {
+		return err
+	}
+	if !slices.Contains(ReportModes, s.Watchman.Watcher.ReportMode) {
+		return fmt.Errorf("watchman.watcher.report-mode must be one of %v, got %s", ReportModes, s.Watchman.Watcher.ReportMode)
+	}
+	if !slices.Contains(Backends, s.Watchman.Watcher.Backend) {
+		return fmt.Errorf("watchman.watcher.backend must be one of %v, got %s", Backends, s.Watchman.Watcher.Backend)
+	}
+	if !slices.Contains(EventTargets, s.Watchman.Watcher.EventTargets) {
+		return fmt.Errorf("watchman.watcher.event-targets must be one of %v, got %s", EventTargets, s.Watchman.Watcher.EventTargets)
+	}
+	if err := s.validateSymlinks(); err != nil {
+		return err
+	}
+	if missing := MissingPaths(s.Watchman.Watcher.Paths); len(missing) > 0 && s.Watchman.Watcher.StrictPaths {
+		return fmt.Errorf("watchman.watcher.paths do not exist: %s", strings.Join(missing, ", "))
+	}
+	for _, e := range s.Watchman.Watcher.Events {
+		if !slices.Contains(MarkableEvents, e) {
+			return fmt.Errorf("watchman.watcher.events must be one of %v, got %s", MarkableEvents, e)
+		}
+	}
+	if err := ValidateExclude(s.Watchman.Watcher.Exclude); err != nil {
+		return err
+	}
+	for _, p := range s.Watchman.Watcher.NamePatterns {
+		if _, err := filepath.Match(p, ""); err != nil || p == "" {
+			return fmt.Errorf("watchman.watcher.name-patterns invalid pattern: %q", p)
+		}
+	}
+	for _, e := range s.Watchman.Watcher.Extensions {
+		if strings.TrimPrefix(e, ".") == "" || strings.ContainsAny(e, "/") {
+			return fmt.Errorf("watchman.watcher.extensions invalid extension: %q", e)
+		}
+	}
+	if w := s.Watchman.Watcher; w.MinSize < 0 || w.MaxSize < 0 || w.MaxSize > 0 && w.MinSize > w.MaxSize {
+		return errors.New("watchman.watcher.min-size and max-size must be >= 0 and min-size <= max-size")
+	}
+	if s.Watchman.Watcher.NameAnywhere && len(s.Watchman.Watcher.NamePatterns) == 0 {
+		return errors.New("watchman.watcher.name-anywhere requires watchman.watcher.name-patterns")
+	}
+	if ms := s.Watchman.Watcher.EphemeralWindowMs; ms < 0 || ms > maxEphemeralWindowMs {
+		return fmt.Errorf("watchman.watcher.ephemeral-window-ms must be between 0 and %d", maxEphemeralWindowMs)
+	}
+	if ms := s.Watchman.Watcher.DebounceMs; ms < 0 || ms > maxDebounceMs {
+		return fmt.Errorf("watchman.watcher.debounce-ms must be between 0 and %d", maxDebounceMs)
+	}
+	if ms := s.Watchman.Watcher.RenameWindowMs; ms < 0 || ms > maxRenameWindow {
+		return fmt.Errorf("watchman.watcher.rename-window-ms must be between 0 and %d", maxRenameWindow)
+	}
+	if n := s.Watchman.Watcher.MaxInflightResolves; n < 0 || n > maxInflightResolves {
+		return fmt.Errorf("watchman.watcher.max-inflight-resolves must be between 0 and %d", maxInflightResolves)
+	}
+	if n := s.Watchman.Watcher.ResolveWorkers; n < 0 || n > maxWorkers {
+		return fmt.Errorf("watchman.watcher.resolve-workers must be between 0 and %d", maxWorkers)
+	}
+	if s.Watchman.Watcher.MaxRelativeDepth < 0 {
+		return errors.New("watchman.watcher.max-relative-depth must be >= 0")
+	}
+	if s.Watchman.Watcher.Scan.MaxEvents < 0 {
+		return errors.New("watchman.watcher.scan.max-events must be >= 0")
+	}
+	if s.Watchman.Watcher.Scan.Rate < 0 {
+		return errors.New("watchman.watcher.scan.rate must be >= 0")
+	}
+	if s.Watchman.Watcher.Bulk.Threshold < 0 {
+		return errors.New("watchman.watcher.bulk.threshold must be >= 0")
+	}
+	if s.Watchman.Watcher.Bulk.WindowMs < 0 {
+		return errors.New("watchman.watcher.bulk.window-ms must be >= 0")
+	}
+	pt := s.Watchman.Watcher.PathTranslation
+	if (pt.Strip != "" && !filepath.IsAbs(pt.Strip)) || (pt.Prepend != "" && !filepath.IsAbs(pt.Prepend)) {
+		return errors.New("watchman.watcher.path-translation strip/prepend must be absolute paths")
+	}
 	buf := s.Watchman.Watcher.BufferSize
 	if buf < minBufferKB || buf > maxBufferKB {
 		return fmt.Errorf("watchman.watcher.buffer-size-kb must be between %d and %d, got %d", minBufferKB, maxBufferKB, buf)
 	}
+	if bufMax := s.Watchman.Watcher.BufferMaxKB; bufMax != 0 && (bufMax < buf || bufMax > maxBufferKB) {
+		return fmt.Errorf("watchman.watcher.buffer-max-kb must be 0 or between buffer-size-kb and %d, got %d", maxBufferKB, bufMax)
+	}
 	if s.Watchman.Cache.FdSize < minCacheSize {
 		return fmt.Errorf("watchman.cache.fd-size must be >= %d", minCacheSize)
 	}
 	if s.Watchman.Cache.FdTtl < minCacheTtlSec || s.Watchman.Cache.FdTtl > maxCacheTtlSec {
 		return fmt.Errorf("watchman.cache.fd-ttl must be between %d and %d seconds", minCacheTtlSec, maxCacheTtlSec)
 	}
+	if s.Watchman.Cache.FdNegativeTtlMs > maxFdNegativeTtl {
+		return fmt.Errorf("watchman.cache.fd-negative-ttl-ms must be <= %d", maxFdNegativeTtl)
+	}
 	if s.Watchman.Cache.FpSize < minCacheSize {
 		return fmt.Errorf("watchman.cache.fp-size must be >= %d", minCacheSize)
 	}
 	if s.Watchman.Cache.FpTtl < minCacheTtlSec || s.Watchman.Cache.FpTtl > maxCacheTtlSec {
 		return fmt.Errorf("watchman.cache.fp-ttl must be between %d and %d seconds", minCacheTtlSec, maxCacheTtlSec)
 	}
+	for _, t := range s.Watchman.Watcher.ExcludeFsTypes {
+		if _, ok := linux.FsMagic(t); !ok {
+			return fmt.Errorf("watchman.watcher.exclude-fstypes unknown filesystem type: %s", t)
+		}
+	}
+	if !slices.Contains(FpKeys, s.Watchman.Cache.FpKey) {
+		return fmt.Errorf("watchman.cache.fp-key must be one of %v, got %s", FpKeys, s.Watchman.Cache.FpKey)
+	}
+	if !slices.Contains(FpModes, s.Watchman.Cache.FpMode) {
+		return fmt.Errorf("watchman.cache.fp-mode must be one of %v, got %s", FpModes, s.Watchman.Cache.FpMode)
+	}
+	if ms := s.Watchman.Cache.FpQuietMs; ms < 1 || ms > maxDebounceMs {
+		return fmt.Errorf("watchman.cache.fp-quiet-ms must be between 1 and %d", maxDebounceMs)
+	}
+	if s.Watchman.Cache.FpMode == "debounce" && s.Watchman.Watcher.DebounceMs > 0 {
+		return errors.New("watchman.cache.fp-mode debounce cannot be combined with watchman.watcher.debounce-ms")
+	}
+	if w := s.Watchman.Dispatch.Workers; w < 0 || w > maxWorkers {
+		return fmt.Errorf("watchman.dispatch.workers must be between 0 and %d", maxWorkers)
+	}
+	if q := s.Watchman.Dispatch.QueueSize; q > maxDispatchQueue {
+		return fmt.Errorf("watchman.dispatch.queue-size must be <= %d", maxDispatchQueue)
+	}
+	if err := s.validateDispatchMode(); err != nil {
+		return err
+	}
+	if h := s.Watchman.History.Size; h < 0 || h > maxHistorySize {
+		return fmt.Errorf("watchman.history.size must be between 0 and %d", maxHistorySize)
+	}
+	if s.Watchman.History.Socket != "" && s.Watchman.History.Size == 0 {
+		return errors.New("watchman.history.socket requires watchman.history.size")
+	}
+	pq := s.Watchman.PluginQueue
+	if pq.QueueSize < 0 || pq.QueueSize > maxDispatchQueue {
+		return fmt.Errorf("watchman.plugin-queue.queue-size must be between 0 and %d", maxDispatchQueue)
+	}
+	if r := pq.Retry; r.MaxAttempts < 0 || r.BaseBackoffMs < 0 || r.MaxBackoffMs < 0 {
+		return errors.New("watchman.plugin-queue.retry values must be >= 0")
+	}
+	if j := pq.Retry.Jitter; j < 0 || j > 1 {
+		return errors.New("watchman.plugin-queue.retry.jitter must be between 0 and 1")
+	}
+	if s.Watchman.Admin.UI && s.Watchman.Admin.Listen == "" {
+		return errors.New("watchman.admin.ui requires watchman.admin.listen")
+	}
+	if err := s.validateWatchGroups(); err != nil {
+		return err
+	}
+	return s.validateGroups()
+}
+
+func (s *Settings) validateWatchGroups() error {
+	names := make(map[string]bool)
+	plugins := make(map[string]string)
+	for i, g := range s.Watchman.WatchGroups {
+		if g.Name == "" {
+			return fmt.Errorf("watchman.watch-groups[%d].name cannot be empty", i)
+		}
+		if names[g.Name] {
+			return fmt.Errorf("watchman.watch-groups duplicate name: %s", g.Name)
+		}
+		names[g.Name] = true
+		if err := ValidatePaths(g.Paths); err != nil {
+			return fmt.Errorf("watchman.watch-groups[%s]: %w", g.Name, err)
+		}
+		for _, p := range g.Plugins {
+			if other, ok := plugins[p]; ok {
+				return fmt.Errorf("watchman.watch-groups[%s].plugins: %s already belongs to group %s", g.Name, p, other)
+			}
+			plugins[p] = g.Name
+		}
+	}
+	return nil
+}
+
+func (s *Settings) validateDispatchMode() error {
+	mode := s.Watchman.Dispatch.Mode
+	if !slices.Contains(DispatchModes, mode) {
+		return fmt.Errorf("watchman.dispatch.mode must be one of %v, got %s", DispatchModes, mode)
+	}
+	if by := s.Watchman.Dispatch.ShardBy; !slices.Contains(ShardKeys, by) {
+		return fmt.Errorf("watchman.dispatch.shard-by must be one of %v, got %s", ShardKeys, by)
+	}
+	// 多个 worker 并行投递不同路径的事件，无法保证各 sink 间顺序一致
+	if mode == "lockstep" && s.Watchman.Dispatch.Workers > 1 {
+		return errors.New("watchman.dispatch.mode lockstep requires watchman.dispatch.workers <= 1")
+	}
+	return nil
+}
+
+func (s *Settings) validatePluginRoot() error {
+	root := s.Watchman.PluginRoot
+	if root == "" {
+		return nil
+	}
+	fi, err := os.Stat(root)
+	if err != nil {
+		if s.Watchman.PluginStrict {
+			return fmt.Errorf("watchman.plugin-root: %w", err)
+		}
+		return nil
+	}
+	if !fi.IsDir() {
+		return fmt.Errorf("watchman.plugin-root is not a directory: %s", root)
+	}
+	return nil
+}
+
+func (s *Settings) validateMarkMode() error {
+	mode := s.Watchman.Watcher.MarkMode
+	if !slices.Contains(MarkModes, mode) {
+		return fmt.Errorf("watchman.watcher.mark-mode must be one of %v, got %s", MarkModes, mode)
+	}
+	if mode == "mount" {
+		for _, e := range s.Watchman.Watcher.Events {
+			if !slices.Contains(MountEvents, e) {
+				return fmt.Errorf("watchman.watcher.events %s is not supported with mark-mode mount, supported: %v", e, MountEvents)
+			}
+		}
+		return nil
+	}
+	if mode != "inode" {
+		return nil
+	}
+	// inode 模式逐个标记路径，路径必须存在且只能按前缀匹配
+	for _, p := range s.Watchman.Watcher.Paths {
+		if p.Mode() != MatchPrefix {
+			return fmt.Errorf("watchman.watcher.paths pattern %s is not supported with mark-mode inode", p.Path)
+		}
+		if _, err := os.Stat(p.Path); err != nil {
+			return fmt.Errorf("watchman.watcher.paths %s: %w", p.Path, err)
+		}
+	}
+	return nil
+}
+
+func (s *Settings) validateGroups() error {
+	names := make(map[string]bool)
+	for i, g := range s.Watchman.Groups {
+		if g.Name == "" {
+			return fmt.Errorf("watchman.groups[%d].name cannot be empty", i)
+		}
+		if names[g.Name] {
+			return fmt.Errorf("watchman.groups duplicate name: %s", g.Name)
+		}
+		names[g.Name] = true
+		if g.Sink == "" {
+			return fmt.Errorf("watchman.groups[%s].sink cannot be empty", g.Name)
+		}
+		for _, e := range g.Events {
+			if !slices.Contains(EventTypes, e) {
+				return fmt.Errorf("watchman.groups[%s].events unknown event type: %s", g.Name, e)
+			}
+		}
+		if g.Format != "" && !slices.Contains(SinkFormats, g.Format) {
+			return fmt.Errorf("watchman.groups[%s].format must be one of %v, got %s", g.Name, SinkFormats, g.Format)
+		}
+		if g.Format == "template" {
+			if g.Template == "" {
+				return fmt.Errorf("watchman.groups[%s].template cannot be empty with format template", g.Name)
+			}
+			if _, err := template.New(g.Name).Parse(g.Template); err != nil {
+				return fmt.Errorf("watchman.groups[%s].template: %w", g.Name, err)
+			}
+		}
+		if g.RateLimit < 0 {
+			return fmt.Errorf("watchman.groups[%s].rate-limit must be >= 0", g.Name)
+		}
+		if g.Hash.Algo != "" && !slices.Contains(HashAlgos, g.Hash.Algo) {
+			return fmt.Errorf("watchman.groups[%s].hash.algo must be one of %v, got %s", g.Name, HashAlgos, g.Hash.Algo)
+		}
+		if g.Hash.MaxBytes < 0 {
+			return fmt.Errorf("watchman.groups[%s].hash.max-bytes must be >= 0", g.Name)
+		}
+		r := g.Retry
+		if r.MaxAttempts < 0 || r.BaseBackoffMs < 0 || r.MaxBackoffMs < 0 || r.BreakerThreshold < 0 || r.BreakerCooldownSec < 0 {
+			return fmt.Errorf("watchman.groups[%s].retry values must be >= 0", g.Name)
+		}
+		if r.Jitter < 0 || r.Jitter > 1 {
+			return fmt.Errorf("watchman.groups[%s].retry.jitter must be between 0 and 1", g.Name)
+		}
+		x := g.Exec
+		if g.Sink == "exec" && x.Command == "" {
+			return fmt.Errorf("watchman.groups[%s].exec.command cannot be empty with sink exec", g.Name)
+		}
+		if x.Concurrency < 0 || x.TimeoutMs < 0 || x.QueueSize < 0 || x.BatchSize < 0 || x.BatchIntervalMs < 0 {
+			return fmt.Errorf("watchman.groups[%s].exec values must be >= 0", g.Name)
+		}
+		for _, a := range x.Args {
+			if _, err := template.New(g.Name).Parse(a); err != nil {
+				return fmt.Errorf("watchman.groups[%s].exec.args: %w", g.Name, err)
+			}
+		}
+	}
 	return nil
 }
 
@@ -125,6 +905,104 @@ func (s *Settings) Save() error {
 	if err != nil {
 		return err
 	}
+	return writeConfig(data)
+}
+
+// SavePaths 仅替换配置文件中的 watchman.watcher.paths，其余配置与注释保持原样
+func SavePaths(paths []WatchPath) error {
+	data, err := readConfig()
+	if err != nil {
+		return err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		return errors.New("empty config document")
+	}
+	watcher := mappingChild(mappingChild(doc.Content[0], "watchman"), "watcher")
+	list := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+	for _, p := range paths {
+		var item yaml.Node
+		if err := item.Encode(p); err != nil {
+			return err
+		}
+		list.Content = append(list.Content, &item)
+	}
+	setMappingChild(watcher, "paths", list)
+	var out bytes.Buffer
+	enc := yaml.NewEncoder(&out)
+	enc.SetIndent(2)
+	if err := enc.Encode(&doc); err != nil {
+		return err
+	}
+	return writeConfig(out.Bytes())
+}
+
+// mappingChild 返回 mapping 节点中 key 对应的子 mapping，不存在时创建
+func mappingChild(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	child := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	setMappingChild(node, key, child)
+	return child
+}
+
+func setMappingChild(node *yaml.Node, key string, value *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			// 保留原节点上的注释
+			value.HeadComment = node.Content[i+1].HeadComment
+			value.LineComment = node.Content[i+1].LineComment
+			node.Content[i+1] = value
+			return
+		}
+	}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+}
+
+// MountNs 只解析配置中的 watchman.watcher.mount-ns，不做校验；其余配置需在加入命名空间后才能校验
+func MountNs() (string, error) {
+	data, err := readConfig()
+	if err != nil {
+		return "", err
+	}
+	var s Settings
+	if err := yaml.Unmarshal(data, &s); err != nil {
+		return "", err
+	}
+	return s.Watchman.Watcher.MountNs, nil
+}
+
+// ConfigPath 返回配置文件路径
+func ConfigPath() string {
+	return getConfigPath()
+}
+
+// readConfig 读取配置文件；加入其他挂载命名空间后读取父进程传入的宿主机配置文件
+func readConfig() ([]byte, error) {
+	if f := nsenter.ConfigFile(); f != nil {
+		fi, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(io.NewSectionReader(f, 0, fi.Size()))
+	}
+	return os.ReadFile(getConfigPath())
+}
+
+func writeConfig(data []byte) error {
+	if f := nsenter.ConfigFile(); f != nil {
+		if err := f.Truncate(0); err != nil {
+			return err
+		}
+		_, err := f.WriteAt(data, 0)
+		return err
+	}
 	return os.WriteFile(getConfigPath(), data, 0644)
 }
 
@@ -0,0 +1,89 @@
+package watcher
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/caoenergy/watchman/internal/clock"
+)
+
+// BatchListener 批量处理事件，batch 在调用返回后不再被复用，可直接保留
+type BatchListener func(batch []*EventInfo)
+
+// AddBatchListener 注册按时间窗口攒批的监听器：第一个事件到达后缓存 window，期间同一路径的多个事件只保留最后一个
+// (位置按最后一次出现)，窗口结束时整批调用 listener。批次在定时器 goroutine 中交付，同一监听器的批次按顺序、不并发；
+// Stop 时先交付未满窗口的批次(见 Flusher)。需按数量攒批时使用 listener.Buffered。
+func (wm *Watchman) AddBatchListener(identify string, listener BatchListener, window time.Duration) {
+	b := &batcher{identify: identify, inner: listener, window: window, wm: wm, index: make(map[string]int)}
+	wm.AddEventListener(identify, b.add)
+	wm.AddFlusher(b)
+}
+
+type batcher struct {
+	identify string
+	inner    BatchListener
+	window   time.Duration
+	wm       *Watchman
+
+	mu    sync.Mutex
+	batch []*EventInfo
+	index map[string]int // Path → 在 batch 中的位置
+	timer clock.Timer
+}
+
+func (b *batcher) add(info *EventInfo) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if i, ok := b.index[info.Path]; ok {
+		// 被覆盖的位置置空，交付时跳过，避免每次删除都移动切片
+		b.batch[i] = nil
+	}
+	b.index[info.Path] = len(b.batch)
+	b.batch = append(b.batch, info)
+	if b.timer == nil {
+		b.timer = b.wm.clock.AfterFunc(b.window, b.onTimer)
+	}
+}
+
+func (b *batcher) onTimer() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.timer = nil
+	b.flushLocked()
+}
+
+// Flush 实现 Flusher，立即交付当前批次
+func (b *batcher) Flush() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.flushLocked()
+	return nil
+}
+
+// flushLocked 持锁调用 inner，保证批次按顺序交付
+func (b *batcher) flushLocked() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.index) == 0 {
+		return
+	}
+	batch := make([]*EventInfo, 0, len(b.index))
+	for _, info := range b.batch {
+		if info != nil {
+			batch = append(batch, info)
+		}
+	}
+	b.batch = nil
+	clear(b.index)
+	// 定时器 goroutine 中没有 call 的 panic 保护
+	defer func() {
+		if r := recover(); r != nil {
+			b.wm.stats.recordListenerError(b.identify, nil, r, b.wm.clock.Now())
+			slog.Error("listener panicked", "listener", b.identify, "batch", len(batch), "panic", r)
+		}
+	}()
+	b.inner(batch)
+}
@@ -0,0 +1,157 @@
+package watcher
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caoenergy/watchman/internal/clock"
+)
+
+const (
+	EventBulkChange = "BULK_CHANGE"
+	// 同时统计的目录上限，超出后新目录不参与检测，避免大量目录同时活跃时内存无界增长
+	maxBulkDirs = 10000
+)
+
+// bulkDetector 检测批量操作(如 tar -x、rsync)：某个目录在一个窗口内的事件数达到阈值后进入批量模式，
+// 之后该目录的单个事件不再投递，改为每个窗口投递一个 BULK_CHANGE 汇总(含计数)；
+// 某个窗口内的事件数回落到阈值以下时投递最后一个汇总(Attrs.final 为 true)并退出批量模式。
+type bulkDetector struct {
+	threshold int
+	window    time.Duration
+	clock     clock.Clock
+	out       chan<- *EventInfo
+
+	mu   sync.Mutex
+	dirs map[string]*bulkDir
+}
+
+type bulkDir struct {
+	count int // 当前窗口内的事件数
+	bulk  bool
+	rule  string
+	since time.Time
+	total int            // 进入批量模式以来被汇总的事件数
+	types map[string]int // 当前窗口内被汇总事件的类型分布
+}
+
+func newBulkDetector(threshold int, window time.Duration, clk clock.Clock, out chan<- *EventInfo) *bulkDetector {
+	if threshold <= 0 {
+		return nil
+	}
+	return &bulkDetector{threshold: threshold, window: window, clock: clk, out: out, dirs: make(map[string]*bulkDir)}
+}
+
+// observe 统计事件所在目录，返回 true 表示该目录处于批量模式，事件已计入汇总，调用方不再投递
+func (b *bulkDetector) observe(info *EventInfo) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	d, ok := b.dirs[info.Dir]
+	if !ok {
+		if len(b.dirs) >= maxBulkDirs {
+			return false
+		}
+		d = &bulkDir{rule: info.MatchedRule}
+		b.dirs[info.Dir] = d
+	}
+	d.count++
+	if !d.bulk && d.count >= b.threshold {
+		d.bulk, d.since, d.total, d.types = true, b.clock.Now(), 0, make(map[string]int)
+		slog.Info("bulk operation detected, summarizing events", "dir", info.Dir, "threshold", b.threshold, "window", b.window)
+		// 触发阈值的事件本身照常投递，之后的事件汇总
+		return false
+	}
+	if !d.bulk {
+		return false
+	}
+	d.total++
+	for _, t := range strings.Split(info.Type, "|") {
+		d.types[t]++
+	}
+	return true
+}
+
+// run 每个窗口结束时为批量模式的目录投递汇总，并清理空闲目录
+func (b *bulkDetector) run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-b.clock.After(b.window):
+		}
+		for _, info := range b.roll() {
+			select {
+			case <-ctx.Done():
+				return
+			case b.out <- info:
+			}
+		}
+	}
+}
+
+func (b *bulkDetector) roll() []*EventInfo {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.clock.Now()
+	var summaries []*EventInfo
+	for dir, d := range b.dirs {
+		if d.bulk {
+			final := d.count < b.threshold
+			summaries = append(summaries, b.summary(dir, d, now, final))
+			d.types = make(map[string]int)
+			if final {
+				d.bulk = false
+				slog.Info("bulk operation subsided", "dir", dir, "events", d.total, "duration", now.Sub(d.since))
+			}
+		}
+		if d.count == 0 && !d.bulk {
+			delete(b.dirs, dir)
+			continue
+		}
+		d.count = 0
+	}
+	return summaries
+}
+
+func (b *bulkDetector) summary(dir string, d *bulkDir, now time.Time, final bool) *EventInfo {
+	count := 0
+	for _, n := range d.types {
+		count += n
+	}
+	return &EventInfo{
+		Type:  EventBulkChange,
+		Dir:   dir,
+		Path:  dir,
+		IsDir: true,
+		Time:  now,
+
+		MatchedRule: d.rule,
+		Attrs: map[string]any{
+			"synthetic": true,
+			"count":     count, // 本窗口内被汇总的事件数
+			"total":     d.total,
+			"by_type":   maps.Clone(d.types),
+			"since":     d.since,
+			"final":     final,
+		},
+	}
+}
+
+// active 返回当前处于批量模式的目录，已排序
+func (b *bulkDetector) active() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var dirs []string
+	for dir, d := range b.dirs {
+		if d.bulk {
+			dirs = append(dirs, dir)
+		}
+	}
+	slices.Sort(dirs)
+	return dirs
+}
@@ -0,0 +1,94 @@
+package watcher
+
+import (
+	"slices"
+	"time"
+
+	"github.com/caoenergy/watchman/internal/clock"
+)
+
+// debouncer 按 (路径, 事件类型) 合并连续事件：每个事件暂存 window，期间同一路径同类型的事件替换暂存的事件并重新计时，
+// 安静 window 后只投递最后一个；不同类型互不影响。byPath 时只按路径合并(cache.fp-mode 为 debounce)，投递最后一个类型。与 ephemeralFilter 一样 pending 只在事件循环协程中访问，
+// 定时器回调仅通过 release 通道把事件交回事件循环。
+type debouncer struct {
+	window  time.Duration
+	byPath  bool
+	clock   clock.Clock
+	pending map[debounceKey]*heldEvent
+	release chan *EventInfo
+	done    chan struct{}
+}
+
+type debounceKey struct {
+	path, eventType string
+}
+
+type heldEvent struct {
+	info  *EventInfo
+	timer clock.Timer
+}
+
+func newDebouncer(window time.Duration, byPath bool, clk clock.Clock) *debouncer {
+	if window <= 0 {
+		return nil
+	}
+	return &debouncer{
+		window:  window,
+		byPath:  byPath,
+		clock:   clk,
+		pending: make(map[debounceKey]*heldEvent),
+		release: make(chan *EventInfo, 1024),
+		done:    make(chan struct{}),
+	}
+}
+
+// hold 暂存事件，返回 true 表示替换了同键的暂存事件(即合并掉一个)
+func (d *debouncer) hold(info *EventInfo) bool {
+	key := d.key(info)
+	h, merged := d.pending[key]
+	if merged {
+		h.timer.Stop()
+	} else {
+		h = &heldEvent{}
+		d.pending[key] = h
+	}
+	h.info = info
+	h.timer = d.clock.AfterFunc(d.window, func() {
+		select {
+		case d.release <- info:
+		case <-d.done:
+		}
+	})
+	return merged
+}
+
+// expired 安静期结束，返回事件是否仍需投递(期间未被同键的新事件替换)
+func (d *debouncer) expired(info *EventInfo) bool {
+	key := d.key(info)
+	h, ok := d.pending[key]
+	if !ok || h.info != info {
+		return false
+	}
+	delete(d.pending, key)
+	return true
+}
+
+func (d *debouncer) key(info *EventInfo) debounceKey {
+	if d.byPath {
+		return debounceKey{path: info.Path}
+	}
+	return debounceKey{info.Path, info.Type}
+}
+
+// drain 事件循环退出时取出所有暂存的事件，由调用方直接投递
+func (d *debouncer) drain() []*EventInfo {
+	close(d.done)
+	held := make([]*EventInfo, 0, len(d.pending))
+	for key, h := range d.pending {
+		h.timer.Stop()
+		held = append(held, h.info)
+		delete(d.pending, key)
+	}
+	slices.SortFunc(held, func(a, b *EventInfo) int { return a.Time.Compare(b.Time) })
+	return held
+}
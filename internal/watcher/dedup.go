@@ -0,0 +1,120 @@
+package watcher
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// 去重键策略，决定哪些事件在 fp-ttl 内被视为同一个
+const (
+	KeyPath     = "path"      // 同一路径的任意事件合并
+	KeyPathType = "path+type" // 同一路径、同一事件类型才合并，如 CREATE 与随后的 CLOSE_WRITE 都会投递
+	KeyDir      = "dir"       // 同一目录下的所有事件合并，适合只关心"目录有变化"的消费方
+	KeyInode    = "inode"     // 按 dev:inode 合并，硬链接的不同路径视为同一文件；取自 FileStat(与大小过滤共用一次 lstat)，文件已删除时退化为 path
+)
+
+// 路径缓存的合并方式，见 cache.fp-mode
+const (
+	FpModeDedup    = "dedup"    // 首个事件立即投递，fp-ttl 内的重复丢弃
+	FpModeDebounce = "debounce" // 同一路径安静 fp-quiet-ms 后投递最后一个事件
+)
+
+const (
+	// 自适应窗口 = 平滑后的事件间隔 * adaptiveFactor，上限为 fp-ttl
+	adaptiveFactor = 2
+	// EWMA 平滑系数，越大越偏向最近一次间隔
+	adaptiveAlpha = 0.3
+)
+
+// pathState fpcManager 中每个路径的去重状态；自适应模式下记录事件节奏
+type pathState struct {
+	eventType     string
+	lastSeen      time.Time
+	lastDelivered time.Time
+	interval      float64      // 平滑后的事件间隔（纳秒）
+	window        atomic.Int64 // 当前抑制窗口（纳秒），Stats 并发读取
+}
+
+// dedupKey 按配置的策略计算事件在 fpcManager 中的键
+func (wm *Watchman) dedupKey(info *EventInfo) string {
+	switch wm.dedupKeyMode {
+	case KeyPathType:
+		return info.Path + "\x00" + info.Type
+	case KeyDir:
+		return info.Dir
+	case KeyInode:
+		if st := info.FileStat(); st != nil {
+			return fmt.Sprintf("inode:%d:%d", st.Dev, st.Ino)
+		}
+	}
+	return info.Path
+}
+
+// duplicate 判断事件是否应被去重，并更新路径状态。
+// 固定模式：fp-ttl 内同一路径只投递一次。
+// 自适应模式：根据该路径最近的事件间隔推算抑制窗口，频繁变化的文件窗口随节奏增大，
+// 间隔超过 fp-ttl 的文件窗口为零，每次修改都会投递；只跟踪仍在 fpcManager 中的键，内存有界。
+// key 由 dedupKey 按策略生成。
+func (wm *Watchman) duplicate(key, eventType string, now time.Time) bool {
+	st, ok := wm.fpcManager.Get(key)
+	if ok {
+		wm.stats.dedupHits.Add(1)
+	} else {
+		wm.stats.dedupMisses.Add(1)
+	}
+	if !wm.adaptiveDedup {
+		if ok {
+			return true
+		}
+		wm.fpcManager.Add(key, &pathState{eventType: eventType, lastSeen: now, lastDelivered: now})
+		return false
+	}
+	if !ok {
+		wm.fpcManager.Add(key, &pathState{eventType: eventType, lastSeen: now, lastDelivered: now})
+		return false
+	}
+	gap := float64(now.Sub(st.lastSeen))
+	if st.interval == 0 {
+		st.interval = gap
+	} else {
+		st.interval = adaptiveAlpha*gap + (1-adaptiveAlpha)*st.interval
+	}
+	st.lastSeen = now
+	window := time.Duration(st.interval * adaptiveFactor)
+	if window > wm.fpTtl {
+		window = 0
+	}
+	st.window.Store(int64(window))
+	// 重新 Add 以刷新 TTL，使持续活跃的路径保留其节奏数据
+	wm.fpcManager.Add(key, st)
+	if now.Sub(st.lastDelivered) < window {
+		return true
+	}
+	st.lastDelivered = now
+	st.eventType = eventType
+	return false
+}
+
+// adaptiveWindows 按区间统计当前各路径的自适应抑制窗口
+func (wm *Watchman) adaptiveWindows() map[string]int {
+	buckets := map[string]int{}
+	for _, st := range wm.fpcManager.Values() {
+		w := time.Duration(st.window.Load())
+		switch {
+		case w == 0:
+			buckets["0"]++
+		case w < 10*time.Millisecond:
+			buckets["<10ms"]++
+		case w < 100*time.Millisecond:
+			buckets["<100ms"]++
+		case w < time.Second:
+			buckets["<1s"]++
+		case w < 10*time.Second:
+			buckets["<10s"]++
+		default:
+			buckets[">=10s"]++
+		}
+	}
+	return buckets
+}
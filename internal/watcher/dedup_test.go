@@ -0,0 +1,69 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2/expirable"
+)
+
+func newDedupWatchman(mode string) *Watchman {
+	return &Watchman{
+		dedupKeyMode: mode,
+		fpcManager:   lru.NewLRU[string, *pathState](100, nil, time.Minute),
+		fpTtl:        time.Minute,
+		stats:        newStats(),
+	}
+}
+
+func TestDedupKeyStrategies(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	other := filepath.Join(t.TempDir(), "a")
+	writeFile(t, a, "x")
+	if err := os.Link(a, b); err != nil {
+		t.Fatal(err)
+	}
+	event := func(typ, path string) *EventInfo {
+		return &EventInfo{Type: typ, Path: path, Dir: filepath.Dir(path), Name: filepath.Base(path)}
+	}
+	tests := []struct {
+		mode   string
+		events []*EventInfo
+		want   []bool // 各事件是否被去重
+	}{
+		{KeyPath, []*EventInfo{event("CREATE", a), event("CLOSE_WRITE", a), event("CLOSE_WRITE", b)},
+			[]bool{false, true, false}},
+		{KeyPathType, []*EventInfo{event("CREATE", a), event("CLOSE_WRITE", a), event("CLOSE_WRITE", a)},
+			[]bool{false, false, true}},
+		{KeyDir, []*EventInfo{event("CLOSE_WRITE", a), event("CREATE", b), event("CLOSE_WRITE", other)},
+			[]bool{false, true, false}},
+		// 硬链接 a、b 为同一 inode；不存在的文件退化为按路径
+		{KeyInode, []*EventInfo{event("CLOSE_WRITE", a), event("CLOSE_WRITE", b), event("DELETE", other), event("DELETE", other)},
+			[]bool{false, true, false, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			wm := newDedupWatchman(tt.mode)
+			now := time.Now()
+			for i, info := range tt.events {
+				if got := wm.duplicate(wm.dedupKey(info), info.Type, now); got != tt.want[i] {
+					t.Errorf("event %d (%s %s): duplicate = %v, want %v", i, info.Type, info.Path, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// path-translation 开启时 inode 键按改写前的路径读取
+func TestDedupKeyInodeUsesHostPath(t *testing.T) {
+	host := filepath.Join(t.TempDir(), "f")
+	writeFile(t, host, "x")
+	wm := newDedupWatchman(KeyInode)
+	info := &EventInfo{Type: "CLOSE_WRITE", Path: "/translated/f", origPath: host}
+	if key := wm.dedupKey(info); key == info.Path {
+		t.Fatalf("dedupKey fell back to the translated path %q", key)
+	}
+}
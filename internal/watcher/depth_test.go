@@ -0,0 +1,57 @@
+package watcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestRelativeDepth(t *testing.T) {
+	tests := []struct {
+		path, rule string
+		want       int
+	}{
+		{"/data", "/data", 0},
+		{"/data/a", "/data", 1},
+		{"/data/a/b", "/data/", 2},
+		{"/data/x/in/a/b", "/data/x/in", 2}, // 通配规则以实际命中的目录为基准，见 ruleBase
+		{"/data/logs/a/b/c", "/data/logs/**/*.log", 3},
+		{"/a", "/", 1},
+	}
+	for _, tt := range tests {
+		if got := relativeDepth(tt.path, tt.rule); got != tt.want {
+			t.Errorf("relativeDepth(%q, %q) = %d, want %d", tt.path, tt.rule, got, tt.want)
+		}
+	}
+}
+
+// 层级恰为 max-relative-depth 的事件上报，超出一层的丢弃；0 表示不限制
+func TestMaxRelativeDepth(t *testing.T) {
+	for _, tt := range []struct {
+		max      int
+		deepSeen bool
+	}{{0, true}, {2, false}, {3, true}} {
+		t.Run(fmt.Sprintf("max=%d", tt.max), func(t *testing.T) {
+			root := t.TempDir()
+			wm := newTestWatchman(t, fmt.Sprintf("paths: [%s]\nevents: [CLOSE_WRITE]\nmax-relative-depth: %d", root, tt.max))
+			sink := runTestWatchman(t, wm)
+			mkdirAll(t, filepath.Join(root, "a", "b"))
+			atMax := filepath.Join(root, "a", "f")     // 层级 2
+			deep := filepath.Join(root, "a", "b", "f") // 层级 3
+			writeFile(t, deep, "x")
+			writeFile(t, atMax, "x")
+			sink.wait(t, func(info *EventInfo) bool { return info.Path == atMax })
+			if tt.deepSeen {
+				sink.wait(t, func(info *EventInfo) bool { return info.Path == deep })
+				return
+			}
+			// deep 先于 atMax 写入，atMax 已投递时 deep 的事件已处理完
+			if sink.find(func(info *EventInfo) bool { return info.Path == deep }) != nil {
+				t.Errorf("event at depth 3 delivered with max-relative-depth %d", tt.max)
+			}
+			if wm.stats.filtered.Load() == 0 {
+				t.Error("dropped event not counted as filtered")
+			}
+		})
+	}
+}
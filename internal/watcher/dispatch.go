@@ -0,0 +1,54 @@
+package watcher
+
+import (
+	"hash/fnv"
+	"sync"
+)
+
+// shardedDispatcher 将事件按完整路径(或按 dispatch.shard-by 为所在目录)哈希分配到固定的 worker，
+// 同一路径的事件总由同一 worker 按到达顺序投递。这是去重、防抖、CREATE→CLOSE_WRITE 合并等依赖事件顺序的功能的前提：
+// 跨路径不保证顺序，同路径严格有序；按目录分片时同一目录内的事件也保持顺序。
+type shardedDispatcher struct {
+	shards []chan *EventInfo
+	byDir  bool
+	wg     sync.WaitGroup
+}
+
+func newShardedDispatcher(workers, queueSize int, byDir bool, deliver func(*EventInfo)) *shardedDispatcher {
+	d := &shardedDispatcher{shards: make([]chan *EventInfo, workers), byDir: byDir}
+	for i := range d.shards {
+		ch := make(chan *EventInfo, queueSize)
+		d.shards[i] = ch
+		d.wg.Add(1)
+		go func() {
+			defer d.wg.Done()
+			for info := range ch {
+				deliver(info)
+			}
+		}()
+	}
+	return d
+}
+
+// submit 队列满时阻塞，以背压代替丢弃
+func (d *shardedDispatcher) submit(info *EventInfo) {
+	key := info.Path
+	if d.byDir {
+		key = info.Dir
+	}
+	d.shards[shardOf(key, len(d.shards))] <- info
+}
+
+// close 关闭所有分片并等待 worker 投递完已排队的事件
+func (d *shardedDispatcher) close() {
+	for _, ch := range d.shards {
+		close(ch)
+	}
+	d.wg.Wait()
+}
+
+func shardOf(key string, n int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(n))
+}
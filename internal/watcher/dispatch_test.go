@@ -0,0 +1,70 @@
+package watcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// 多个 worker 并发投递时，同一路径(或按目录分片时同一目录)的事件保持提交顺序；配合 -race 运行
+func TestShardedDispatcherOrder(t *testing.T) {
+	const dirs, files, rounds = 8, 16, 200
+	for _, byDir := range []bool{false, true} {
+		t.Run(fmt.Sprintf("byDir=%v", byDir), func(t *testing.T) {
+			wm := &Watchman{stats: newStats()}
+			var mu sync.Mutex
+			got := make(map[string][]int)
+			wm.AddEventListener("order", func(info *EventInfo) {
+				seq, _ := info.Attr("seq")
+				key := info.Path
+				if byDir {
+					key = info.Dir
+				}
+				mu.Lock()
+				got[key] = append(got[key], seq.(int))
+				mu.Unlock()
+			})
+			// 后续监听器读写前一个监听器写入的 Attrs
+			wm.AddEventListener("attrs", func(info *EventInfo) {
+				v, _ := info.Attr("seq")
+				info.SetAttr("seen", v)
+			})
+			wm.dispatcher = newShardedDispatcher(8, 4, byDir, wm.deliver)
+
+			next := make(map[string]int)
+			for r := range rounds {
+				for d := range dirs {
+					for f := range files {
+						dir := fmt.Sprintf("/root/d%d", d)
+						info := &EventInfo{Type: "CLOSE_WRITE", Dir: dir, Name: fmt.Sprint(f), Path: filepath.Join(dir, fmt.Sprint(f))}
+						key := info.Path
+						if byDir {
+							key = dir
+						}
+						info.SetAttr("seq", next[key])
+						next[key]++
+						wm.dispatcher.submit(info)
+					}
+				}
+				if r == rounds/2 {
+					// 投递过程中注册监听器不影响顺序
+					wm.AddEventListener("late", func(*EventInfo) {})
+				}
+			}
+			wm.dispatcher.close()
+
+			for key, n := range next {
+				seqs := got[key]
+				if len(seqs) != n {
+					t.Fatalf("%s: delivered %d events, want %d", key, len(seqs), n)
+				}
+				for i, s := range seqs {
+					if s != i {
+						t.Fatalf("%s: event %d delivered at position %d", key, s, i)
+					}
+				}
+			}
+		})
+	}
+}
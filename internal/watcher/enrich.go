@@ -0,0 +1,95 @@
+package watcher
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/armon/go-radix"
+	"gopkg.in/yaml.v3"
+)
+
+// enrichReloadInterval 映射文件变更检查间隔
+const enrichReloadInterval = 5 * time.Second
+
+// enrichRule 映射文件中的一条规则：路径位于 prefix 之下的事件附加 attrs
+type enrichRule struct {
+	Prefix string            `yaml:"prefix"`
+	Attrs  map[string]string `yaml:"attrs"`
+}
+
+// enricher 按路径前缀向事件写入 Attrs。多个前缀命中时从短到长依次写入，同名键以最长前缀为准。
+// 映射文件修改时间变化后自动重新加载，加载失败保留旧映射。
+type enricher struct {
+	path    string
+	tree    atomic.Pointer[radix.Tree]
+	modTime time.Time
+}
+
+func newEnricher(path string) (*enricher, error) {
+	e := &enricher{path: path}
+	if _, err := e.load(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// load 文件未变化时返回 false
+func (e *enricher) load() (bool, error) {
+	fi, err := os.Stat(e.path)
+	if err != nil {
+		return false, err
+	}
+	if fi.ModTime().Equal(e.modTime) {
+		return false, nil
+	}
+	data, err := os.ReadFile(e.path)
+	if err != nil {
+		return false, err
+	}
+	var rules []enrichRule
+	if err := yaml.Unmarshal(data, &rules); err != nil {
+		return false, fmt.Errorf("enrich file %s: %w", e.path, err)
+	}
+	tree := radix.New()
+	for i, r := range rules {
+		if r.Prefix == "" {
+			return false, fmt.Errorf("enrich file %s: rule %d has empty prefix", e.path, i)
+		}
+		tree.Insert(filepath.Clean(r.Prefix), r.Attrs)
+	}
+	e.tree.Store(tree)
+	e.modTime = fi.ModTime()
+	return true, nil
+}
+
+func (e *enricher) apply(info *EventInfo) {
+	e.tree.Load().WalkPath(info.Path, func(prefix string, v interface{}) bool {
+		if isUnder(info.Path, prefix) {
+			for k, val := range v.(map[string]string) {
+				info.SetAttr(k, val)
+			}
+		}
+		return false
+	})
+}
+
+func (e *enricher) watch(ctx context.Context, after func(time.Duration) <-chan time.Time) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-after(enrichReloadInterval):
+			changed, err := e.load()
+			if err != nil {
+				slog.Warn("reload enrich file failed, keeping previous mapping", "file", e.path, "err", err)
+			} else if changed {
+				slog.Info("enrich file reloaded", "file", e.path)
+			}
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package watcher
+
+import (
+	"time"
+
+	"github.com/caoenergy/watchman/internal/clock"
+)
+
+// ephemeralFilter 合并临时文件的 CREATE+DELETE：CREATE 先暂存 window，期间同一路径出现 DELETE 则两者都不投递，
+// 否则窗口到期后正常投递 CREATE。代价是所有 CREATE 都会延迟 window。
+// pending 只在事件循环协程中访问，定时器回调仅通过 release 通道把事件交回事件循环。
+type ephemeralFilter struct {
+	window  time.Duration
+	clock   clock.Clock
+	pending map[string]*heldCreate
+	release chan *EventInfo
+	done    chan struct{}
+}
+
+type heldCreate struct {
+	info  *EventInfo
+	timer clock.Timer
+}
+
+func newEphemeralFilter(window time.Duration, clk clock.Clock) *ephemeralFilter {
+	return &ephemeralFilter{
+		window:  window,
+		clock:   clk,
+		pending: make(map[string]*heldCreate),
+		release: make(chan *EventInfo, 1024),
+		done:    make(chan struct{}),
+	}
+}
+
+// hold 处理一个事件，返回 true 表示事件已被暂存或吞掉，调用方不应继续投递
+func (f *ephemeralFilter) hold(info *EventInfo) bool {
+	if info.Type == "CREATE" {
+		if _, ok := f.pending[info.Path]; ok {
+			return true
+		}
+		h := &heldCreate{info: info}
+		h.timer = f.clock.AfterFunc(f.window, func() {
+			select {
+			case f.release <- info:
+			case <-f.done:
+			}
+		})
+		f.pending[info.Path] = h
+		return true
+	}
+	if h, ok := f.pending[info.Path]; ok && (info.Type == "DELETE" || info.Type == "DELETE_SELF") {
+		h.timer.Stop()
+		delete(f.pending, info.Path)
+		return true
+	}
+	return false
+}
+
+// expired 窗口到期，返回 CREATE 是否仍需投递（期间未被 DELETE 取消）
+func (f *ephemeralFilter) expired(info *EventInfo) bool {
+	h, ok := f.pending[info.Path]
+	if !ok || h.info != info {
+		return false
+	}
+	delete(f.pending, info.Path)
+	return true
+}
+
+// drain 事件循环退出时取出所有暂存的 CREATE，由调用方直接投递
+func (f *ephemeralFilter) drain() []*EventInfo {
+	close(f.done)
+	held := make([]*EventInfo, 0, len(f.pending))
+	for path, h := range f.pending {
+		h.timer.Stop()
+		held = append(held, h.info)
+		delete(f.pending, path)
+	}
+	return held
+}
@@ -0,0 +1,62 @@
+package watcher
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// 排除前缀与监控路径交替嵌套时，以最具体(最长)的一方为准；文件名规则任意层级生效
+func TestExcludePrecedence(t *testing.T) {
+	root := t.TempDir()
+	keep, again := filepath.Join(root, "tmp", "keep"), filepath.Join(root, "tmp", "keep", "junk", "again")
+	junk := filepath.Join(keep, "junk")
+	mkdirAll(t, again)
+	wm := newTestWatchman(t, "paths: ["+root+", "+keep+", "+again+"]\nevents: [CLOSE_WRITE]\n"+
+		"exclude: ["+filepath.Join(root, "tmp")+", "+junk+", \"*.swp\"]")
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{filepath.Join(root, "a"), true},
+		{filepath.Join(root, "tmp"), false},
+		{filepath.Join(root, "tmp", "x"), false},
+		{filepath.Join(root, "tmpfile"), true}, // 按路径段匹配
+		{keep, true},
+		{filepath.Join(keep, "x"), true},
+		{junk, false},
+		{filepath.Join(junk, "x"), false},
+		{filepath.Join(again, "x"), true},
+		{filepath.Join(root, "a.swp"), false},
+		{filepath.Join(again, "x.swp"), false},
+	}
+	check := func() {
+		t.Helper()
+		for _, tt := range tests {
+			if _, got := wm.matchPath(tt.path, filepath.Base(tt.path), false); got != tt.want {
+				t.Errorf("matchPath(%s) = %v, want %v", tt.path, got, tt.want)
+			}
+		}
+	}
+	check()
+
+	// reload 后按新规则重新判断
+	if err := wm.ReloadExclude([]string{junk}); err != nil {
+		t.Fatal(err)
+	}
+	for i, tt := range tests {
+		switch tt.path {
+		case filepath.Join(root, "tmp"), filepath.Join(root, "tmp", "x"), filepath.Join(root, "a.swp"), filepath.Join(again, "x.swp"):
+			tests[i].want = true
+		}
+	}
+	check()
+
+	// 实际事件经过同样的判断
+	sink := runTestWatchman(t, wm)
+	writeFile(t, filepath.Join(junk, "dropped"), "x")
+	writeFile(t, filepath.Join(again, "kept"), "x")
+	sink.wait(t, func(info *EventInfo) bool { return info.Name == "kept" })
+	if sink.find(func(info *EventInfo) bool { return info.Name == "dropped" }) != nil {
+		t.Error("event under an excluded prefix delivered")
+	}
+}
@@ -0,0 +1,37 @@
+package watcher
+
+import (
+	"log/slog"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/vm"
+)
+
+// exprFilter 基于 expr 表达式的事件过滤器。表达式在启动时编译一次，运行时只读取 EventInfo 字段，
+// 环境中不暴露任何函数，因此表达式无法进行 I/O。每个事件都要求值一次，属于可选的高成本过滤。
+// 事件循环与目录扫描等协程会并发调用 match，program 只读，每次求值使用独立的 VM。
+type exprFilter struct {
+	program *vm.Program
+}
+
+func newExprFilter(source string) (*exprFilter, error) {
+	if source == "" {
+		return nil, nil
+	}
+	program, err := expr.Compile(source, expr.Env(EventInfo{}), expr.AsBool())
+	if err != nil {
+		return nil, err
+	}
+	return &exprFilter{program: program}, nil
+}
+
+// match 返回事件是否应继续投递；求值出错时按不匹配处理。
+func (f *exprFilter) match(info *EventInfo) bool {
+	out, err := expr.Run(f.program, info)
+	if err != nil {
+		slog.Warn("filter expr eval failed", "path", info.Path, "err", err)
+		return false
+	}
+	matched, _ := out.(bool)
+	return matched
+}
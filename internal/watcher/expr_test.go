@@ -0,0 +1,59 @@
+package watcher
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestExprFilter(t *testing.T) {
+	f, err := newExprFilter(`Type == "CLOSE_WRITE" && !IsDir && Name endsWith ".log"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		info *EventInfo
+		want bool
+	}{
+		{&EventInfo{Type: "CLOSE_WRITE", Name: "a.log"}, true},
+		{&EventInfo{Type: "CREATE", Name: "a.log"}, false},
+		{&EventInfo{Type: "CLOSE_WRITE", Name: "a.txt"}, false},
+		{&EventInfo{Type: "CLOSE_WRITE", Name: "d.log", IsDir: true}, false},
+	}
+	for _, tt := range tests {
+		if got := f.match(tt.info); got != tt.want {
+			t.Errorf("match(%s %s dir=%v) = %v, want %v", tt.info.Type, tt.info.Name, tt.info.IsDir, got, tt.want)
+		}
+	}
+	if _, err := newExprFilter(`Path +`); err == nil {
+		t.Error("invalid expression compiled")
+	}
+	if f, err := newExprFilter(""); f != nil || err != nil {
+		t.Errorf("empty expression = %v, %v", f, err)
+	}
+}
+
+// 事件循环与扫描协程并发求值，配合 -race 运行
+func TestExprFilterConcurrent(t *testing.T) {
+	f, err := newExprFilter(`Name endsWith ".log"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for g := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 1000 {
+				name := "a.txt"
+				if (g+i)%2 == 0 {
+					name = "a.log"
+				}
+				if got, want := f.match(&EventInfo{Name: name}), name == "a.log"; got != want {
+					t.Errorf("match(%s) = %v, want %v", name, got, want)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
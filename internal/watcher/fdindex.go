@@ -0,0 +1,92 @@
+package watcher
+
+import (
+	"path/filepath"
+	"sync"
+
+	"github.com/armon/go-radix"
+	"golang.org/x/sys/unix"
+)
+
+// fdPathIndex 按解析出的路径索引 fdcManager 的键。目录被移动后其下对象的 handle 不变，缓存的却还是旧路径，
+// 需要按前缀找出这些键并失效。条目随 fdcManager 的淘汰回调删除。
+type fdPathIndex struct {
+	mu   sync.Mutex
+	tree *radix.Tree // 路径 -> map[string]struct{}(缓存键)
+}
+
+func newFdPathIndex() *fdPathIndex {
+	return &fdPathIndex{tree: radix.New()}
+}
+
+func (x *fdPathIndex) add(key, path string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if v, ok := x.tree.Get(path); ok {
+		v.(map[string]struct{})[key] = struct{}{}
+		return
+	}
+	x.tree.Insert(path, map[string]struct{}{key: {}})
+}
+
+// remove 作为 fdcManager 的淘汰回调
+func (x *fdPathIndex) remove(key, path string) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	v, ok := x.tree.Get(path)
+	if !ok {
+		return
+	}
+	keys := v.(map[string]struct{})
+	delete(keys, key)
+	if len(keys) == 0 {
+		x.tree.Delete(path)
+	}
+}
+
+// under 返回 dir 自身及其下路径的缓存键
+func (x *fdPathIndex) under(dir string) []string {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	var keys []string
+	collect := func(_ string, v any) bool {
+		for k := range v.(map[string]struct{}) {
+			keys = append(keys, k)
+		}
+		return false
+	}
+	if v, ok := x.tree.Get(dir); ok {
+		collect(dir, v)
+	}
+	prefix := dir + "/"
+	if dir == "/" {
+		prefix = dir
+	}
+	x.tree.WalkPrefix(prefix, collect)
+	return keys
+}
+
+// invalidateMovedDir 目录被移动时(由 resolveBatch 调用)失效缓存中以其原路径为前缀的条目；只订阅了 MOVED_TO 时不知道原路径，清空整个缓存
+func (wm *Watchman) invalidateMovedDir(event *Event, fullPath string) {
+	var oldPath string
+	switch {
+	case event.Mask&unix.FAN_MOVED_FROM != 0:
+		oldPath = fullPath
+	case event.Mask&unix.FAN_RENAME != 0:
+		dir, name, ok := wm.resolve(event.OldHandle)
+		if !ok || name == "" {
+			wm.fdcManager.Purge()
+			return
+		}
+		oldPath = filepath.Join(dir, name)
+	case event.Mask&unix.FAN_MOVED_TO != 0 && wm.markEvents&(unix.FAN_MOVED_FROM|unix.FAN_RENAME) == 0:
+		wm.fdcManager.Purge()
+		return
+	default:
+		return
+	}
+	// Remove 触发淘汰回调，须在 under 释放锁之后调用
+	for _, key := range wm.fdIndex.under(oldPath) {
+		wm.fdcManager.Remove(key)
+	}
+}
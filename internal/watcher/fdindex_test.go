@@ -0,0 +1,83 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// 目录 old 重命名为 new，同一批次中紧随其后的子文件事件应解析为新路径
+func TestResolveBatchInvalidatesMovedDir(t *testing.T) {
+	root := t.TempDir()
+	wm := newTestWatchman(t, "paths: ["+root+"]\nevents: [CREATE, CLOSE_WRITE, MOVED_FROM, MOVED_TO]\nresolve-workers: 4")
+	old, renamed := filepath.Join(root, "old"), filepath.Join(root, "new")
+	mkdirAll(t, old)
+	writeFile(t, filepath.Join(old, "child"), "a")
+	child := dfidName(t, unix.FAN_EVENT_INFO_TYPE_DFID_NAME, old, "child")
+	// 重命名前的事件将 old 的 handle 以旧路径缓存
+	if dir, _, ok := wm.resolve(child); !ok || dir != old {
+		t.Fatalf("resolve before rename = %q, %v", dir, ok)
+	}
+	if err := os.Rename(old, renamed); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(renamed, "child"), "b")
+
+	batch := []Event{
+		{Mask: unix.FAN_MOVED_FROM | unix.FAN_ONDIR, IsDir: true, Pidfd: -1,
+			Handle: dfidName(t, unix.FAN_EVENT_INFO_TYPE_DFID_NAME, root, "old")},
+		{Mask: unix.FAN_MOVED_TO | unix.FAN_ONDIR, IsDir: true, Pidfd: -1,
+			Handle: dfidName(t, unix.FAN_EVENT_INFO_TYPE_DFID_NAME, root, "new")},
+		{Mask: unix.FAN_CLOSE_WRITE, Pidfd: -1, Handle: child},
+		{Mask: unix.FAN_CLOSE_WRITE, Pidfd: -1, Handle: child},
+	}
+	wm.resolveBatch(batch)
+	for i, e := range batch[2:] {
+		if r := e.resolved; r == nil || !r.ok || filepath.Join(r.dir, r.name) != filepath.Join(renamed, "child") {
+			t.Fatalf("event %d resolved to %+v, want %s", i+2, r, filepath.Join(renamed, "child"))
+		}
+	}
+}
+
+// 移动事件所在批次之后的批次同样在 capture 阶段解析，不能读到旧路径
+func TestResolveBatchAfterMoveBatch(t *testing.T) {
+	root := t.TempDir()
+	wm := newTestWatchman(t, "paths: ["+root+"]\nevents: [CLOSE_WRITE, MOVED_FROM, MOVED_TO]\nresolve-workers: 4")
+	old, renamed := filepath.Join(root, "old"), filepath.Join(root, "new")
+	mkdirAll(t, filepath.Join(old, "sub"))
+	child := dfidName(t, unix.FAN_EVENT_INFO_TYPE_DFID_NAME, filepath.Join(old, "sub"), "child")
+	wm.resolve(child)
+	if err := os.Rename(old, renamed); err != nil {
+		t.Fatal(err)
+	}
+
+	wm.resolveBatch([]Event{{Mask: unix.FAN_MOVED_FROM | unix.FAN_ONDIR, IsDir: true, Pidfd: -1,
+		Handle: dfidName(t, unix.FAN_EVENT_INFO_TYPE_DFID_NAME, root, "old")}})
+	next := []Event{{Mask: unix.FAN_CLOSE_WRITE, Pidfd: -1, Handle: child}, {Mask: unix.FAN_CLOSE_WRITE, Pidfd: -1, Handle: child}}
+	wm.resolveBatch(next)
+	want := filepath.Join(renamed, "sub")
+	for i, e := range next {
+		if e.resolved == nil || e.resolved.dir != want {
+			t.Fatalf("event %d resolved to %+v, want dir %s", i, e.resolved, want)
+		}
+	}
+}
+
+func TestDirRenameDeliversNewChildPath(t *testing.T) {
+	root := t.TempDir()
+	old, renamed := filepath.Join(root, "old"), filepath.Join(root, "new")
+	mkdirAll(t, old)
+	wm := newTestWatchman(t, "paths: ["+root+"]\nevents: [CLOSE_WRITE, MOVED_FROM, MOVED_TO]\nresolve-workers: 4")
+	sink := runTestWatchman(t, wm)
+	writeFile(t, filepath.Join(old, "before"), "a")
+	sink.wait(t, func(info *EventInfo) bool { return info.Path == filepath.Join(old, "before") })
+	if err := os.Rename(old, renamed); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(renamed, "after"), "b")
+	sink.wait(t, func(info *EventInfo) bool {
+		return info.Type == "CLOSE_WRITE" && info.Path == filepath.Join(renamed, "after")
+	})
+}
@@ -0,0 +1,52 @@
+package watcher
+
+import (
+	"encoding/binary"
+
+	"golang.org/x/sys/unix"
+)
+
+// fsTypeFilter 按文件系统类型丢弃事件。FAN_MARK_FILESYSTEM 下 proc、tmpfs、overlay 等文件系统的事件
+// 同样会进入队列，类型名比 fsid 稳定，跨重启无需修改配置。
+// fsid 首次出现时对解析出的目录做一次 statfs 并缓存类型，之后同一 fsid 的事件在解析 handle 之前即可丢弃。
+// 只在事件循环中使用，无需加锁。
+type fsTypeFilter struct {
+	excluded map[int64]bool
+	byFsid   map[uint64]bool // fsid → 是否排除
+}
+
+func newFsTypeFilter(magics []int64) *fsTypeFilter {
+	if len(magics) == 0 {
+		return nil
+	}
+	f := &fsTypeFilter{excluded: make(map[int64]bool), byFsid: make(map[uint64]bool)}
+	for _, m := range magics {
+		f.excluded[m] = true
+	}
+	return f
+}
+
+// fsidOf 取 FID 类 info 记录头部之后的 fsid
+func fsidOf(handle []byte) uint64 {
+	return binary.LittleEndian.Uint64(handle[4:EventInfoFidLen])
+}
+
+// lookup 返回该 fsid 是否已知以及是否应排除
+func (f *fsTypeFilter) lookup(handle []byte) (known, excluded bool) {
+	if len(handle) < EventInfoFidLen {
+		return true, false
+	}
+	excluded, known = f.byFsid[fsidOf(handle)]
+	return known, excluded
+}
+
+// learn 对事件所在目录 statfs 并缓存该 fsid 的结果；statfs 失败时不缓存，下次重试
+func (f *fsTypeFilter) learn(handle []byte, dir string) bool {
+	var st unix.Statfs_t
+	if err := unix.Statfs(dir, &st); err != nil {
+		return false
+	}
+	excluded := f.excluded[int64(st.Type)]
+	f.byFsid[fsidOf(handle)] = excluded
+	return excluded
+}
@@ -0,0 +1,113 @@
+package watcher
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// globMatcher 匹配含通配符的监控路径：'*' 等 filepath.Match 语法匹配单个路径段，'**' 匹配任意多段。
+// 与 radix 前缀语义保持一致，模式匹配完路径的前若干段即视为命中，如 /data/*/incoming 命中 /data/a/incoming/x.txt。
+type globMatcher struct {
+	sources  []string // 配置中的原始模式
+	patterns [][]string
+}
+
+// isGlob 判断配置路径是否含通配符
+func isGlob(p string) bool {
+	return strings.ContainsAny(p, "*?[")
+}
+
+func newGlobMatcher(patterns []string) *globMatcher {
+	g := &globMatcher{}
+	for _, p := range patterns {
+		g.sources = append(g.sources, p)
+		g.patterns = append(g.patterns, splitSegments(p))
+	}
+	return g
+}
+
+// match 返回命中的原始模式
+func (g *globMatcher) match(path string) (string, bool) {
+	if len(g.patterns) == 0 {
+		return "", false
+	}
+	segs := splitSegments(path)
+	for i, p := range g.patterns {
+		if matchSegments(p, segs) {
+			return g.sources[i], true
+		}
+	}
+	return "", false
+}
+
+func matchSegments(pattern, segs []string) bool {
+	if len(pattern) == 0 {
+		return true
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segs); i++ {
+			if matchSegments(pattern[1:], segs[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segs) == 0 {
+		return false
+	}
+	if ok, _ := filepath.Match(pattern[0], segs[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], segs[1:])
+}
+
+func splitSegments(p string) []string {
+	return strings.Split(strings.Trim(p, "/"), "/")
+}
+
+// relativeDepth 返回 path 相对于监控规则 rule 的层级，rule 的直接子项为 1。
+// 规则含 '**' 时深度不确定，从 '**' 之前的部分起算。
+func relativeDepth(path, rule string) int {
+	if i := strings.Index(rule, "**"); i >= 0 {
+		rule = rule[:i]
+	}
+	return segmentCount(path) - segmentCount(rule)
+}
+
+// staticPrefix 返回通配规则中第一个通配段之前的目录，如 /data/*/in → /data
+func staticPrefix(rule string) string {
+	segs := splitSegments(rule)
+	for i, seg := range segs {
+		if isGlob(seg) {
+			return "/" + strings.Join(segs[:i], "/")
+		}
+	}
+	return rule
+}
+
+func segmentCount(p string) int {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return 0
+	}
+	return strings.Count(p, "/") + 1
+}
+
+// relativeTo 将 path 拆为命中规则对应的实际根目录与其下的相对路径，path 与根目录相同时相对路径为 "."。
+// 通配规则的根目录为 path 中与规则段数相同的前缀，如 /data/*/in 命中 /data/a/in/x → (/data/a/in, x)；
+// 含 '**' 时以 '**' 之前的部分为根。
+func relativeTo(path, rule string) (root, rel string) {
+	if i := strings.Index(rule, "**"); i >= 0 {
+		rule = rule[:i]
+	}
+	n := segmentCount(rule)
+	segs := strings.Split(strings.Trim(path, "/"), "/")
+	if n > len(segs) {
+		n = len(segs)
+	}
+	root = "/" + strings.Join(segs[:n], "/")
+	if n == len(segs) {
+		return root, "."
+	}
+	return root, strings.Join(segs[n:], "/")
+}
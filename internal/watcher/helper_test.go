@@ -0,0 +1,172 @@
+package watcher
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/caoenergy/watchman/internal/settings"
+	"golang.org/x/sys/unix"
+)
+
+// testSettings 以 watchman.yml 的 watcher 段(不含缩进)加载配置，经过与启动时相同的默认值与校验
+func testSettings(t *testing.T, watcher string) *settings.Settings {
+	t.Helper()
+	dir := t.TempDir()
+	var b strings.Builder
+	b.WriteString("watchman:\n  watcher:\n")
+	for _, line := range strings.Split(strings.TrimSpace(watcher), "\n") {
+		b.WriteString("    " + line + "\n")
+	}
+	if err := os.WriteFile(filepath.Join(dir, "watchman.yml"), []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONF_DIR", dir)
+	s, err := settings.Load()
+	if err != nil {
+		t.Fatalf("load settings: %v", err)
+	}
+	return s
+}
+
+// newTestWatchman 未指定 backend 时以 fanotify 后端初始化，缺少 CAP_SYS_ADMIN 或内核不支持时跳过
+func newTestWatchman(t *testing.T, watcher string) *Watchman {
+	t.Helper()
+	s := testSettings(t, watcher)
+	if !strings.Contains(watcher, "backend:") {
+		s.Watchman.Watcher.Backend = BackendFanotify
+	}
+	wm, err := Initialize(s)
+	if err != nil {
+		if fanotifyUnavailable(errors.Unwrap(err)) || fanotifyUnavailable(err) {
+			t.Skipf("fanotify unavailable: %v", err)
+		}
+		t.Fatal(err)
+	}
+	t.Cleanup(wm.Stop)
+	return wm
+}
+
+// eventSink 收集投递的事件
+type eventSink struct {
+	mu     sync.Mutex
+	events []*EventInfo
+	notify chan struct{}
+	stop   func() // 停止 Watch 并等待其协程退出，可重复调用
+}
+
+// runTestWatchman 注册收集事件的监听器并启动 Watch，测试结束时停止
+func runTestWatchman(t *testing.T, wm *Watchman) *eventSink {
+	t.Helper()
+	sink := &eventSink{notify: make(chan struct{}, 1)}
+	wm.AddEventListener("test", func(info *EventInfo) {
+		sink.mu.Lock()
+		sink.events = append(sink.events, info)
+		sink.mu.Unlock()
+		select {
+		case sink.notify <- struct{}{}:
+		default:
+		}
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wm.Watch(ctx, &wg)
+	sink.stop = sync.OnceFunc(func() {
+		cancel()
+		wm.Interrupt()
+		wg.Wait()
+	})
+	t.Cleanup(sink.stop)
+	return sink
+}
+
+// wait 等待直到出现满足 match 的事件，超时则失败
+func (s *eventSink) wait(t *testing.T, match func(*EventInfo) bool) *EventInfo {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		if info := s.find(match); info != nil {
+			return info
+		}
+		select {
+		case <-s.notify:
+		case <-deadline:
+			t.Fatalf("timed out waiting for event, got %s", s)
+			return nil
+		}
+	}
+}
+
+func (s *eventSink) find(match func(*EventInfo) bool) *EventInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, info := range s.events {
+		if match(info) {
+			return info
+		}
+	}
+	return nil
+}
+
+// snapshot 当前已收集的事件
+func (s *eventSink) snapshot() []*EventInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*EventInfo(nil), s.events...)
+}
+
+func (s *eventSink) String() string {
+	var b strings.Builder
+	for _, info := range s.snapshot() {
+		fmt.Fprintf(&b, "[%s %s] ", info.Type, info.Path)
+	}
+	return b.String()
+}
+
+// dfidName 构造 dir 的 handle 与文件名组成的 FID 类 info 记录，与内核上报的格式相同
+func dfidName(t *testing.T, infoType byte, dir, name string) []byte {
+	t.Helper()
+	fh, _, err := unix.NameToHandleAt(unix.AT_FDCWD, dir, 0)
+	if err != nil {
+		t.Skipf("name_to_handle_at: %v", err)
+	}
+	var st unix.Statfs_t
+	if err := unix.Statfs(dir, &st); err != nil {
+		t.Fatal(err)
+	}
+	raw := fh.Bytes()
+	rec := make([]byte, EventInfoFidLen+FileHandleLen, EventInfoFidLen+FileHandleLen+len(raw)+len(name)+1)
+	rec[0] = infoType
+	binary.LittleEndian.PutUint32(rec[4:8], uint32(st.Fsid.Val[0]))
+	binary.LittleEndian.PutUint32(rec[8:12], uint32(st.Fsid.Val[1]))
+	binary.LittleEndian.PutUint32(rec[12:16], uint32(len(raw)))
+	binary.LittleEndian.PutUint32(rec[16:20], uint32(fh.Type()))
+	rec = append(rec, raw...)
+	if name != "" {
+		rec = append(rec, name...)
+		rec = append(rec, 0)
+	}
+	binary.LittleEndian.PutUint16(rec[2:4], uint16(len(rec)))
+	return rec
+}
+
+func mkdirAll(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(path, 0o755); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
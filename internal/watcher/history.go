@@ -0,0 +1,58 @@
+package watcher
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// RecentEvent 最近投递的事件摘要，见 RecentEvents
+type RecentEvent struct {
+	Type  string    `json:"type"`
+	Path  string    `json:"path"`
+	IsDir bool      `json:"is_dir"`
+	Time  time.Time `json:"time"`
+}
+
+// eventRing 固定大小的环形缓冲区：写入只做一次原子自增和一次指针存储，不加锁，不拖慢 processEvents；
+// 读取与写入并发时可能漏掉或多出正在被覆盖的一条，调试用途可以接受
+type eventRing struct {
+	next  atomic.Uint64
+	slots []atomic.Pointer[RecentEvent]
+}
+
+func newEventRing(size int) *eventRing {
+	if size <= 0 {
+		return nil
+	}
+	return &eventRing{slots: make([]atomic.Pointer[RecentEvent], size)}
+}
+
+func (r *eventRing) record(info *EventInfo) {
+	i := r.next.Add(1) - 1
+	r.slots[i%uint64(len(r.slots))].Store(&RecentEvent{Type: info.Type, Path: info.Path, IsDir: info.IsDir, Time: info.Time})
+}
+
+// last 返回最近的至多 n 个事件，按时间从旧到新；n <= 0 时返回缓冲区中的全部
+func (r *eventRing) last(n int) []RecentEvent {
+	total := r.next.Load()
+	size := uint64(len(r.slots))
+	count := min(total, size)
+	if n > 0 && uint64(n) < count {
+		count = uint64(n)
+	}
+	events := make([]RecentEvent, 0, count)
+	for i := total - count; i < total; i++ {
+		if e := r.slots[i%size].Load(); e != nil {
+			events = append(events, *e)
+		}
+	}
+	return events
+}
+
+// RecentEvents 返回最近投递的至多 n 个事件(n <= 0 时为全部)，按时间从旧到新；未配置 history.size 时为空
+func (wm *Watchman) RecentEvents(n int) []RecentEvent {
+	if wm.history == nil {
+		return nil
+	}
+	return wm.history.last(n)
+}
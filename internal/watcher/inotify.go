@@ -0,0 +1,196 @@
+package watcher
+
+import (
+	"encoding/binary"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	BackendAuto     = "auto"
+	BackendFanotify = "fanotify"
+	BackendInotify  = "inotify"
+
+	// inotify 与 fanotify 的事件位取值相同，这些位可直接作为 fanotify 掩码交给 handleEvent
+	inotifyEvents = unix.IN_CREATE | unix.IN_DELETE | unix.IN_DELETE_SELF | unix.IN_CLOSE_WRITE |
+		unix.IN_MOVED_FROM | unix.IN_MOVED_TO | unix.IN_MODIFY | unix.IN_ATTRIB
+	// 维护递归监视所需的事件，即使未订阅也要监视，读取后不上报
+	inotifyTreeEvents = unix.IN_CREATE | unix.IN_MOVED_FROM | unix.IN_MOVED_TO
+)
+
+// inotifyWatcher fanotify 不可用时的后备：为监控路径下的每个目录添加 inotify 监视，目录被创建或移入时递归补充，
+// 移出时移除。读取到的事件转换为已解析好路径的 Event 进入与 fanotify 相同的过滤、去重与投递流程。
+type inotifyWatcher struct {
+	fd     int
+	report uint32 // 上报给监听器的事件
+	watch  uint32 // 添加监视时使用的掩码，含 inotifyTreeEvents
+
+	mu      sync.Mutex
+	watches map[int32]inotifyWatch
+	full    bool // 已达到 max_user_watches，只警告一次
+}
+
+type inotifyWatch struct {
+	path  string
+	isDir bool
+}
+
+// fanotifyUnavailable 判断 fanotify 初始化失败是否属于内核不支持或缺少权限，此时 auto 后端降级为 inotify
+func fanotifyUnavailable(err error) bool {
+	return errors.Is(err, unix.EPERM) || errors.Is(err, unix.ENOSYS) || errors.Is(err, unix.EINVAL)
+}
+
+// newInotifyWatcher events 为 EventMask 得到的 fanotify 掩码；inotify 没有 FAN_RENAME，改为订阅 MOVED_FROM/MOVED_TO
+func newInotifyWatcher(events uint64) (*inotifyWatcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	report := uint32(events & inotifyEvents)
+	if events&unix.FAN_RENAME != 0 {
+		report |= unix.IN_MOVED_FROM | unix.IN_MOVED_TO
+	}
+	return &inotifyWatcher{fd: fd, report: report, watch: report | inotifyTreeEvents,
+		watches: make(map[int32]inotifyWatch)}, nil
+}
+
+// addTree 监视 root 及其下所有目录，root 为文件时只监视该文件。
+// synthesize 为 true 时为 root 之下已存在的条目合成 CREATE：新建目录在添加监视之前写入的文件不会产生事件
+func (w *inotifyWatcher) addTree(root string, synthesize bool) []Event {
+	var created []Event
+	_ = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() || p == root {
+			if err := w.add(p, d.IsDir()); err != nil {
+				if errors.Is(err, unix.ENOSPC) {
+					return filepath.SkipAll
+				}
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+		}
+		if synthesize && p != root && w.report&unix.IN_CREATE != 0 {
+			mask := uint64(unix.FAN_CREATE)
+			if d.IsDir() {
+				mask |= unix.FAN_ONDIR
+			}
+			created = append(created, Event{Mask: mask, IsDir: d.IsDir(), Pidfd: -1,
+				resolved: &resolution{dir: filepath.Dir(p), name: d.Name(), ok: true}})
+		}
+		return nil
+	})
+	return created
+}
+
+func (w *inotifyWatcher) add(path string, isDir bool) error {
+	mask := w.watch
+	if isDir {
+		mask |= unix.IN_ONLYDIR
+	}
+	wd, err := unix.InotifyAddWatch(w.fd, path, mask)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err != nil {
+		if errors.Is(err, unix.ENOSPC) && !w.full {
+			w.full = true
+			slog.Warn("inotify watch limit reached, raise fs.inotify.max_user_watches; remaining directories are not watched",
+				"path", path, "watches", len(w.watches))
+		}
+		return err
+	}
+	w.watches[int32(wd)] = inotifyWatch{path: path, isDir: isDir}
+	return nil
+}
+
+// removeTree 移除 root 及其下目录的监视，用于目录被移出之后；移入监控范围内的新位置时由 MOVED_TO 重新添加
+func (w *inotifyWatcher) removeTree(root string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for wd, watch := range w.watches {
+		if watch.path == root || strings.HasPrefix(watch.path, root+"/") {
+			_, _ = unix.InotifyRmWatch(w.fd, uint32(wd))
+			delete(w.watches, wd)
+		}
+	}
+}
+
+// parse 解析一次 read 得到的 inotify 事件，与 parseEvents 一样对每个事件调用 fn，fn 返回 false 时停止
+func (w *inotifyWatcher) parse(data []byte, fn func(Event) bool) {
+	for len(data) >= unix.SizeofInotifyEvent {
+		wd := int32(binary.LittleEndian.Uint32(data[0:4]))
+		mask := binary.LittleEndian.Uint32(data[4:8])
+		cookie := binary.LittleEndian.Uint32(data[8:12])
+		nameLen := int(binary.LittleEndian.Uint32(data[12:16]))
+		if unix.SizeofInotifyEvent+nameLen > len(data) {
+			break
+		}
+		name := strings.TrimRight(string(data[unix.SizeofInotifyEvent:unix.SizeofInotifyEvent+nameLen]), "\x00")
+		data = data[unix.SizeofInotifyEvent+nameLen:]
+		if mask&unix.IN_Q_OVERFLOW != 0 {
+			if !fn(Event{Mask: unix.FAN_Q_OVERFLOW, Pidfd: -1}) {
+				return
+			}
+			continue
+		}
+		w.mu.Lock()
+		watch, ok := w.watches[wd]
+		if mask&unix.IN_IGNORED != 0 {
+			delete(w.watches, wd)
+		}
+		w.mu.Unlock()
+		if !ok || mask&unix.IN_IGNORED != 0 {
+			continue
+		}
+		isDir := mask&unix.IN_ISDIR != 0
+		var res resolution
+		var created []Event
+		if name == "" {
+			// 作用于被监视对象自身的事件
+			isDir = watch.isDir
+			res = resolution{dir: filepath.Dir(watch.path), name: filepath.Base(watch.path), ok: true}
+		} else {
+			res = resolution{dir: watch.path, name: name, ok: true}
+			if isDir && mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0 {
+				// 移入的目录与 fanotify 一样只有目录自身的 MOVED_TO，不为其中已有的文件合成事件
+				created = w.addTree(filepath.Join(watch.path, name), mask&unix.IN_CREATE != 0)
+			} else if isDir && mask&unix.IN_MOVED_FROM != 0 {
+				w.removeTree(filepath.Join(watch.path, name))
+			}
+		}
+		if reported := mask & w.report; reported != 0 {
+			event := uint64(reported)
+			if isDir {
+				event |= unix.FAN_ONDIR
+			}
+			e := Event{Mask: event, IsDir: isDir, Pidfd: -1, resolved: &res}
+			if reported&(unix.IN_MOVED_FROM|unix.IN_MOVED_TO) != 0 {
+				e.Cookie = cookie
+			}
+			if !fn(e) {
+				return
+			}
+		}
+		for _, e := range created {
+			if !fn(e) {
+				return
+			}
+		}
+	}
+}
+
+// watchCount 当前的监视数量
+func (w *inotifyWatcher) watchCount() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.watches)
+}
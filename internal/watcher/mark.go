@@ -0,0 +1,166 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/caoenergy/watchman/platform/linux"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// 未配置 watcher.events 时关注的事件
+	watchedEvents = unix.FAN_CREATE | unix.FAN_DELETE | unix.FAN_DELETE_SELF | unix.FAN_CLOSE_WRITE | unix.FAN_MOVED_TO
+	// 仅对目录有意义的标志；对普通文件的 inode mark 附带这些标志内核会返回 ENOTDIR
+	dirOnlyFlags = unix.FAN_CREATE | unix.FAN_DELETE | unix.FAN_MOVED_TO | unix.FAN_MOVED_FROM | unix.FAN_RENAME |
+		unix.FAN_ONDIR | unix.FAN_EVENT_ON_CHILD
+
+	MarkModeFilesystem = "filesystem"
+	MarkModeInode      = "inode"
+	MarkModeMount      = "mount"
+
+	ReportModeAuto = "auto"
+	ReportModeFid  = "fid"
+
+	// FAN_MARK_MOUNT 不支持 inode 类事件(CREATE/DELETE/MOVE/ATTRIB 等)，降级后只能收到作用于文件内容的事件
+	mountEvents = unix.FAN_CLOSE_WRITE | unix.FAN_MODIFY
+)
+
+// markableEvents 可通过 watcher.events 配置的事件类型，与 maskToString 的名称一致
+var markableEvents = map[string]uint64{
+	"CREATE":      unix.FAN_CREATE,
+	"DELETE":      unix.FAN_DELETE,
+	"DELETE_SELF": unix.FAN_DELETE_SELF,
+	"CLOSE_WRITE": unix.FAN_CLOSE_WRITE,
+	"MOVED_TO":    unix.FAN_MOVED_TO,
+	"MOVED_FROM":  unix.FAN_MOVED_FROM,
+	"RENAME":      unix.FAN_RENAME, // 需内核 >= 5.17，一个事件同时携带原路径与新路径
+	"MODIFY":      unix.FAN_MODIFY,
+	"ATTRIB":      unix.FAN_ATTRIB,
+}
+
+// EventMask 将事件类型名转换为 fanotify 掩码，names 为空时返回默认关注的事件；未知名称由配置校验拦截，这里忽略
+func EventMask(names []string) uint64 {
+	if len(names) == 0 {
+		return watchedEvents
+	}
+	var mask uint64
+	for _, n := range names {
+		mask |= markableEvents[n]
+	}
+	return mask
+}
+
+// markMask 按路径类型选择标志：目录需要子项事件，单文件只保留作用于自身的事件
+func markMask(events uint64, isDir bool) uint64 {
+	mask := events | unix.FAN_ONDIR | unix.FAN_EVENT_ON_CHILD
+	if !isDir {
+		mask &^= dirOnlyFlags
+	}
+	return mask
+}
+
+// addMarks 按 mark-mode 添加标记：filesystem 标记 "/" 及配置路径所在的整个文件系统；
+// mount 只标记配置路径所在的挂载点，其他挂载上的事件不再进入队列；
+// inode 只标记每个配置路径本身，目录只覆盖直接子项（不递归）。
+// "/" 以外的文件系统的目录 fd 记录到 mounts，用于解析其上的 handle
+func addMarks(ffd int, mode string, paths []string, events uint64, mounts *mountRoots) error {
+	if mode == MarkModeMount {
+		return addMountMarks(ffd, paths, events&mountEvents, mounts)
+	}
+	if mode != MarkModeInode {
+		err := unix.FanotifyMark(ffd, unix.FAN_MARK_ADD|unix.FAN_MARK_FILESYSTEM, markMask(events, true), unix.AT_FDCWD, "/")
+		if err == nil {
+			return addFilesystemMarks(ffd, paths, events, mounts)
+		}
+		if !errors.Is(err, unix.EINVAL) && !errors.Is(err, unix.EOPNOTSUPP) {
+			return fmt.Errorf("mark: %w", err)
+		}
+		slog.Warn("FAN_MARK_FILESYSTEM unsupported, falling back to mount marks: only CLOSE_WRITE/MODIFY are reported "+
+			"and mounts that appear later are not covered", "err", err)
+		return addMountMarks(ffd, paths, events&mountEvents, mounts)
+	}
+	for _, p := range paths {
+		fi, err := os.Stat(p)
+		if err != nil {
+			return fmt.Errorf("mark %s: %w", p, err)
+		}
+		if err := unix.FanotifyMark(ffd, unix.FAN_MARK_ADD, markMask(events, fi.IsDir()), unix.AT_FDCWD, p); err != nil {
+			return fmt.Errorf("mark %s: %w", p, err)
+		}
+		slog.Info("inode mark added", "path", p, "dir", fi.IsDir())
+		if err := mounts.add(p); err != nil {
+			return fmt.Errorf("mark %s: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// addFilesystemMarks 为位于 "/" 以外文件系统的配置路径补充 FAN_MARK_FILESYSTEM 标记，每个文件系统一次；
+// 路径不存在时跳过(见 warnMissing)
+func addFilesystemMarks(ffd int, paths []string, events uint64, mounts *mountRoots) error {
+	root, err := linux.Fsid("/")
+	if err != nil {
+		return fmt.Errorf("mark: %w", err)
+	}
+	marked := map[[8]byte]bool{root: true}
+	for _, p := range paths {
+		fsid, err := linux.Fsid(p)
+		if err != nil || marked[fsid] {
+			continue
+		}
+		marked[fsid] = true
+		if err := unix.FanotifyMark(ffd, unix.FAN_MARK_ADD|unix.FAN_MARK_FILESYSTEM, markMask(events, true), unix.AT_FDCWD, p); err != nil {
+			return fmt.Errorf("mark %s: %w", p, err)
+		}
+		if err := mounts.add(p); err != nil {
+			return fmt.Errorf("mark %s: %w", p, err)
+		}
+		slog.Info("filesystem mark added", "path", p)
+	}
+	return nil
+}
+
+// addMountMarks 按 /proc/self/mountinfo 找到每个配置路径所在的挂载点并添加 FAN_MARK_MOUNT 标记，同一挂载点只标记一次；
+// paths 为已由 pathRoot 转换的实际目录。mountinfo 不可读时(如加入的挂载命名空间中 /proc 属于其他 pid 命名空间)直接标记路径本身
+func addMountMarks(ffd int, paths []string, events uint64, mounts *mountRoots) error {
+	if events == 0 {
+		return errors.New("mark: none of the configured events are supported by mount marks")
+	}
+	marked := make(map[string]bool)
+	for _, p := range paths {
+		if _, err := os.Stat(p); err != nil {
+			return fmt.Errorf("mark %s: %w", p, err)
+		}
+		mp, err := linux.MountPoint(p)
+		if err != nil {
+			slog.Warn("mount point lookup failed, marking the path's mount directly", "path", p, "err", err)
+			mp = p
+		}
+		if marked[mp] {
+			continue
+		}
+		marked[mp] = true
+		if err := unix.FanotifyMark(ffd, unix.FAN_MARK_ADD|unix.FAN_MARK_MOUNT, events|unix.FAN_ONDIR|unix.FAN_EVENT_ON_CHILD, unix.AT_FDCWD, mp); err != nil {
+			return fmt.Errorf("mark %s: %w", mp, err)
+		}
+		if err := mounts.add(mp); err != nil {
+			return fmt.Errorf("mark %s: %w", mp, err)
+		}
+		slog.Info("mount mark added", "path", p, "mount", mp)
+	}
+	return nil
+}
+
+// renameSupported 用临时的 fanotify 组探测内核是否支持 FAN_RENAME(需内核 >= 5.17)
+func renameSupported() bool {
+	fd, err := unix.FanotifyInit(unix.FAN_REPORT_DFID_NAME|unix.FAN_CLOEXEC, unix.O_RDONLY)
+	if err != nil {
+		return false
+	}
+	defer unix.Close(fd)
+	return unix.FanotifyMark(fd, unix.FAN_MARK_ADD, unix.FAN_RENAME|unix.FAN_ONDIR, unix.AT_FDCWD, "/") == nil
+}
@@ -0,0 +1,112 @@
+package watcher
+
+import (
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caoenergy/watchman/internal/clock"
+
+	"github.com/armon/go-radix"
+)
+
+// Middleware 包装 EventListener，为单个消费者叠加过滤、限流等逻辑。
+type Middleware func(EventListener) EventListener
+
+// Chain 按顺序应用中间件，第一个中间件位于最外层。
+func Chain(l EventListener, mws ...Middleware) EventListener {
+	for i := len(mws) - 1; i >= 0; i-- {
+		l = mws[i](l)
+	}
+	return l
+}
+
+// WithPathFilter 按前缀包含/排除路径；include 为空表示不限制，排除规则优先。
+// 前缀按路径段匹配(/data/up 不包含 /data/uploads)，与监控组一样使用改写前的路径(见 HostPath)。
+// 不关联路径的事件(如 SESSION_START)不受限制。
+func WithPathFilter(include, exclude []string) Middleware {
+	in, ex := prefixTree(include), prefixTree(exclude)
+	return func(next EventListener) EventListener {
+		return func(info *EventInfo) {
+			path := info.HostPath()
+			if path == "" {
+				next(info)
+				return
+			}
+			if in.Len() > 0 && !underPrefix(in, path) {
+				return
+			}
+			if underPrefix(ex, path) {
+				return
+			}
+			next(info)
+		}
+	}
+}
+
+// WithEventFilter 只投递包含指定类型之一的事件；events 为空表示不限制。
+func WithEventFilter(events []string) Middleware {
+	wanted := make(map[string]bool, len(events))
+	for _, e := range events {
+		wanted[e] = true
+	}
+	return func(next EventListener) EventListener {
+		if len(wanted) == 0 {
+			return next
+		}
+		return func(info *EventInfo) {
+			for _, t := range strings.Split(info.Type, "|") {
+				if wanted[t] {
+					next(info)
+					return
+				}
+			}
+		}
+	}
+}
+
+// WithRateLimit 每秒最多投递 perSecond 个事件，超出的直接丢弃；perSecond<=0 表示不限制。
+func WithRateLimit(perSecond int, clk clock.Clock) Middleware {
+	return func(next EventListener) EventListener {
+		if perSecond <= 0 {
+			return next
+		}
+		var (
+			mu     sync.Mutex
+			window time.Time
+			count  int
+		)
+		return func(info *EventInfo) {
+			now := clk.Now().Truncate(time.Second)
+			mu.Lock()
+			if !now.Equal(window) {
+				window, count = now, 0
+			}
+			count++
+			allowed := count <= perSecond
+			mu.Unlock()
+			if allowed {
+				next(info)
+			}
+		}
+	}
+}
+
+// underPrefix path 是否等于树中的某个前缀或位于其下
+func underPrefix(t *radix.Tree, path string) bool {
+	found := false
+	t.WalkPath(path, func(p string, _ interface{}) bool {
+		found = len(p) == len(path) || path[len(p)] == '/' || p == "/"
+		return found
+	})
+	return found
+}
+
+func prefixTree(prefixes []string) *radix.Tree {
+	t := radix.New()
+	for _, p := range prefixes {
+		t.Insert(filepath.Clean(p), true)
+	}
+	return t
+}
@@ -0,0 +1,31 @@
+package watcher
+
+import "testing"
+
+func TestWithPathFilterMatchesSegments(t *testing.T) {
+	var got []string
+	l := Chain(func(info *EventInfo) { got = append(got, info.Path) },
+		WithPathFilter([]string{"/data/up", "/srv/"}, []string{"/data/up/tmp"}))
+	for _, p := range []string{"/data/up", "/data/up/a", "/data/uploads/a", "/data/up/tmp/b", "/data/up/tmpfile", "/srv/x", ""} {
+		l(&EventInfo{Path: p})
+	}
+	want := []string{"/data/up", "/data/up/a", "/data/up/tmpfile", "/srv/x", ""}
+	if len(got) != len(want) {
+		t.Fatalf("delivered %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("delivered %q, want %q", got, want)
+		}
+	}
+}
+
+// 开启 path-translation 时与监控组一样按改写前的路径过滤
+func TestWithPathFilterUsesHostPath(t *testing.T) {
+	delivered := false
+	l := WithPathFilter([]string{"/host/data"}, nil)(func(*EventInfo) { delivered = true })
+	l(&EventInfo{Path: "/data/a", origPath: "/host/data/a"})
+	if !delivered {
+		t.Fatal("event under the host include prefix was dropped")
+	}
+}
@@ -0,0 +1,25 @@
+package watcher
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// monoClock 将事件的 Time 换算为 CLOCK_MONOTONIC 纳秒。记录一次基准点 (CLOCK_MONOTONIC, clock.Now())，
+// 之后按 Time 与基准 Time 之差推算：两者都带 Go 单调读数时相减不受系统时钟跳变影响，
+// 结果可与同一主机上其他进程的 CLOCK_MONOTONIC 时间戳直接比较。
+type monoClock struct {
+	base int64
+	at   time.Time
+}
+
+func newMonoClock(now time.Time) monoClock {
+	var ts unix.Timespec
+	_ = unix.ClockGettime(unix.CLOCK_MONOTONIC, &ts)
+	return monoClock{base: ts.Nano(), at: now}
+}
+
+func (m monoClock) of(t time.Time) int64 {
+	return m.base + int64(t.Sub(m.at))
+}
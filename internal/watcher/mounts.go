@@ -0,0 +1,84 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/caoenergy/watchman/platform/linux"
+
+	"golang.org/x/sys/unix"
+)
+
+// mountRoots 按 fsid 记录挂载标记所在文件系统的目录 fd：OpenByHandleAt 只能解析 mount_fd 所在文件系统的 handle，
+// 监控路径位于 "/" 以外的文件系统时须用对应的 fd 解析。"/" 所在的文件系统由 rfd 负责，不重复记录
+type mountRoots struct {
+	mu  sync.RWMutex
+	fds map[[8]byte]int
+}
+
+func newMountRoots() *mountRoots {
+	return &mountRoots{fds: make(map[[8]byte]int)}
+}
+
+// add 打开 dir(为文件时取其所在目录)并按其 fsid 记录，同一文件系统只保留第一个 fd
+func (m *mountRoots) add(dir string) error {
+	if fi, err := os.Stat(dir); err == nil && !fi.IsDir() {
+		dir = filepath.Dir(dir)
+	}
+	fsid, err := linux.Fsid(dir)
+	if err != nil {
+		return err
+	}
+	if root, err := linux.Fsid("/"); err == nil && root == fsid {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.fds[fsid]; ok {
+		return nil
+	}
+	// OpenByHandleAt 不接受 O_PATH 打开的 mount_fd
+	fd, err := unix.Open(dir, unix.O_DIRECTORY|unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return err
+	}
+	m.fds[fsid] = fd
+	return nil
+}
+
+// fd 返回事件 fsid 对应的目录 fd，未记录时返回 fallback
+func (m *mountRoots) fd(fsid []byte, fallback int) int {
+	var key [8]byte
+	if copy(key[:], fsid) != len(key) {
+		return fallback
+	}
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if fd, ok := m.fds[key]; ok {
+		return fd
+	}
+	return fallback
+}
+
+func (m *mountRoots) close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for fsid, fd := range m.fds {
+		_ = unix.Close(fd)
+		delete(m.fds, fsid)
+	}
+}
+
+// MountPoint 返回 fsid(见 EventInfo.Fsid)对应文件系统的挂载点，成功的结果按 fsid 缓存；无法确定时返回空串
+func (wm *Watchman) MountPoint(fsid [8]byte) string {
+	if mp, ok := wm.fsidMounts.Load(fsid); ok {
+		return mp.(string)
+	}
+	mp, err := linux.FsidMountPoint(fsid)
+	if err != nil {
+		return ""
+	}
+	wm.fsidMounts.Store(fsid, mp)
+	return mp
+}
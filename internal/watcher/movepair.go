@@ -0,0 +1,118 @@
+package watcher
+
+import (
+	"time"
+
+	"github.com/caoenergy/watchman/internal/clock"
+
+	"golang.org/x/sys/unix"
+)
+
+// movePairer 订阅了 RENAME 而内核不能提供 FAN_RENAME(内核 < 5.17 或 inotify 后端)时，将同一次重命名的 MOVED_FROM 与 MOVED_TO 配对为 RENAME。
+// 两半由 Event.Cookie 关联：inotify 事件自带 cookie；fanotify 没有，由 assign 为事件流中紧邻的一对分配。
+// 命中监控规则的 MOVED_FROM 暂存 window，期间对应的 MOVED_TO 命中规则则合并为 RENAME(Path 为新路径，OldPath 为原路径)，
+// 新位置被过滤或窗口到期时 MOVED_FROM 单独投递；没有暂存的 MOVED_FROM 的 MOVED_TO(从监控范围外移入)照常投递。
+// pending 只在事件循环协程中访问，定时器回调仅通过 release 通道把事件交回事件循环。
+type movePairer struct {
+	window  time.Duration
+	clock   clock.Clock
+	pending map[uint32]*heldMove
+	release chan *heldMove
+	done    chan struct{}
+
+	// 以下只在 captureEvents 协程中访问，见 assign
+	seq  uint32
+	from movedFrom
+}
+
+type heldMove struct {
+	info   *EventInfo
+	cookie uint32
+	timer  clock.Timer
+}
+
+// movedFrom 事件流中上一个事件为 MOVED_FROM 时记录其 cookie 与配对条件
+type movedFrom struct {
+	cookie uint32
+	pid    int32
+	isDir  bool
+}
+
+func newMovePairer(window time.Duration, clk clock.Clock) *movePairer {
+	return &movePairer{
+		window:  window,
+		clock:   clk,
+		pending: make(map[uint32]*heldMove),
+		release: make(chan *heldMove, 1024),
+		done:    make(chan struct{}),
+	}
+}
+
+// assign 为一批 fanotify 事件分配 cookie：内核依次上报一次重命名的 MOVED_FROM 与 MOVED_TO，
+// 紧邻、来自同一进程且同为目录或文件的一对视为同一次重命名；中间夹有其他事件时不配对，两者各自单独投递。
+// 事件流跨批次连续，状态在批次之间保留
+func (p *movePairer) assign(batch []Event) {
+	for i := range batch {
+		e := &batch[i]
+		from := p.from
+		p.from = movedFrom{}
+		switch {
+		case e.Mask&unix.FAN_MOVED_FROM != 0:
+			p.seq++
+			if p.seq == 0 {
+				p.seq = 1
+			}
+			e.Cookie = p.seq
+			p.from = movedFrom{cookie: e.Cookie, pid: e.Pid, isDir: e.IsDir}
+		case e.Mask&unix.FAN_MOVED_TO != 0 && from.cookie != 0 && from.pid == e.Pid && from.isDir == e.IsDir:
+			e.Cookie = from.cookie
+		}
+	}
+}
+
+// hold 暂存已通过过滤的 MOVED_FROM，等待同一 cookie 的 MOVED_TO；cookie 已有暂存的事件(回绕)时返回它，由调用方单独投递
+func (p *movePairer) hold(cookie uint32, info *EventInfo) *EventInfo {
+	displaced := p.take(cookie)
+	h := &heldMove{info: info, cookie: cookie}
+	h.timer = p.clock.AfterFunc(p.window, func() {
+		select {
+		case p.release <- h:
+		case <-p.done:
+		}
+	})
+	p.pending[cookie] = h
+	return displaced
+}
+
+// take 取出与 cookie 对应的暂存 MOVED_FROM，没有时返回 nil
+func (p *movePairer) take(cookie uint32) *EventInfo {
+	h, ok := p.pending[cookie]
+	if !ok {
+		return nil
+	}
+	h.timer.Stop()
+	delete(p.pending, cookie)
+	return h.info
+}
+
+// expired 窗口到期，返回 MOVED_FROM 是否仍需单独投递(期间未被配对)
+func (p *movePairer) expired(h *heldMove) bool {
+	if p.pending[h.cookie] != h {
+		return false
+	}
+	delete(p.pending, h.cookie)
+	logPairing("unpaired", h.cookie, h.info.Path, "", p.window)
+	return true
+}
+
+// drain 事件循环退出时取出所有暂存的 MOVED_FROM，由调用方直接投递
+func (p *movePairer) drain() []*EventInfo {
+	close(p.done)
+	held := make([]*EventInfo, 0, len(p.pending))
+	for cookie, h := range p.pending {
+		h.timer.Stop()
+		held = append(held, h.info)
+		delete(p.pending, cookie)
+	}
+	return held
+}
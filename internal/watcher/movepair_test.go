@@ -0,0 +1,90 @@
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/caoenergy/watchman/internal/clock"
+	"golang.org/x/sys/unix"
+)
+
+func TestMovePairerAssign(t *testing.T) {
+	p := newMovePairer(time.Second, clock.Real{})
+	from := func(pid int32) Event { return Event{Mask: unix.FAN_MOVED_FROM, Pid: pid} }
+	to := func(pid int32) Event { return Event{Mask: unix.FAN_MOVED_TO, Pid: pid} }
+	batch := []Event{
+		from(1), to(1), // 紧邻的一对
+		from(2), {Mask: unix.FAN_CREATE, Pid: 2}, to(2), // 中间夹有其他事件
+		from(3), to(4), // 不同进程
+		from(5), // 跨批次
+	}
+	p.assign(batch)
+	next := []Event{to(5)}
+	p.assign(next)
+	if batch[0].Cookie == 0 || batch[1].Cookie != batch[0].Cookie {
+		t.Errorf("adjacent pair got cookies %d, %d", batch[0].Cookie, batch[1].Cookie)
+	}
+	if batch[4].Cookie != 0 {
+		t.Errorf("interleaved MOVED_TO got cookie %d", batch[4].Cookie)
+	}
+	if batch[6].Cookie != 0 {
+		t.Errorf("MOVED_TO from another process got cookie %d", batch[6].Cookie)
+	}
+	if batch[7].Cookie == 0 || next[0].Cookie != batch[7].Cookie {
+		t.Errorf("pair across batches got cookies %d, %d", batch[7].Cookie, next[0].Cookie)
+	}
+	if batch[0].Cookie == batch[2].Cookie || batch[2].Cookie == batch[5].Cookie {
+		t.Error("cookies are not unique")
+	}
+}
+
+// testMovePairing 重命名在监控范围内得到 RENAME，移出得到窗口到期后的 MOVED_FROM，移入得到 MOVED_TO
+func testMovePairing(t *testing.T, wm *Watchman, root string) {
+	t.Helper()
+	// 同一路径上先后出现 RENAME 与 MOVED_FROM，不按路径合并
+	wm.dedupKeyMode = KeyPathType
+	outside := t.TempDir()
+	sink := runTestWatchman(t, wm)
+	a, b, c := filepath.Join(root, "a"), filepath.Join(root, "b"), filepath.Join(root, "c")
+	writeFile(t, a, "x")
+	if err := os.Rename(a, b); err != nil {
+		t.Fatal(err)
+	}
+	rename := sink.wait(t, func(info *EventInfo) bool { return info.Type != "MOVED_TO" && info.Path == b })
+	if rename.Type != "RENAME" || rename.OldPath != a {
+		t.Fatalf("got %s %s (old %q), want RENAME from %s", rename.Type, rename.Path, rename.OldPath, a)
+	}
+	if err := os.Rename(b, filepath.Join(outside, "b")); err != nil {
+		t.Fatal(err)
+	}
+	sink.wait(t, func(info *EventInfo) bool { return info.Type == "MOVED_FROM" && info.Path == b })
+	writeFile(t, filepath.Join(outside, "c"), "y")
+	if err := os.Rename(filepath.Join(outside, "c"), c); err != nil {
+		t.Fatal(err)
+	}
+	sink.wait(t, func(info *EventInfo) bool { return info.Type == "MOVED_TO" && info.Path == c })
+	for _, info := range sink.snapshot() {
+		if info.Type == "MOVED_FROM" && info.Path == a || info.Type == "MOVED_TO" && info.Path == b {
+			t.Errorf("paired half delivered separately: %s %s", info.Type, info.Path)
+		}
+	}
+}
+
+func TestMovePairingInotify(t *testing.T) {
+	root := t.TempDir()
+	wm := newTestWatchman(t, "paths: ["+root+"]\nbackend: inotify\nevents: [RENAME]\nrename-window-ms: 50")
+	if wm.moves == nil {
+		t.Fatal("inotify backend did not enable move pairing")
+	}
+	testMovePairing(t, wm, root)
+}
+
+// 内核不支持 FAN_RENAME 时的配对：订阅两半并按相邻关系分配 cookie
+func TestMovePairingFanotify(t *testing.T) {
+	root := t.TempDir()
+	wm := newTestWatchman(t, "paths: ["+root+"]\nevents: [MOVED_FROM, MOVED_TO]")
+	wm.moves = newMovePairer(50*time.Millisecond, wm.clock)
+	testMovePairing(t, wm, root)
+}
@@ -0,0 +1,200 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	wmp "github.com/caoenergy/watchman-plugin"
+	"github.com/caoenergy/watchman/internal/clock"
+	"github.com/caoenergy/watchman/internal/retry"
+)
+
+// ErrorHandler 插件可选实现：返回 error 的 Handle。启用 plugin-queue 时优先调用，
+// 返回错误或 panic 时按 max-attempts 重试，仍失败则写入死信
+type ErrorHandler interface {
+	HandleErr(eventType, dir, filename string, isDir bool) error
+}
+
+// PluginQueueStats 单个插件队列的运行状态，见 Stats.Sinks["plugin:<name>"]
+type PluginQueueStats struct {
+	Queued       int    `json:"queued"`
+	Dropped      uint64 `json:"dropped"` // 队列满丢弃的事件数
+	Retried      uint64 `json:"retried"`
+	DeadLettered uint64 `json:"dead_lettered"` // 重试用尽仍失败的事件数
+}
+
+// pluginQueueConfig 见 SetPluginQueue
+type pluginQueueConfig struct {
+	size       int
+	policy     retry.Policy
+	deadLetter *deadLetterLog
+}
+
+// pluginQueue 每个插件一个有界队列和一个 worker：慢插件只积压自己的队列，队列满时丢弃并计数，不阻塞事件循环
+type pluginQueue struct {
+	name    string
+	h       wmp.Handler
+	cfg     pluginQueueConfig
+	clock   clock.Clock
+	onError ErrorReporter
+	queue   chan *EventInfo
+	block   bool
+	cancel  context.CancelFunc
+	done    <-chan struct{}
+	wg      sync.WaitGroup
+
+	dropped, retried, deadLettered atomic.Uint64
+}
+
+func newPluginQueue(name string, h wmp.Handler, cfg pluginQueueConfig, clk clock.Clock, block bool, onError ErrorReporter) *pluginQueue {
+	ctx, cancel := context.WithCancel(context.Background())
+	q := &pluginQueue{name: name, h: h, cfg: cfg, clock: clk, onError: onError, block: block,
+		queue: make(chan *EventInfo, cfg.size), cancel: cancel, done: ctx.Done()}
+	q.wg.Add(1)
+	go q.run(ctx)
+	return q
+}
+
+// handle 作为监听器注册，只负责入队
+func (q *pluginQueue) handle(info *EventInfo) {
+	// worker 在其他 goroutine 读取，复制一份避免与后续监听器写 Attrs 竞争
+	c := *info
+	c.Attrs = maps.Clone(info.Attrs)
+	info = &c
+	if q.block {
+		select {
+		case q.queue <- info:
+		case <-q.done:
+			q.dropped.Add(1)
+		}
+		return
+	}
+	select {
+	case q.queue <- info:
+	default:
+		q.dropped.Add(1)
+	}
+}
+
+func (q *pluginQueue) run(ctx context.Context) {
+	defer q.wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			// 关闭时队列中剩余的事件各尝试一次，不再退避重试
+			for {
+				select {
+				case info := <-q.queue:
+					q.deliver(ctx, info)
+				default:
+					return
+				}
+			}
+		case info := <-q.queue:
+			q.deliver(ctx, info)
+		}
+	}
+}
+
+func (q *pluginQueue) deliver(ctx context.Context, info *EventInfo) {
+	attempts := 0
+	var err error
+	_ = q.cfg.policy.Do(ctx, q.clock, func() error {
+		if attempts++; attempts > 1 {
+			q.retried.Add(1)
+		}
+		err = q.call(info)
+		return err
+	})
+	if err != nil {
+		q.deadLettered.Add(1)
+		q.onError(info, err)
+		q.cfg.deadLetter.write(q.name, info, attempts, err)
+	}
+}
+
+// call 调用一次插件，panic 视为失败
+func (q *pluginQueue) call(info *EventInfo) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	if eh, ok := q.h.(ErrorHandler); ok {
+		return eh.HandleErr(info.Type, info.Dir, info.Name, info.IsDir)
+	}
+	q.h.Handle(info.Type, info.Dir, info.Name, info.IsDir)
+	return nil
+}
+
+func (q *pluginQueue) stats() any {
+	return PluginQueueStats{
+		Queued:       len(q.queue),
+		Dropped:      q.dropped.Load(),
+		Retried:      q.retried.Load(),
+		DeadLettered: q.deadLettered.Load(),
+	}
+}
+
+// close 停止 worker 并等待队列中剩余的事件处理完
+func (q *pluginQueue) close() {
+	q.cancel()
+	q.wg.Wait()
+}
+
+// deadLetterLog 记录重试用尽的插件事件：配置了文件时每行追加一条 JSON，否则写入错误日志
+type deadLetterLog struct {
+	mu sync.Mutex
+	f  *os.File
+}
+
+type deadLetter struct {
+	Plugin   string    `json:"plugin"`
+	Type     string    `json:"type"`
+	Path     string    `json:"path"`
+	IsDir    bool      `json:"is_dir"`
+	Time     time.Time `json:"time"`
+	Attempts int       `json:"attempts"`
+	Error    string    `json:"error"`
+}
+
+func newDeadLetterLog(path string) (*deadLetterLog, error) {
+	if path == "" {
+		return &deadLetterLog{}, nil
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &deadLetterLog{f: f}, nil
+}
+
+func (d *deadLetterLog) write(plugin string, info *EventInfo, attempts int, err error) {
+	if d.f == nil {
+		slog.Error("plugin dead letter", "plugin", plugin, "type", info.Type, "path", info.Path, "attempts", attempts, "err", err)
+		return
+	}
+	data, _ := json.Marshal(deadLetter{Plugin: plugin, Type: info.Type, Path: info.Path, IsDir: info.IsDir,
+		Time: info.Time, Attempts: attempts, Error: err.Error()})
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, werr := d.f.Write(append(data, '\n')); werr != nil {
+		slog.Warn("dead letter write failed", "file", d.f.Name(), "err", werr)
+	}
+}
+
+func (d *deadLetterLog) Close() error {
+	if d.f == nil {
+		return nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.f.Close()
+}
@@ -0,0 +1,65 @@
+package watcher
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/caoenergy/watchman/internal/clock"
+	"github.com/caoenergy/watchman/internal/retry"
+)
+
+// failingPlugin HandleErr 总是失败
+type failingPlugin struct{}
+
+func (failingPlugin) Name() string                                 { return "failing" }
+func (failingPlugin) Init() error                                  { return nil }
+func (failingPlugin) Handle(string, string, string, bool)          {}
+func (failingPlugin) Close() error                                 { return nil }
+func (failingPlugin) HandleErr(string, string, string, bool) error { return errors.New("rejected") }
+
+// 入队的是事件副本：worker、错误上报与死信读取的是入队时的内容，之后修改事件不影响它们；配合 -race 运行
+func TestPluginQueueCopiesEvent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dead.jsonl")
+	dl, err := newDeadLetterLog(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const n = 50
+	reported := make(chan string, n)
+	q := newPluginQueue("failing", failingPlugin{}, pluginQueueConfig{size: n, policy: retry.Policy{MaxAttempts: 1}, deadLetter: dl},
+		clock.Real{}, true, func(info *EventInfo, _ error) { reported <- info.Path })
+	for range n {
+		info := &EventInfo{Type: "CLOSE_WRITE", Dir: "/data", Name: "a", Path: "/data/a"}
+		info.SetAttr("k", 1)
+		q.handle(info)
+		// 后续监听器继续修改同一事件
+		info.Path = "/translated/a"
+		info.SetAttr("k", 2)
+	}
+	for range n {
+		if got := <-reported; got != "/data/a" {
+			t.Fatalf("reported path %q, want /data/a", got)
+		}
+	}
+	q.close()
+	_ = dl.Close()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	lines := 0
+	for sc := bufio.NewScanner(f); sc.Scan(); lines++ {
+		var d deadLetter
+		if err := json.Unmarshal(sc.Bytes(), &d); err != nil || d.Path != "/data/a" {
+			t.Fatalf("dead letter %s: %v", sc.Text(), err)
+		}
+	}
+	if lines != n {
+		t.Errorf("%d dead letters, want %d", lines, n)
+	}
+}
@@ -0,0 +1,79 @@
+package watcher
+
+import (
+	"log/slog"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// 每 readWindow 次读取评估一次填充情况
+	readWindow = 16
+	// 剩余空间小于一个较大的事件(含 handle 与文件名)时视为读满，内核不会拆分事件
+	readFullSlack = 512
+	// 缓冲区大于初始大小时，poll 超过该时长没有事件即缩小一半
+	readIdleShrink = 10 * time.Second
+)
+
+// readBuffer captureEvents 的读缓冲区，只在 captureEvents 协程中使用(size 除外)。
+// max 大于 min 时(见 watcher.buffer-max-kb)，一个窗口内多数读取读满则扩大一倍，平均填充不足四分之一或空闲时缩小一半，
+// 大小始终在 [min, max] 内；解析出的 handle 均已复制，替换缓冲区不影响已读取的事件。
+type readBuffer struct {
+	buf         []byte
+	min, max    int
+	reads, full int // 当前窗口内的读取次数与读满次数
+	filled      int // 当前窗口内读取的字节数
+	size        atomic.Int64
+}
+
+func newReadBuffer(minKB, maxKB int) *readBuffer {
+	b := &readBuffer{min: minKB * 1024, max: max(minKB, maxKB) * 1024}
+	b.resize(b.min)
+	return b
+}
+
+// observe 记录一次读取了 n 字节，窗口结束时按填充情况调整大小
+func (b *readBuffer) observe(n int) {
+	if b.max == b.min {
+		return
+	}
+	b.reads++
+	b.filled += n
+	if n >= len(b.buf)-readFullSlack {
+		b.full++
+	}
+	if b.reads < readWindow {
+		return
+	}
+	switch {
+	case b.full*4 >= b.reads*3 && len(b.buf) < b.max:
+		b.resize(min(len(b.buf)*2, b.max))
+	case b.filled*4 < b.reads*len(b.buf) && len(b.buf) > b.min:
+		b.resize(max(len(b.buf)/2, b.min))
+	}
+	b.reads, b.full, b.filled = 0, 0, 0
+}
+
+// pollTimeout 缓冲区大于初始大小时 poll 带超时，以便空闲时缩小
+func (b *readBuffer) pollTimeout() int {
+	if len(b.buf) > b.min {
+		return int(readIdleShrink / time.Millisecond)
+	}
+	return -1
+}
+
+// idle poll 超时
+func (b *readBuffer) idle() {
+	if len(b.buf) > b.min {
+		b.resize(max(len(b.buf)/2, b.min))
+	}
+	b.reads, b.full, b.filled = 0, 0, 0
+}
+
+func (b *readBuffer) resize(n int) {
+	if b.buf != nil {
+		slog.Debug("resize read buffer", "from_kb", len(b.buf)/1024, "to_kb", n/1024)
+	}
+	b.buf = make([]byte, n)
+	b.size.Store(int64(n))
+}
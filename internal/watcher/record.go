@@ -0,0 +1,107 @@
+package watcher
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// 录制文件格式：文件头 recordMagic，之后为若干条记录，每条为
+// 8 字节小端 UnixNano 时间戳 + 4 字节小端长度 + 一次 read 得到的原始缓冲区。
+// 用于复现现场报告的解析问题，无需重建当时的内核条件。
+var recordMagic = []byte("WMREC\x00\x01\n")
+
+// 单条记录上限，与 maxBufferKB 一致，防止损坏的文件导致超大分配
+const maxRecordLen = 1024 * 1024
+
+type recorder struct {
+	mu sync.Mutex
+	f  *os.File
+	w  *bufio.Writer
+}
+
+// EnableRecord 将之后每次 read 得到的原始缓冲区追加到 path，须在 Watch 之前调用
+func (wm *Watchman) EnableRecord(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	if _, err := w.Write(recordMagic); err != nil {
+		_ = f.Close()
+		return err
+	}
+	wm.recorder = &recorder{f: f, w: w}
+	wm.AddCloser(wm.recorder)
+	wm.ExcludeSelf(path)
+	return nil
+}
+
+func (r *recorder) write(buf []byte, now time.Time) {
+	var head [12]byte
+	binary.LittleEndian.PutUint64(head[0:8], uint64(now.UnixNano()))
+	binary.LittleEndian.PutUint32(head[8:12], uint32(len(buf)))
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, err := r.w.Write(head[:]); err == nil {
+		_, err = r.w.Write(buf)
+		if err != nil {
+			slog.Warn("record write failed", "err", err)
+		}
+	}
+}
+
+func (r *recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.w.Flush(); err != nil {
+		_ = r.f.Close()
+		return err
+	}
+	return r.f.Close()
+}
+
+// Replay 读取录制文件，按原顺序把每条记录交给与 captureEvents 相同的解析逻辑，对每个事件调用 fn。
+// 回放出的事件不含可用的 pidfd（录制时的 fd 已失效），Pidfd 统一置为 -1。
+func Replay(r io.Reader, fn func(at time.Time, event Event)) error {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(recordMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || !bytes.Equal(magic, recordMagic) {
+		return errors.New("not a watchman recording")
+	}
+	var head [12]byte
+	for {
+		if _, err := io.ReadFull(br, head[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		at := time.Unix(0, int64(binary.LittleEndian.Uint64(head[0:8])))
+		n := binary.LittleEndian.Uint32(head[8:12])
+		if n > maxRecordLen {
+			return fmt.Errorf("record too large: %d", n)
+		}
+		buf := make([]byte, n)
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return err
+		}
+		parseEvents(buf, func(event Event) bool {
+			event.Pidfd = -1
+			fn(at, event)
+			return true
+		})
+	}
+}
+
+// MaskString 将事件掩码转换为可读的事件类型
+func MaskString(mask uint64) string {
+	return maskToString(mask)
+}
@@ -0,0 +1,146 @@
+package watcher
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// replayed 回放出的事件，路径由 wm 的 handle 解析得到
+type replayed struct {
+	at   time.Time
+	typ  string
+	path string
+}
+
+// replayRecording 回放录制文件 path，用 wm 解析每个事件的 handle；wm 无需 Watch，
+// 只要录制时的文件系统仍挂载、目录仍存在即可解析
+func replayRecording(t *testing.T, wm *Watchman, path string) []replayed {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var events []replayed
+	err = Replay(f, func(at time.Time, event Event) {
+		if event.Pidfd != -1 {
+			t.Errorf("replayed event has pidfd %d", event.Pidfd)
+		}
+		dir, name, ok := wm.resolve(event.Handle)
+		if !ok {
+			t.Errorf("replayed %s event did not resolve", MaskString(event.Mask))
+			return
+		}
+		events = append(events, replayed{at: at, typ: MaskString(event.Mask), path: filepath.Join(dir, name)})
+	})
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	return events
+}
+
+// rawEvent 构造一条 fanotify_event_metadata 及其后的 info 记录
+func rawEvent(mask uint64, pid int32, infos ...[]byte) []byte {
+	buf := make([]byte, EventMetadataLen)
+	buf[4] = unix.FANOTIFY_METADATA_VERSION
+	binary.LittleEndian.PutUint16(buf[6:8], uint16(EventMetadataLen))
+	binary.LittleEndian.PutUint64(buf[8:16], mask)
+	binary.LittleEndian.PutUint32(buf[16:20], ^uint32(0)) // FAN_NOFD
+	binary.LittleEndian.PutUint32(buf[20:24], uint32(pid))
+	for _, info := range infos {
+		buf = append(buf, info...)
+	}
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(buf)))
+	return buf
+}
+
+// recording 以录制文件格式拼接若干次 read 的缓冲区
+func recording(at time.Time, reads ...[]byte) []byte {
+	data := slices.Clone(recordMagic)
+	for _, buf := range reads {
+		data = binary.LittleEndian.AppendUint64(data, uint64(at.UnixNano()))
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(buf)))
+		data = append(data, buf...)
+	}
+	return data
+}
+
+// 录制实时事件后用另一个实例回放，得到与投递相同的事件与路径
+func TestRecordReplay(t *testing.T) {
+	root := t.TempDir()
+	conf := "paths: [" + root + "]\nevents: [CREATE, CLOSE_WRITE, DELETE]"
+	wm := newTestWatchman(t, conf)
+	rec := filepath.Join(t.TempDir(), "events.rec")
+	if err := wm.EnableRecord(rec); err != nil {
+		t.Fatal(err)
+	}
+	wm.dedupKeyMode = KeyPathType
+	sink := runTestWatchman(t, wm)
+	a, b := filepath.Join(root, "a"), filepath.Join(root, "b")
+	writeFile(t, a, "x")
+	writeFile(t, b, "y")
+	if err := os.Remove(a); err != nil {
+		t.Fatal(err)
+	}
+	// 内核会合并同一对象尚未读取的事件，DELETE 可能与 CREATE 等同在一条事件中
+	sink.wait(t, func(info *EventInfo) bool { return info.Mask&unix.FAN_DELETE != 0 && info.Path == a })
+	sink.stop()
+	wm.Stop()
+
+	events := replayRecording(t, newTestWatchman(t, conf), rec)
+	// 投递的事件经过去重，应按顺序出现在回放结果中
+	next := 0
+	for _, info := range sink.snapshot() {
+		i := slices.IndexFunc(events[next:], func(e replayed) bool { return e.typ == info.Type && e.path == info.Path })
+		if i < 0 {
+			t.Fatalf("delivered %s %s not found in replay %v", info.Type, info.Path, events)
+		}
+		next += i + 1
+	}
+}
+
+func TestReplayParse(t *testing.T) {
+	dir := t.TempDir()
+	at := time.Unix(1700000000, 123)
+	first := rawEvent(unix.FAN_CREATE, 10, dfidName(t, unix.FAN_EVENT_INFO_TYPE_DFID_NAME, dir, "a"))
+	second := rawEvent(unix.FAN_CLOSE_WRITE, 11, dfidName(t, unix.FAN_EVENT_INFO_TYPE_DFID_NAME, dir, "b"))
+	third := rawEvent(unix.FAN_DELETE|unix.FAN_ONDIR, 12, dfidName(t, unix.FAN_EVENT_INFO_TYPE_DFID_NAME, dir, "c"))
+	data := recording(at, append(first, second...), third)
+
+	var got []Event
+	err := Replay(bytes.NewReader(data), func(ts time.Time, event Event) {
+		if !ts.Equal(at) {
+			t.Errorf("timestamp %v, want %v", ts, at)
+		}
+		got = append(got, event)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("replayed %d events, want 3", len(got))
+	}
+	for i, want := range []struct {
+		mask  uint64
+		pid   int32
+		isDir bool
+	}{{unix.FAN_CREATE, 10, false}, {unix.FAN_CLOSE_WRITE, 11, false}, {unix.FAN_DELETE | unix.FAN_ONDIR, 12, true}} {
+		if e := got[i]; e.Mask != want.mask || e.Pid != want.pid || e.IsDir != want.isDir || e.Pidfd != -1 || len(e.Handle) == 0 {
+			t.Errorf("event %d = %+v", i, e)
+		}
+	}
+
+	if err := Replay(bytes.NewReader([]byte("not a recording")), func(time.Time, Event) {}); err == nil {
+		t.Error("accepted a file without the recording header")
+	}
+	if err := Replay(bytes.NewReader(data[:len(data)-1]), func(time.Time, Event) {}); err == nil {
+		t.Error("accepted a truncated record")
+	}
+}
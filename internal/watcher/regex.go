@@ -0,0 +1,73 @@
+package watcher
+
+import (
+	"fmt"
+	"regexp"
+	"regexp/syntax"
+	"strings"
+)
+
+// regexMatcher 匹配 match-mode 为 regex 的监控路径，正则作用于完整路径，在 Initialize/ReloadFilter 时编译一次
+type regexMatcher struct {
+	sources []string
+	res     []*regexp.Regexp
+	roots   map[string]string // 原始模式 → 所在目录，见 regexRoot
+}
+
+func newRegexMatcher(patterns []string) (*regexMatcher, error) {
+	m := &regexMatcher{roots: make(map[string]string)}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("watch path regex %q: %w", p, err)
+		}
+		m.sources = append(m.sources, p)
+		m.res = append(m.res, re)
+		m.roots[p] = regexRoot(p)
+	}
+	return m, nil
+}
+
+// match 返回命中的原始模式
+func (m *regexMatcher) match(path string) (string, bool) {
+	for i, re := range m.res {
+		if re.MatchString(path) {
+			return m.sources[i], true
+		}
+	}
+	return "", false
+}
+
+// regexRoot 返回正则可能命中的路径所在的最深目录，用于扫描、挂载标记与相对路径：
+// 以 ^ 锚定时取开头字面量的最后一个 '/' 之前的部分，如 ^/var/log/.*\.log$ → /var/log；否则为 "/"
+func regexRoot(pattern string) string {
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return "/"
+	}
+	re = re.Simplify()
+	subs := []*syntax.Regexp{re}
+	if re.Op == syntax.OpConcat {
+		subs = re.Sub
+	}
+	if len(subs) == 0 || subs[0].Op != syntax.OpBeginText {
+		return "/"
+	}
+	var prefix strings.Builder
+	for _, sub := range subs[1:] {
+		if sub.Op != syntax.OpLiteral || sub.Flags&syntax.FoldCase != 0 {
+			break
+		}
+		for _, r := range sub.Rune {
+			prefix.WriteRune(r)
+		}
+	}
+	p := prefix.String()
+	if !strings.HasPrefix(p, "/") {
+		return "/"
+	}
+	if i := strings.LastIndex(p, "/"); i > 0 {
+		return p[:i]
+	}
+	return "/"
+}
@@ -0,0 +1,68 @@
+package watcher
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRelativeTo(t *testing.T) {
+	tests := []struct {
+		path, rule string
+		root, rel  string
+	}{
+		{"/data", "/data", "/data", "."},
+		{"/data/a/b.txt", "/data", "/data", "a/b.txt"},
+		{"/data/a/b.txt", "/data/", "/data", "a/b.txt"},
+		{"/data/x/in/f", "/data/x/in", "/data/x/in", "f"}, // 通配与正则规则经 ruleBase 换算为实际目录
+		{"/data/logs/a/b.log", "/data/logs/**/*.log", "/data/logs", "a/b.log"},
+		{"/a", "/", "/", "a"},
+	}
+	for _, tt := range tests {
+		if root, rel := relativeTo(tt.path, tt.rule); root != tt.root || rel != tt.rel {
+			t.Errorf("relativeTo(%q, %q) = %q, %q, want %q, %q", tt.path, tt.rule, root, rel, tt.root, tt.rel)
+		}
+	}
+}
+
+// 嵌套的监控路径中，事件相对命中的最内层路径计算，Root/RelPath 经 path-translation 改写
+func TestRelativePathsNestedRoots(t *testing.T) {
+	root := t.TempDir()
+	inner, innermost := filepath.Join(root, "a"), filepath.Join(root, "a", "b", "c")
+	wm := newTestWatchman(t, "paths: ["+root+", "+inner+", "+innermost+"]\nevents: [CREATE]\nevent-targets: both\nrelative-paths: true")
+	sink := runTestWatchman(t, wm)
+	mkdirAll(t, innermost)
+	tests := []struct {
+		path, root, rel string
+	}{
+		{inner, inner, "."}, // 事件路径即监控路径
+		{filepath.Join(root, "x"), root, "x"},
+		{filepath.Join(inner, "x"), inner, "x"},
+		{filepath.Join(inner, "b", "x"), inner, "b/x"},
+		{filepath.Join(innermost, "x"), innermost, "x"},
+		{filepath.Join(innermost, "d", "x"), innermost, "d/x"},
+	}
+	mkdirAll(t, filepath.Join(innermost, "d"))
+	for _, tt := range tests[1:] {
+		writeFile(t, tt.path, "x")
+	}
+	for _, tt := range tests {
+		info := sink.wait(t, func(info *EventInfo) bool { return info.Path == tt.path })
+		if info.Root != tt.root || info.RelPath != tt.rel {
+			t.Errorf("%s: Root %q RelPath %q, want %q %q", tt.path, info.Root, info.RelPath, tt.root, tt.rel)
+		}
+	}
+}
+
+func TestRelativePathsTranslated(t *testing.T) {
+	root := t.TempDir()
+	inner := filepath.Join(root, "a")
+	mkdirAll(t, inner)
+	wm := newTestWatchman(t, "paths: ["+root+", "+inner+"]\nevents: [CREATE]\nrelative-paths: true\n"+
+		"path-translation:\n  strip: "+root+"\n  prepend: /host")
+	sink := runTestWatchman(t, wm)
+	writeFile(t, filepath.Join(inner, "f"), "x")
+	info := sink.wait(t, func(info *EventInfo) bool { return info.Name == "f" })
+	if info.Path != "/host/a/f" || info.Root != "/host/a" || info.RelPath != "f" {
+		t.Errorf("Path %q Root %q RelPath %q", info.Path, info.Root, info.RelPath)
+	}
+}
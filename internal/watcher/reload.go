@@ -0,0 +1,214 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/caoenergy/watchman/internal/settings"
+
+	"golang.org/x/sys/unix"
+)
+
+// ErrPathNotWatched 要移除的路径不在当前监控路径中
+var ErrPathNotWatched = errors.New("path is not watched")
+
+// ReloadFilter 替换监控路径而不重启：先构建新的前缀树与通配、正则匹配器，再在 filterMu 下一次性替换，
+// 处理中的事件只会看到旧规则或新规则。路径先按配置加载时的方式规范化，再做与 Validate 相同的校验
+// (含去重，如 /data 与 /data/ 视为重复)，失败时返回错误，原规则保持不变。
+// inode 标记方式下同步为新增路径添加标记、移除已删除路径的标记；mount 方式为新增路径所在的挂载点添加标记；
+// filesystem 方式为位于尚未标记的文件系统上的新增路径补充标记。
+// follow-symlinks 的链接索引不随之重建。
+func (wm *Watchman) ReloadFilter(paths []settings.WatchPath) error {
+	wm.reloadMu.Lock()
+	defer wm.reloadMu.Unlock()
+	return wm.reloadFilter(paths)
+}
+
+// ReloadConfigPaths 同 ReloadFilter，替换为配置中的监控路径并记录其在配置文件中的写法，供 ConfigPaths 写回
+func (wm *Watchman) ReloadConfigPaths(s *settings.Settings) error {
+	wm.reloadMu.Lock()
+	defer wm.reloadMu.Unlock()
+	if err := wm.reloadFilter(s.Watchman.Watcher.Paths); err != nil {
+		return err
+	}
+	wm.writtenPaths = newWrittenPaths(s.Watchman.Watcher.Paths, s.WrittenPaths())
+	return nil
+}
+
+// ConfigPaths 返回写回配置文件的监控路径：来自 watcher.paths 的保持原写法，运行时追加的按生效形式，
+// 仅由监控组并入的不写回(重启后仍由组并入)
+func (wm *Watchman) ConfigPaths() []settings.WatchPath {
+	wm.reloadMu.Lock()
+	defer wm.reloadMu.Unlock()
+	var paths []settings.WatchPath
+	for _, p := range wm.ExportWatchPaths() {
+		if written, ok := wm.writtenPaths[pathKey(p)]; ok {
+			paths = append(paths, written...)
+		} else {
+			paths = append(paths, p)
+		}
+	}
+	return paths
+}
+
+// newWrittenPaths 按生效路径索引配置文件中的写法，written 与 effective 一一对应，见 settings.WrittenPaths
+func newWrittenPaths(effective, written []settings.WatchPath) map[settings.WatchPath][]settings.WatchPath {
+	m := make(map[settings.WatchPath][]settings.WatchPath, len(effective))
+	for i, p := range effective {
+		k := pathKey(p)
+		if written[i].Path == "" {
+			if _, ok := m[k]; !ok {
+				m[k] = nil // 仅由监控组并入
+			}
+			continue
+		}
+		m[k] = append(m[k], written[i])
+	}
+	return m
+}
+
+// pathKey 匹配方式按推断结果补全，使显式与省略 match-mode 的同一路径得到相同的键
+func pathKey(p settings.WatchPath) settings.WatchPath {
+	return settings.WatchPath{Path: p.Path, MatchMode: p.Mode()}
+}
+
+// AddWatchPath 在当前监控路径中追加一条，校验与生效方式同 ReloadFilter
+func (wm *Watchman) AddWatchPath(path settings.WatchPath) error {
+	wm.reloadMu.Lock()
+	defer wm.reloadMu.Unlock()
+	return wm.reloadFilter(append(wm.ExportWatchPaths(), path))
+}
+
+// RemoveWatchPath 从当前监控路径中移除 path(按规范化后的路径比较，不区分匹配方式)；不在其中时返回 ErrPathNotWatched
+func (wm *Watchman) RemoveWatchPath(path string) error {
+	wm.reloadMu.Lock()
+	defer wm.reloadMu.Unlock()
+	paths := wm.ExportWatchPaths()
+	kept := slices.DeleteFunc(slices.Clone(paths), func(p settings.WatchPath) bool {
+		return p.Path == path || p.Mode() != settings.MatchRegex && p.Path == settings.NormalizePath(path)
+	})
+	if len(kept) == len(paths) {
+		return fmt.Errorf("%w: %s", ErrPathNotWatched, path)
+	}
+	return wm.reloadFilter(kept)
+}
+
+// reloadFilter 调用方持有 reloadMu
+func (wm *Watchman) reloadFilter(paths []settings.WatchPath) error {
+	normalized := slices.Clone(paths)
+	for i, p := range normalized {
+		if p.Mode() != settings.MatchRegex {
+			normalized[i].Path = settings.NormalizePath(p.Path)
+		}
+	}
+	paths = normalized
+	if err := settings.ValidatePaths(paths); err != nil {
+		return err
+	}
+	filter, globFilter, regexFilter, _, err := buildFilter(paths)
+	if err != nil {
+		return err
+	}
+	old := wm.ExportWatchPaths()
+	var added, removed []settings.WatchPath
+	for _, p := range paths {
+		if !slices.Contains(old, p) {
+			added = append(added, p)
+		}
+	}
+	for _, p := range old {
+		if !slices.Contains(paths, p) {
+			removed = append(removed, p)
+		}
+	}
+	switch {
+	case wm.inotify != nil:
+		// 移除的路径保留监视，多出的事件由过滤丢弃
+		for _, p := range added {
+			wm.inotify.addTree(pathRoot(p), false)
+		}
+	case wm.markMode == MarkModeInode:
+		if err := wm.remark(added, removed); err != nil {
+			return err
+		}
+	case wm.markMode == MarkModeMount:
+		// 已标记的挂载点重复标记无副作用；移除的路径所在挂载可能仍被其他路径使用，保留其标记，多出的事件由过滤丢弃
+		roots := make([]string, len(added))
+		for i, p := range added {
+			roots[i] = pathRoot(p)
+		}
+		if err := addMountMarks(wm.ffd, roots, wm.markEvents&mountEvents, wm.mounts); err != nil {
+			return err
+		}
+	default:
+		roots := make([]string, len(added))
+		for i, p := range added {
+			roots[i] = pathRoot(p)
+		}
+		if err := addFilesystemMarks(wm.ffd, roots, wm.markEvents, wm.mounts); err != nil {
+			return err
+		}
+	}
+	wm.filterMu.Lock()
+	wm.filter, wm.globFilter, wm.regexFilter = filter, globFilter, regexFilter
+	wm.filterMu.Unlock()
+	slog.Info("watch paths reloaded", "added", added, "removed", removed)
+	warnMissing(added)
+	return nil
+}
+
+// ReloadExclude 替换排除规则，与 ReloadFilter 一样在 filterMu 下一次性替换；校验失败时原规则保持不变
+func (wm *Watchman) ReloadExclude(exclude []string) error {
+	if err := settings.ValidateExclude(exclude); err != nil {
+		return err
+	}
+	normalized := make([]string, len(exclude))
+	for i, p := range exclude {
+		if filepath.IsAbs(p) {
+			p = settings.NormalizePath(p)
+		}
+		normalized[i] = p
+	}
+	tree, names := splitExclude(normalized)
+	wm.filterMu.Lock()
+	wm.excludeFilter, wm.excludeNames = tree, names
+	wm.filterMu.Unlock()
+	slog.Info("exclude rules reloaded", "exclude", normalized)
+	return nil
+}
+
+// remark 为新增路径添加 inode 标记，任一失败时撤销已添加的标记；全部成功后再移除已删除路径的标记
+func (wm *Watchman) remark(added, removed []settings.WatchPath) error {
+	for _, p := range added {
+		if p.Mode() != settings.MatchPrefix {
+			return fmt.Errorf("watchman.watcher.paths pattern %s is not supported with mark-mode inode", p.Path)
+		}
+	}
+	for i, wp := range added {
+		p := wp.Path
+		fi, err := os.Stat(p)
+		if err == nil {
+			err = unix.FanotifyMark(wm.ffd, unix.FAN_MARK_ADD, markMask(wm.markEvents, fi.IsDir()), unix.AT_FDCWD, p)
+		}
+		if err != nil {
+			for _, q := range added[:i] {
+				_ = unix.FanotifyMark(wm.ffd, unix.FAN_MARK_REMOVE, markMask(wm.markEvents, true), unix.AT_FDCWD, q.Path)
+			}
+			return fmt.Errorf("mark %s: %w", p, err)
+		}
+		if err := wm.mounts.add(p); err != nil {
+			slog.Warn("failed to open the new path's filesystem, its events may not resolve", "path", p, "err", err)
+		}
+	}
+	for _, p := range removed {
+		// 移除时内核只清除掩码中的位，按目录掩码移除即可覆盖单文件的标记；路径已不存在时标记已随 inode 释放
+		if err := unix.FanotifyMark(wm.ffd, unix.FAN_MARK_REMOVE, markMask(wm.markEvents, true), unix.AT_FDCWD, p.Path); err != nil {
+			slog.Warn("failed to remove inode mark", "path", p.Path, "err", err)
+		}
+	}
+	return nil
+}
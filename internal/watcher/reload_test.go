@@ -0,0 +1,59 @@
+package watcher
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/caoenergy/watchman/internal/settings"
+)
+
+// 写回配置文件的路径保持 watcher.paths 的原写法，不含监控组并入的路径与解析符号链接后的路径
+func TestConfigPaths(t *testing.T) {
+	root := t.TempDir()
+	real, grp, link := filepath.Join(root, "real"), filepath.Join(root, "grp"), filepath.Join(root, "link")
+	mkdirAll(t, real)
+	mkdirAll(t, grp)
+	if err := os.Symlink(real, link); err != nil {
+		t.Fatal(err)
+	}
+	conf := "watchman:\n  watcher:\n    resolve-symlinks: true\n    paths: [" + link + "/]\n" +
+		"  watch-groups:\n    - name: g\n      paths: [" + grp + "]\n"
+	if err := os.WriteFile(filepath.Join(root, "watchman.yml"), []byte(conf), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONF_DIR", root)
+	s, err := settings.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Watchman.Watcher.Backend = BackendFanotify
+	wm, err := Initialize(s)
+	if err != nil {
+		if fanotifyUnavailable(errors.Unwrap(err)) || fanotifyUnavailable(err) {
+			t.Skipf("fanotify unavailable: %v", err)
+		}
+		t.Fatal(err)
+	}
+	t.Cleanup(wm.Stop)
+
+	extra := filepath.Join(root, "extra")
+	if err := wm.AddWatchPath(settings.WatchPath{Path: extra + "/"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := wm.ExportPaths(); !slices.Equal(got, []string{extra, grp, real}) {
+		t.Fatalf("effective paths %v", got)
+	}
+	want := []settings.WatchPath{{Path: extra}, {Path: link + "/"}}
+	if got := wm.ConfigPaths(); !slices.Equal(got, want) {
+		t.Fatalf("config paths %v, want %v", got, want)
+	}
+	if err := wm.RemoveWatchPath(real); err != nil {
+		t.Fatal(err)
+	}
+	if got := wm.ConfigPaths(); !slices.Equal(got, want[:1]) {
+		t.Fatalf("config paths after remove %v, want %v", got, want[:1])
+	}
+}
@@ -0,0 +1,45 @@
+package watcher
+
+import (
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// pairRename 处理 FAN_RENAME：一个事件同时携带原位置与新位置（fanotify 没有 inotify 的 cookie，无需按窗口配对）。
+// 两端都命中监控规则时作为 RENAME 投递（Path 为新路径，OldPath 为原路径）；只有一端命中时退化为
+// 该端单独的 MOVED_TO（从监控范围外移入）或 MOVED_FROM（移出到监控范围外，Path 为原路径）。
+func (wm *Watchman) pairRename(event *Event, fullPath, rule string, matched bool) (path, dir, name, oldPath, matchedRule string, ok bool, mask uint64) {
+	path, dir, name, matchedRule, ok, mask = fullPath, filepath.Dir(fullPath), filepath.Base(fullPath), rule, matched, event.Mask
+	if event.OldHandle != nil {
+		if d, n, resolved := wm.resolve(event.OldHandle); resolved && d != "" && n != "" {
+			oldPath = filepath.Join(d, n)
+		}
+	}
+	oldRule, oldMatched := "", false
+	if oldPath != "" {
+		oldRule, oldMatched = wm.matchPath(oldPath, filepath.Base(oldPath), event.IsDir)
+	}
+	// 内核在同一事件中给出两端，配对窗口为 0
+	switch {
+	case matched && oldMatched:
+		logPairing("paired", 0, oldPath, path, 0)
+		return path, dir, name, oldPath, matchedRule, true, mask
+	case matched:
+		logPairing("unpaired", 0, oldPath, path, 0)
+		return path, dir, name, "", matchedRule, true, mask&^unix.FAN_RENAME | unix.FAN_MOVED_TO
+	case oldMatched:
+		logPairing("unpaired", 0, oldPath, path, 0)
+		return oldPath, filepath.Dir(oldPath), filepath.Base(oldPath), "", oldRule, true, mask&^unix.FAN_RENAME | unix.FAN_MOVED_FROM
+	}
+	return path, dir, name, "", matchedRule, false, mask
+}
+
+// logPairing 调试日志：记录一次重命名的配对结果(paired 投递为 RENAME，unpaired 只有一端在监控范围内或窗口内没有另一端)；
+// cookie 为 0 表示由 FAN_RENAME 配对，原路径未知时 old_path 为空
+func logPairing(outcome string, cookie uint32, oldPath, path string, window time.Duration) {
+	slog.Debug("rename pairing", "outcome", outcome, "cookie", cookie, "old_path", oldPath, "path", path,
+		"window_ms", window.Milliseconds())
+}
@@ -0,0 +1,52 @@
+package watcher
+
+import (
+	"sync/atomic"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	minResolveInflight = 16
+	maxResolveInflight = 1024
+)
+
+// resolveLimiter 限制同时进行的 OpenByHandleAt 数量，每个 resolve 在打开到关闭期间占用一个 fd，
+// 并发解析时如不加限制，突发事件可能耗尽 RLIMIT_NOFILE。
+type resolveLimiter struct {
+	sem         chan struct{}
+	inflight    atomic.Int64
+	maxInflight atomic.Int64 // 观察到的最大并发
+}
+
+func newResolveLimiter(limit int) *resolveLimiter {
+	if limit <= 0 {
+		limit = defaultResolveInflight()
+	}
+	return &resolveLimiter{sem: make(chan struct{}, limit)}
+}
+
+// defaultResolveInflight 取 RLIMIT_NOFILE 软限制的四分之一，为其他 fd 留出余量
+func defaultResolveInflight() int {
+	var rl unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_NOFILE, &rl); err != nil {
+		return minResolveInflight
+	}
+	return int(min(max(rl.Cur/4, minResolveInflight), maxResolveInflight))
+}
+
+func (l *resolveLimiter) acquire() {
+	l.sem <- struct{}{}
+	n := l.inflight.Add(1)
+	for {
+		peak := l.maxInflight.Load()
+		if n <= peak || l.maxInflight.CompareAndSwap(peak, n) {
+			return
+		}
+	}
+}
+
+func (l *resolveLimiter) release() {
+	l.inflight.Add(-1)
+	<-l.sem
+}
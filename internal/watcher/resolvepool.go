@@ -0,0 +1,71 @@
+package watcher
+
+import (
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/sys/unix"
+)
+
+// resolution 预先解析的结果，见 prefetch
+type resolution struct {
+	dir, name string
+	ok        bool
+}
+
+// resolveBatch 在 captureEvents 中解析一批 fanotify 事件。目录被移动后须先失效缓存再解析其后的事件，
+// 否则它们仍从缓存解析为旧路径；processEvents 处理到移动事件时，后续批次往往已被 prefetch 解析，失效只能在这里做。
+// 截至最后一个目录移动事件的部分按顺序逐个解析并失效，其余部分交给 prefetch 并行解析
+func (wm *Watchman) resolveBatch(batch []Event) {
+	last := -1
+	for i := range batch {
+		if movedDir(&batch[i]) {
+			last = i
+		}
+	}
+	for i := range batch[:last+1] {
+		event := &batch[i]
+		r := &resolution{}
+		r.dir, r.name, r.ok = wm.resolve(event.Handle)
+		event.resolved = r
+		if r.ok && r.dir != "" && movedDir(event) {
+			wm.invalidateMovedDir(event, filepath.Join(r.dir, r.name))
+		}
+	}
+	if rest := batch[last+1:]; wm.resolveWorkers > 1 && len(rest) > 1 {
+		wm.prefetch(rest)
+	}
+}
+
+// movedDir 是否为目录的移动事件
+func movedDir(event *Event) bool {
+	return event.IsDir && event.Mask&(unix.FAN_MOVED_FROM|unix.FAN_MOVED_TO|unix.FAN_RENAME) != 0
+}
+
+// prefetch 用 resolveWorkers 个 goroutine 并行解析一批事件的 handle，结果写回各事件。
+// resolve 受 OpenByHandleAt/readlink 系统调用限制，并行可提升突发时的吞吐；
+// 事件随后仍按原顺序送入 eventChan，不影响投递顺序。
+func (wm *Watchman) prefetch(events []Event) {
+	results := make([]resolution, len(events))
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	for range min(wm.resolveWorkers, len(events)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1)) - 1
+				if i >= len(events) {
+					return
+				}
+				r := &results[i]
+				r.dir, r.name, r.ok = wm.resolve(events[i].Handle)
+			}
+		}()
+	}
+	wg.Wait()
+	for i := range events {
+		events[i].resolved = &results[i]
+	}
+}
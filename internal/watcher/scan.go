@@ -0,0 +1,154 @@
+package watcher
+
+import (
+	"context"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	ScanInitial  = "initial"
+	ScanOverflow = "overflow"
+)
+
+// scanner 遍历监控目录，为已有文件合成 CREATE 事件(Attrs 含 synthetic、scan)，用于启动时建立基线
+// 或内核队列溢出后对账。合成事件按 rate 限速、每次遍历最多 maxEvents 个，经 synthChan 投递，
+// synthChan 满时遍历随之阻塞，不会挤占内核事件的处理。
+type scanner struct {
+	initial   bool
+	rescan    chan struct{} // 溢出时非阻塞写入，遍历期间的多次溢出合并为一次
+	maxEvents int
+	rate      int
+}
+
+func newScanner(initial, onOverflow bool, maxEvents, rate int) *scanner {
+	if !initial && !onOverflow {
+		return nil
+	}
+	s := &scanner{initial: initial, maxEvents: maxEvents, rate: rate}
+	if onOverflow {
+		s.rescan = make(chan struct{}, 1)
+	}
+	return s
+}
+
+// overflowed 请求一次溢出后的遍历，未开启 on-overflow 时忽略
+func (s *scanner) overflowed() {
+	if s == nil || s.rescan == nil {
+		return
+	}
+	select {
+	case s.rescan <- struct{}{}:
+	default:
+	}
+}
+
+func (wm *Watchman) runScanner(ctx context.Context) {
+	s := wm.scanner
+	if s.initial {
+		wm.scan(ctx, ScanInitial)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.rescan:
+			wm.scan(ctx, ScanOverflow)
+		}
+	}
+}
+
+// scan 遍历当前生效的监控路径；通配与正则路径从其所在目录(见 pathRoot)开始，逐个文件按规则匹配
+func (wm *Watchman) scan(ctx context.Context, reason string) {
+	s := wm.scanner
+	start := wm.clock.Now()
+	windowStart, inWindow := start, 0
+	emitted, truncated := 0, false
+	for _, p := range wm.ExportWatchPaths() {
+		_ = filepath.WalkDir(pathRoot(p), func(path string, d fs.DirEntry, err error) error {
+			if ctx.Err() != nil {
+				return fs.SkipAll
+			}
+			if err != nil || d.IsDir() && (!wm.dirs || path == pathRoot(p)) || !d.IsDir() && !wm.files {
+				return nil
+			}
+			info := wm.scanned(path, reason, d.IsDir())
+			if info == nil {
+				return nil
+			}
+			if emitted >= s.maxEvents {
+				truncated = true
+				return fs.SkipAll
+			}
+			// 每秒最多 rate 个：当前窗口用完后等到窗口结束
+			if inWindow >= s.rate {
+				if wait := time.Second - wm.clock.Now().Sub(windowStart); wait > 0 {
+					select {
+					case <-ctx.Done():
+						return fs.SkipAll
+					case <-wm.clock.After(wait):
+					}
+				}
+				windowStart, inWindow = wm.clock.Now(), 0
+			}
+			select {
+			case <-ctx.Done():
+				return fs.SkipAll
+			case wm.synthChan <- info:
+			}
+			emitted++
+			inWindow++
+			return nil
+		})
+		if truncated || ctx.Err() != nil {
+			break
+		}
+	}
+	elapsed := wm.clock.Now().Sub(start)
+	switch {
+	case ctx.Err() != nil:
+		slog.Info("scan cancelled", "reason", reason, "events", emitted, "elapsed", elapsed)
+	case truncated:
+		slog.Warn("scan truncated, max-events reached; remaining files were not reported",
+			"reason", reason, "max_events", s.maxEvents, "elapsed", elapsed)
+	default:
+		slog.Info("scan finished", "reason", reason, "events", emitted, "elapsed", elapsed)
+	}
+}
+
+// scanned 为遍历到的文件或目录构造合成事件，与内核事件经过相同的路径规则、表达式过滤和改写；不命中时返回 nil
+func (wm *Watchman) scanned(path, reason string, isDir bool) *EventInfo {
+	if wm.self.has(path) {
+		return nil
+	}
+	dir, name := filepath.Dir(path), filepath.Base(path)
+	rule, matched := wm.matchPath(path, name, isDir)
+	if !matched || (wm.maxDepth > 0 && rule != "" && relativeDepth(path, wm.ruleBase(rule)) > wm.maxDepth) {
+		return nil
+	}
+	mask := uint64(unix.FAN_CREATE)
+	if isDir {
+		mask |= unix.FAN_ONDIR
+	}
+	info := &EventInfo{
+		Type:  maskToString(mask),
+		Dir:   dir,
+		Name:  name,
+		Path:  path,
+		IsDir: isDir,
+		Mask:  mask,
+		Time:  wm.clock.Now(),
+
+		MatchedRule: rule,
+	}
+	if !wm.decorate(info, rule) {
+		return nil
+	}
+	info.SetAttr("synthetic", true)
+	info.SetAttr("scan", reason)
+	return info
+}
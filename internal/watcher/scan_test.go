@@ -0,0 +1,37 @@
+package watcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 启动遍历与事件循环同时经过 filter-expr，配合 -race 运行
+func TestScanWithExprFilter(t *testing.T) {
+	const n = 100
+	root := t.TempDir()
+	for i := range n {
+		writeFile(t, filepath.Join(root, fmt.Sprintf("old%d.log", i)), "x")
+		writeFile(t, filepath.Join(root, fmt.Sprintf("old%d.txt", i)), "x")
+	}
+	wm := newTestWatchman(t, "paths: ["+root+"]\nevents: [CLOSE_WRITE]\nfilter-expr: 'Name endsWith \".log\"'\n"+
+		"scan:\n  initial: true\n  rate: 100000")
+	sink := runTestWatchman(t, wm)
+	for i := range n {
+		writeFile(t, filepath.Join(root, fmt.Sprintf("new%d.log", i)), "x")
+		writeFile(t, filepath.Join(root, fmt.Sprintf("new%d.txt", i)), "x")
+	}
+	// 遍历合成的与写入产生的 .log 事件都应投递
+	for _, prefix := range []string{"old", "new"} {
+		for i := range n {
+			name := fmt.Sprintf("%s%d.log", prefix, i)
+			sink.wait(t, func(info *EventInfo) bool { return info.Name == name })
+		}
+	}
+	for _, info := range sink.snapshot() {
+		if !strings.HasSuffix(info.Name, ".log") {
+			t.Fatalf("%s %s passed the filter", info.Type, info.Path)
+		}
+	}
+}
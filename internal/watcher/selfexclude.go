@@ -0,0 +1,39 @@
+package watcher
+
+import (
+	"log/slog"
+	"path/filepath"
+	"sync"
+)
+
+// selfExclude watchman 自身写入的文件(file sink 输出、录制文件等)。这些文件位于监控目录下时，
+// 每次写入都会产生事件，file sink 还会因此不断写入自身形成反馈，需在匹配前丢弃。
+type selfExclude struct {
+	mu    sync.RWMutex
+	paths map[string]struct{}
+}
+
+func (s *selfExclude) add(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.paths == nil {
+		s.paths = make(map[string]struct{})
+	}
+	s.paths[path] = struct{}{}
+	return path
+}
+
+func (s *selfExclude) has(path string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.paths[path]
+	return ok
+}
+
+// ExcludeSelf 登记 watchman 自身写入的文件，其事件不再上报
+func (wm *Watchman) ExcludeSelf(path string) {
+	slog.Info("internal file excluded from events", "path", wm.self.add(path))
+}
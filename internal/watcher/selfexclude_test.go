@@ -0,0 +1,54 @@
+package watcher
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/caoenergy/watchman/internal/retry"
+)
+
+// 位于监控目录下的内部文件(数据库及其 WAL、录制文件、死信文件)的写入不产生事件
+func TestSelfExcludedFilesEmitNothing(t *testing.T) {
+	root := t.TempDir()
+	wm := newTestWatchman(t, "paths: ["+root+"]\nevents: [CREATE, MODIFY, CLOSE_WRITE, DELETE]")
+	wm.dedupKeyMode = KeyPathType
+	db, wal := filepath.Join(root, "audit.db"), filepath.Join(root, "audit.db-wal")
+	wm.ExcludeSelf(db)
+	wm.ExcludeSelf(wal)
+	rec, deadLetter := filepath.Join(root, "events.rec"), filepath.Join(root, "dead.jsonl")
+	if err := wm.EnableRecord(rec); err != nil {
+		t.Fatal(err)
+	}
+	if err := wm.SetPluginQueue(1, retry.DefaultPolicy(), deadLetter); err != nil {
+		t.Fatal(err)
+	}
+	internal := map[string]bool{db: true, wal: true, rec: true, deadLetter: true}
+	sink := runTestWatchman(t, wm)
+
+	for i := range 50 {
+		for _, path := range []string{db, wal, deadLetter} {
+			f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fmt.Fprintf(f, "%d\n", i)
+			_ = f.Close()
+		}
+	}
+	if err := os.Remove(wal); err != nil {
+		t.Fatal(err)
+	}
+	// 以普通文件的事件作为内部文件事件已处理完的标记
+	marker := filepath.Join(root, "marker")
+	writeFile(t, marker, "x")
+	sink.wait(t, func(info *EventInfo) bool { return info.Path == marker })
+	sink.stop()
+	wm.Stop() // 录制文件在关闭时写入剩余缓冲
+	for _, info := range sink.snapshot() {
+		if internal[info.Path] {
+			t.Errorf("internal file emitted %s %s", info.Type, info.Path)
+		}
+	}
+}
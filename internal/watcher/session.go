@@ -0,0 +1,42 @@
+package watcher
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/caoenergy/watchman/internal/settings"
+)
+
+const (
+	EventSessionStart = "SESSION_START"
+	EventSessionEnd   = "SESSION_END"
+)
+
+// session 会话标记事件的公共属性。消费方按 session_id 关联同一次运行的首尾，
+// 按 config_hash 判断配置是否变化。
+type session struct {
+	attrs map[string]any
+}
+
+func newSession(s *settings.Settings) *session {
+	var id [8]byte
+	_, _ = rand.Read(id[:])
+	return &session{attrs: map[string]any{
+		"synthetic":   true,
+		"session_id":  hex.EncodeToString(id[:]),
+		"instance":    s.Watchman.Instance,
+		"pid":         os.Getpid(),
+		"config_hash": s.Fingerprint(),
+		"event_types": slices.Clone(settings.EventTypes),
+	}}
+}
+
+// emitSession 投递会话标记事件，跳过过滤与去重；监控路径取当前生效的路径
+func (wm *Watchman) emitSession(eventType string) {
+	attrs := maps.Clone(wm.session.attrs)
+	attrs["paths"] = wm.ExportPaths()
+	wm.dispatch(&EventInfo{Type: eventType, Pid: int32(os.Getpid()), Time: wm.clock.Now(), Attrs: attrs})
+}
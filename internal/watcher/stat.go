@@ -0,0 +1,61 @@
+package watcher
+
+import (
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// FileStat 事件发生后读取到的文件元数据，只反映读取时刻的状态，与事件之间可能已被再次修改
+type FileStat struct {
+	Size  int64
+	Mode  uint32 // st_mode，含文件类型位
+	Uid   uint32 // 文件属主，区别于 EventInfo.Uid(触发进程)
+	Gid   uint32
+	Mtime time.Time
+	// 所在设备与 inode 号，同一文件的硬链接相同
+	Dev, Ino uint64
+}
+
+// EnrichedListener 需要文件元数据的监听器，调用约束同 Listener。
+// 文件已不存在(DELETE、MOVED_FROM 等，或读取前已被删除)时 st 为 nil。
+type EnrichedListener func(info *EventInfo, st *FileStat)
+
+// AddEnrichedListener 注册 EnrichedListener。每个事件最多 lstat 一次，由同一事件的多个 EnrichedListener 共用，
+// 只注册普通监听器时没有额外的系统调用
+func (wm *Watchman) AddEnrichedListener(identify string, listener EnrichedListener) {
+	wm.AddEventListener(identify, func(info *EventInfo) {
+		listener(info, info.FileStat())
+	})
+}
+
+// FileStat 返回事件路径(改写前，见 HostPath)的元数据(不跟随符号链接)，首次调用时读取，之后返回缓存的结果；文件不存在时为 nil
+func (e *EventInfo) FileStat() *FileStat {
+	if !e.statDone {
+		e.stat, e.statDone = lstat(e), true
+	}
+	return e.stat
+}
+
+func lstat(info *EventInfo) *FileStat {
+	const gone = unix.FAN_DELETE | unix.FAN_DELETE_SELF | unix.FAN_MOVED_FROM
+	path := info.HostPath()
+	if path == "" || info.Mask&gone != 0 && info.Mask&^(gone|unix.FAN_ONDIR|unix.FAN_EVENT_ON_CHILD) == 0 {
+		// 仅有删除或移出的事件，文件已不在该路径，省去一次注定失败的系统调用
+		return nil
+	}
+	var st unix.Stat_t
+	if err := unix.Fstatat(unix.AT_FDCWD, path, &st, unix.AT_SYMLINK_NOFOLLOW); err != nil {
+		// ENOENT 等：读取前已被删除或移走
+		return nil
+	}
+	return &FileStat{
+		Size:  st.Size,
+		Mode:  st.Mode,
+		Uid:   st.Uid,
+		Gid:   st.Gid,
+		Mtime: time.Unix(st.Mtim.Unix()),
+		Dev:   st.Dev,
+		Ino:   st.Ino,
+	}
+}
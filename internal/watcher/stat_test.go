@@ -0,0 +1,22 @@
+package watcher
+
+import (
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// 开启 path-translation 时 Path 在本机不存在，FileStat 应读取改写前的路径
+func TestFileStatUsesHostPath(t *testing.T) {
+	host := filepath.Join(t.TempDir(), "f")
+	writeFile(t, host, "hello")
+	info := &EventInfo{Path: "/translated/f", Mask: unix.FAN_CLOSE_WRITE, origPath: host}
+	st := info.FileStat()
+	if st == nil || st.Size != 5 {
+		t.Fatalf("FileStat() = %+v, want size 5", st)
+	}
+	if gone := (&EventInfo{Path: host, Mask: unix.FAN_DELETE}).FileStat(); gone != nil {
+		t.Fatalf("FileStat() of DELETE = %+v, want nil", gone)
+	}
+}
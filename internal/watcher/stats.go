@@ -0,0 +1,292 @@
+package watcher
+
+import (
+	"fmt"
+	"log/slog"
+	"maps"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats 运行时统计快照
+type Stats struct {
+	Captured   uint64 `json:"captured"`   // 从 fanotify 读取的事件数
+	Overflows  uint64 `json:"overflows"`  // 内核队列溢出次数
+	Filtered   uint64 `json:"filtered"`   // 未命中监控规则或被过滤器丢弃的事件数
+	Deduped    uint64 `json:"deduped"`    // 被路径缓存去重的事件数
+	Dispatched uint64 `json:"dispatched"` // 投递给监听器的事件数
+	Bulked     uint64 `json:"bulked"`     // 批量模式下汇总为 BULK_CHANGE、未单独投递的事件数
+	Debounced  uint64 `json:"debounced"`  // 安静期内被同路径同类型的后续事件替换、未投递的事件数
+	// BulkDirs 当前处于批量模式的目录
+	BulkDirs []string `json:"bulk_dirs,omitempty"`
+	QueueLen int      `json:"queue_len"` // eventChan 当前积压
+	QueueCap int      `json:"queue_cap"` // eventChan 容量
+	BufferKB int      `json:"buffer_kb"` // 当前读缓冲区大小，见 watcher.buffer-max-kb
+	// 缓存当前条目数：handle→path(fd-size 为上限)与路径去重(fp-size 为上限)
+	FdCacheLen int `json:"fd_cache_len"`
+	FpCacheLen int `json:"fp_cache_len"`
+	Listeners  int `json:"listeners"` // 已注册的监听器数，含插件与 groups 的 sink
+	// ResolveInflight 正在进行的 OpenByHandleAt 数，ResolveMaxInflight 为观察到的最大值，ResolveLimit 为上限
+	ResolveInflight    int64 `json:"resolve_inflight"`
+	ResolveMaxInflight int64 `json:"resolve_max_inflight"`
+	ResolveLimit       int   `json:"resolve_limit"`
+	// handle→path 缓存命中/未命中次数；每次未命中都会 OpenByHandleAt 打开并关闭一个 fd，
+	// ResolveOpenErrors 为打开失败次数(含 EMFILE)。未命中率高说明 fd-size 偏小或 fd-ttl 偏短
+	ResolveCacheHits   uint64 `json:"resolve_cache_hits"`
+	ResolveCacheMisses uint64 `json:"resolve_cache_misses"`
+	ResolveOpenErrors  uint64 `json:"resolve_open_errors"`
+	// ResolveNegativeHits 命中失败缓存(见 cache.fd-negative-ttl-ms)而跳过打开的次数，不计入命中与未命中
+	ResolveNegativeHits uint64  `json:"resolve_negative_hits"`
+	ResolveMissRate     float64 `json:"resolve_miss_rate"`     // 累计未命中占比
+	ResolveOpensPerSec  float64 `json:"resolve_opens_per_sec"` // 最近一个采样区间(>=1s)内每秒打开的 fd 数
+	// ResolveStale 打开失败中 ESTALE/ENOENT(对象已删除)的次数，其余打开失败另有告警日志
+	ResolveStale uint64 `json:"resolve_stale"`
+	// LostDeletes handle 已失效且缓存中没有路径而无法投递的删除类事件数，每次都会请求 on-overflow 遍历
+	LostDeletes uint64 `json:"lost_deletes"`
+	// 去重缓存(fpcManager)命中/未命中次数；命中不一定被去重，自适应模式下还要比较抑制窗口
+	DedupCacheHits   uint64 `json:"dedup_cache_hits"`
+	DedupCacheMisses uint64 `json:"dedup_cache_misses"`
+	// ByType 按事件类型统计已投递事件，组合类型分别计数
+	ByType map[string]uint64 `json:"by_type"`
+	// ByPrefix 按命中的监控路径统计已投递事件，只统计配置中的路径
+	ByPrefix map[string]uint64 `json:"by_prefix"`
+	// AdaptiveTTL 自适应去重模式下当前各路径抑制窗口的分布
+	AdaptiveTTL map[string]int `json:"adaptive_ttl,omitempty"`
+	// ListenerErrors 按监听器注册名、再按事件命中的监控路径统计失败次数(含监听器 panic 与 sink 上报的投递失败)，
+	// 用于定位只在某个目录下失败的 sink；仅由文件名规则命中的事件计在空串下
+	ListenerErrors map[string]map[string]uint64 `json:"listener_errors,omitempty"`
+	// ListenerLastErrors 各监听器最近一次失败，配合 ListenerErrors 判断失败是否仍在持续
+	ListenerLastErrors map[string]ListenerError `json:"listener_last_errors,omitempty"`
+	// Sinks 各 sink 自行上报的状态（如熔断状态、丢弃数），键为注册名
+	Sinks map[string]any `json:"sinks,omitempty"`
+}
+
+type stats struct {
+	captured   atomic.Uint64
+	overflows  atomic.Uint64
+	filtered   atomic.Uint64
+	deduped    atomic.Uint64
+	dispatched atomic.Uint64
+	bulked     atomic.Uint64
+	debounced  atomic.Uint64
+
+	resolveHits   atomic.Uint64
+	resolveMisses atomic.Uint64
+	resolveErrors atomic.Uint64
+	resolveNeg    atomic.Uint64
+	resolveStale  atomic.Uint64
+	lostDeletes   atomic.Uint64
+	opensRate     rateMeter
+	dedupHits     atomic.Uint64
+	dedupMisses   atomic.Uint64
+
+	mu       sync.Mutex
+	byType   map[string]uint64
+	byPrefix map[string]uint64
+	errors   map[string]map[string]uint64 // 监听器 → 命中规则 → 失败次数
+	last     map[string]ListenerError
+	sources  map[string]func() any
+}
+
+func newStats() *stats {
+	return &stats{byType: make(map[string]uint64), byPrefix: make(map[string]uint64), sources: make(map[string]func() any)}
+}
+
+// recordDispatch 记录一次投递；eventType 只会是 maskToString 生成的已知类型，rule 为配置中的监控路径，内存有界
+func (s *stats) recordDispatch(eventType, rule string) {
+	s.dispatched.Add(1)
+	s.mu.Lock()
+	for _, t := range strings.Split(eventType, "|") {
+		s.byType[t]++
+	}
+	if rule != "" {
+		s.byPrefix[rule]++
+	}
+	s.mu.Unlock()
+}
+
+// ListenerError 监听器的一次失败
+type ListenerError struct {
+	Error string    `json:"error"`
+	Path  string    `json:"path,omitempty"` // 失败事件的路径，攒批监听器为空
+	Time  time.Time `json:"time"`
+}
+
+// recordListenerError 记录监听器处理 info 的一次失败(cause 为返回的错误或 panic 值)；计数键为注册名与事件命中的
+// 配置中的监控路径，内存有界。info 为 nil 表示整批失败
+func (s *stats) recordListenerError(identify string, info *EventInfo, cause any, at time.Time) {
+	var rule, path string
+	if info != nil {
+		rule, path = info.MatchedRule, info.Path
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.errors == nil {
+		s.errors = make(map[string]map[string]uint64)
+		s.last = make(map[string]ListenerError)
+	}
+	s.last[identify] = ListenerError{Error: fmt.Sprint(cause), Path: path, Time: at}
+	byRule := s.errors[identify]
+	if byRule == nil {
+		byRule = make(map[string]uint64)
+		s.errors[identify] = byRule
+	}
+	byRule[rule]++
+}
+
+func (s *stats) snapshot(now time.Time) Stats {
+	hits, misses := s.resolveHits.Load(), s.resolveMisses.Load()
+	var missRate float64
+	if hits+misses > 0 {
+		missRate = float64(misses) / float64(hits+misses)
+	}
+	s.mu.Lock()
+	byType, byPrefix := maps.Clone(s.byType), maps.Clone(s.byPrefix)
+	sources := maps.Clone(s.sources)
+	var listenerErrors map[string]map[string]uint64
+	if len(s.errors) > 0 {
+		listenerErrors = make(map[string]map[string]uint64, len(s.errors))
+		for name, byRule := range s.errors {
+			listenerErrors[name] = maps.Clone(byRule)
+		}
+	}
+	lastErrors := maps.Clone(s.last)
+	s.mu.Unlock()
+	var sinks map[string]any
+	if len(sources) > 0 {
+		sinks = make(map[string]any, len(sources))
+		for name, fn := range sources {
+			sinks[name] = fn()
+		}
+	}
+	return Stats{
+		Sinks:      sinks,
+		Captured:   s.captured.Load(),
+		Overflows:  s.overflows.Load(),
+		Filtered:   s.filtered.Load(),
+		Deduped:    s.deduped.Load(),
+		Dispatched: s.dispatched.Load(),
+		Bulked:     s.bulked.Load(),
+		Debounced:  s.debounced.Load(),
+		ByType:     byType,
+		ByPrefix:   byPrefix,
+
+		ListenerErrors:     listenerErrors,
+		ListenerLastErrors: lastErrors,
+
+		ResolveCacheHits:    hits,
+		ResolveCacheMisses:  misses,
+		ResolveOpenErrors:   s.resolveErrors.Load(),
+		ResolveNegativeHits: s.resolveNeg.Load(),
+		ResolveStale:        s.resolveStale.Load(),
+		LostDeletes:         s.lostDeletes.Load(),
+		ResolveMissRate:     missRate,
+		ResolveOpensPerSec:  s.opensRate.rate(misses, now),
+		DedupCacheHits:      s.dedupHits.Load(),
+		DedupCacheMisses:    s.dedupMisses.Load(),
+	}
+}
+
+// rateMeter 根据累计计数计算每秒速率。多个调用方并发采样时，间隔不足 1 秒返回上次的结果，
+// 避免相邻两次采样之间的抖动。
+type rateMeter struct {
+	mu     sync.Mutex
+	last   uint64
+	lastAt time.Time
+	value  float64
+}
+
+func (m *rateMeter) rate(total uint64, now time.Time) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.lastAt.IsZero() {
+		m.last, m.lastAt = total, now
+		return 0
+	}
+	if elapsed := now.Sub(m.lastAt); elapsed >= time.Second {
+		m.value = float64(total-m.last) / elapsed.Seconds()
+		m.last, m.lastAt = total, now
+	}
+	return m.value
+}
+
+// Stats 返回统计快照，可与事件循环并发调用
+func (wm *Watchman) Stats() Stats {
+	st := wm.stats.snapshot(wm.clock.Now())
+	st.QueueLen = len(wm.eventChan)
+	st.QueueCap = cap(wm.eventChan)
+	st.BufferKB = int(wm.readBuf.size.Load() / 1024)
+	st.FdCacheLen = wm.fdcManager.Len()
+	st.FpCacheLen = wm.fpcManager.Len()
+	wm.listenerMu.RLock()
+	st.Listeners = len(wm.listeners)
+	wm.listenerMu.RUnlock()
+	st.ResolveInflight = wm.resolveLimit.inflight.Load()
+	st.ResolveMaxInflight = wm.resolveLimit.maxInflight.Load()
+	st.ResolveLimit = cap(wm.resolveLimit.sem)
+	if wm.adaptiveDedup {
+		st.AdaptiveTTL = wm.adaptiveWindows()
+	}
+	if wm.bulk != nil {
+		st.BulkDirs = wm.bulk.active()
+	}
+	return st
+}
+
+// CacheStat 单个缓存的条目数、容量与累计命中/未命中次数
+type CacheStat struct {
+	Len    int    `json:"len"`
+	Cap    int    `json:"cap"`
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+// CacheStats handle→path 缓存(fd-size、fd-ttl)与路径去重缓存(fp-size、fp-ttl)的状态
+type CacheStats struct {
+	Fd CacheStat `json:"fd"`
+	Fp CacheStat `json:"fp"`
+}
+
+// CacheStats 返回缓存状态快照，用于调整 cache 配置；数值与 Stats 中的对应字段一致
+func (wm *Watchman) CacheStats() CacheStats {
+	return CacheStats{
+		Fd: CacheStat{Len: wm.fdcManager.Len(), Cap: wm.fdcSize,
+			Hits: wm.stats.resolveHits.Load(), Misses: wm.stats.resolveMisses.Load()},
+		Fp: CacheStat{Len: wm.fpcManager.Len(), Cap: wm.fpcSize,
+			Hits: wm.stats.dedupHits.Load(), Misses: wm.stats.dedupMisses.Load()},
+	}
+}
+
+// FlushCaches 清空 handle→path 缓存(含失败缓存)与路径去重缓存，用于重新挂载等使缓存的路径失效之后；
+// 累计命中/未命中次数不清零。清空后各路径的下一个事件不会被去重
+func (wm *Watchman) FlushCaches() {
+	wm.fdcManager.Purge()
+	wm.fdcNegative.Purge()
+	wm.fpcManager.Purge()
+	slog.Info("caches flushed")
+}
+
+// ErrorReporter 供 sink 上报单个事件处理失败，失败按监听器与事件命中的监控路径归类，见 Stats.ListenerErrors
+type ErrorReporter func(info *EventInfo, err error)
+
+// ErrorReporterFor 返回以 identify 归类的失败上报函数，可在后台协程中调用；失败日志由 sink 自行记录
+func (wm *Watchman) ErrorReporterFor(identify string) ErrorReporter {
+	return func(info *EventInfo, err error) {
+		wm.stats.recordListenerError(identify, info, err, wm.clock.Now())
+	}
+}
+
+// AddStatsSource 注册 sink 的状态回调，结果出现在 Stats.Sinks[name]
+func (wm *Watchman) AddStatsSource(name string, fn func() any) {
+	wm.stats.mu.Lock()
+	defer wm.stats.mu.Unlock()
+	wm.stats.sources[name] = fn
+}
+
+func (wm *Watchman) removeStatsSource(name string) {
+	wm.stats.mu.Lock()
+	defer wm.stats.mu.Unlock()
+	delete(wm.stats.sources, name)
+}
@@ -0,0 +1,56 @@
+package watcher
+
+import (
+	"errors"
+	"maps"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 只在某个监控路径下失败的监听器，按监听器与命中的监控路径计数，其他监听器与路径不受影响
+func TestListenerErrorsByRule(t *testing.T) {
+	root := t.TempDir()
+	ok, bad := filepath.Join(root, "ok"), filepath.Join(root, "bad")
+	mkdirAll(t, ok)
+	mkdirAll(t, bad)
+	wm := newTestWatchman(t, "paths: ["+ok+", "+bad+"]\nevents: [CLOSE_WRITE]")
+	errRejected := errors.New("rejected")
+	wm.AddErrListener("sink", func(_, dir, _ string, _ bool) error {
+		if strings.HasPrefix(dir, bad) {
+			return errRejected
+		}
+		return nil
+	})
+	wm.AddEventListener("panicky", func(info *EventInfo) {
+		if info.MatchedRule == bad {
+			panic("boom")
+		}
+	})
+	wm.AddErrListener("fine", func(string, string, string, bool) error { return nil })
+	sink := runTestWatchman(t, wm)
+
+	for _, path := range []string{filepath.Join(bad, "1"), filepath.Join(ok, "1"), filepath.Join(bad, "2"), filepath.Join(ok, "2")} {
+		writeFile(t, path, "x")
+		// 测试监听器最后注册，收到事件时前面的监听器已处理完
+		sink.wait(t, func(info *EventInfo) bool { return info.Path == path })
+	}
+
+	st := wm.Stats()
+	want := map[string]map[string]uint64{"sink": {bad: 2}, "panicky": {bad: 2}}
+	if len(st.ListenerErrors) != len(want) {
+		t.Fatalf("ListenerErrors = %v, want %v", st.ListenerErrors, want)
+	}
+	for name, byRule := range want {
+		if !maps.Equal(st.ListenerErrors[name], byRule) {
+			t.Errorf("ListenerErrors[%s] = %v, want %v", name, st.ListenerErrors[name], byRule)
+		}
+	}
+	last := st.ListenerLastErrors["sink"]
+	if last.Error != errRejected.Error() || last.Path != filepath.Join(bad, "2") {
+		t.Errorf("ListenerLastErrors[sink] = %+v", last)
+	}
+	if last := st.ListenerLastErrors["panicky"]; last.Error != "boom" {
+		t.Errorf("ListenerLastErrors[panicky] = %+v", last)
+	}
+}
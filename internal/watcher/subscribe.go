@@ -0,0 +1,57 @@
+package watcher
+
+import (
+	"fmt"
+	"maps"
+	"sync"
+	"sync/atomic"
+)
+
+// subscriberSeq 生成订阅者 identify 的序号
+var subscriberSeq atomic.Uint64
+
+// subscriber 以监听器形式接收事件并转发到 channel；关闭后不再发送，避免向已关闭的 channel 写入
+type subscriber struct {
+	mu     sync.Mutex
+	ch     chan *EventInfo
+	closed bool
+}
+
+func (s *subscriber) handle(info *EventInfo) {
+	// 订阅方在其他 goroutine 读取，复制一份避免与后续监听器写 Attrs 竞争
+	c := *info
+	c.Attrs = maps.Clone(info.Attrs)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.ch <- &c:
+	default:
+	}
+}
+
+func (s *subscriber) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+}
+
+// Subscribe 订阅投递给监听器的事件，返回缓冲为 buffer 的 channel 和取消函数。
+// 订阅方消费不及时时事件被丢弃，不会阻塞事件处理；取消后 channel 被关闭。
+func (wm *Watchman) Subscribe(buffer int) (<-chan *EventInfo, func()) {
+	s := &subscriber{ch: make(chan *EventInfo, max(buffer, 1))}
+	identify := fmt.Sprintf("subscriber:%d", subscriberSeq.Add(1))
+	wm.AddEventListener(identify, s.handle)
+	var once sync.Once
+	return s.ch, func() {
+		once.Do(func() {
+			wm.RemoveListener(identify)
+			s.close()
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package watcher
+
+import (
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/armon/go-radix"
+)
+
+// 启动扫描时最多遍历的目录项数，超出后停止扫描，避免巨大目录树拖慢启动
+const maxSymlinkScanEntries = 100000
+
+// symlinkIndex 记录监控目录内指向目录树之外的符号链接，目标被修改时 fanotify 报告的是真实路径，
+// 借此映射回树内的链接路径。链接在启动时扫描，运行期间只在树内 CREATE 事件时补充（每次一个 lstat）；
+// 被删除或改指向的链接不会自动失效，同一目标有多个链接时只映射到最先发现的一个。
+type symlinkIndex struct {
+	mu      sync.RWMutex
+	targets *radix.Tree // 真实目标路径 -> 树内链接路径
+}
+
+func newSymlinkIndex(roots []string) *symlinkIndex {
+	x := &symlinkIndex{targets: radix.New()}
+	scanned := 0
+	for _, root := range roots {
+		_ = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil
+			}
+			if scanned++; scanned > maxSymlinkScanEntries {
+				return filepath.SkipAll
+			}
+			if d.Type()&fs.ModeSymlink != 0 {
+				x.add(path, roots)
+			}
+			return nil
+		})
+	}
+	if scanned > maxSymlinkScanEntries {
+		slog.Warn("symlink scan truncated", "limit", maxSymlinkScanEntries)
+	}
+	slog.Info("symlink index built", "links", x.targets.Len())
+	return x
+}
+
+// add 若链接目标位于所有 roots 之外则记录
+func (x *symlinkIndex) add(link string, roots []string) {
+	target, err := filepath.EvalSymlinks(link)
+	if err != nil {
+		return
+	}
+	for _, r := range roots {
+		if isUnder(target, r) {
+			return
+		}
+	}
+	x.mu.Lock()
+	if _, ok := x.targets.Get(target); !ok {
+		x.targets.Insert(target, link)
+	}
+	x.mu.Unlock()
+}
+
+// observe 处理树内新建的路径，若为符号链接则加入索引
+func (x *symlinkIndex) observe(path string, roots []string) {
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&fs.ModeSymlink != 0 {
+		x.add(path, roots)
+	}
+}
+
+// translate 将真实路径映射回树内链接路径
+func (x *symlinkIndex) translate(path string) (string, bool) {
+	x.mu.RLock()
+	target, link, ok := x.targets.LongestPrefix(path)
+	x.mu.RUnlock()
+	if !ok || !isUnder(path, target) {
+		return "", false
+	}
+	return link.(string) + path[len(target):], true
+}
+
+// isUnder 判断 path 是否等于 root 或位于其下（按路径段边界）
+func isUnder(path, root string) bool {
+	if root == "/" {
+		return true
+	}
+	return path == root || (len(path) > len(root) && path[len(root)] == '/' && path[:len(root)] == root)
+}
@@ -0,0 +1,38 @@
+package watcher
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+// event-targets 排除的目录事件与文件事件一样计入 filtered
+func TestEventTargetsCountFiltered(t *testing.T) {
+	file := func(t *testing.T, path string) { writeFile(t, path, "") }
+	for _, tt := range []struct {
+		targets      string
+		skip, expect func(t *testing.T, path string)
+	}{
+		{TargetFiles, mkdirAll, file},
+		{TargetDirs, file, mkdirAll},
+	} {
+		t.Run(tt.targets, func(t *testing.T) {
+			root := t.TempDir()
+			wm := newTestWatchman(t, "paths: ["+root+"]\nevents: [CREATE]\nevent-targets: "+tt.targets)
+			sink := runTestWatchman(t, wm)
+			for i := range 3 {
+				tt.skip(t, filepath.Join(root, fmt.Sprint("skip", i)))
+			}
+			last := filepath.Join(root, "last")
+			tt.expect(t, last)
+			// 事件按顺序处理，收到 last 时之前的事件都已被过滤
+			sink.wait(t, func(info *EventInfo) bool { return info.Path == last })
+			if got := wm.Stats().Filtered; got < 3 {
+				t.Fatalf("filtered = %d, want >= 3", got)
+			}
+			if info := sink.find(func(info *EventInfo) bool { return info.Path != last }); info != nil {
+				t.Fatalf("unexpected event %s %s", info.Type, info.Path)
+			}
+		})
+	}
+}
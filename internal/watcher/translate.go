@@ -0,0 +1,32 @@
+package watcher
+
+import "path/filepath"
+
+// pathTranslation 将 watchman 所在挂载命名空间中的路径改写为宿主机视角，
+// 如容器内 /host/data/x → /data/x。不在 strip 之下的路径只追加 prepend。
+type pathTranslation struct {
+	strip   string
+	prepend string
+}
+
+// newPathTranslation strip 与 prepend 均为空时返回 nil，表示不做改写
+func newPathTranslation(strip, prepend string) *pathTranslation {
+	if strip == "" && prepend == "" {
+		return nil
+	}
+	return &pathTranslation{strip: strip, prepend: prepend}
+}
+
+func (t *pathTranslation) apply(path string) string {
+	rest := path
+	if t.strip != "" && t.strip != "/" && isUnder(path, t.strip) {
+		rest = path[len(t.strip):]
+		if rest == "" {
+			rest = "/"
+		}
+	}
+	if t.prepend == "" {
+		return rest
+	}
+	return filepath.Join(t.prepend, rest)
+}
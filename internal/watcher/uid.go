@@ -0,0 +1,95 @@
+package watcher
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/caoenergy/watchman/platform/linux/nsenter"
+
+	lru "github.com/hashicorp/golang-lru/v2/expirable"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	uidCacheSize = 4096
+	// pid 会被复用，缓存时间不宜过长
+	uidCacheTTL = 30 * time.Second
+)
+
+// uidResolver 读取 /proc/<pid>/status 的 Uid 行(真实 uid)得到触发事件的用户，按 pid 缓存。
+// 进程在读取前已退出(如短命的 echo > file)时无法得到 uid，结果同样缓存，避免反复读取。
+type uidResolver struct {
+	cache *lru.LRU[int32, int64] // -1 表示未知
+}
+
+func newUidResolver(enabled bool) *uidResolver {
+	if !enabled {
+		return nil
+	}
+	return &uidResolver{cache: lru.NewLRU[int32, int64](uidCacheSize, nil, uidCacheTTL)}
+}
+
+// lookup 返回 pid 的真实 uid，未知时 ok 为 false。
+// pidfd >= 0 时(见 report-pidfd)不使用缓存：读取 /proc 后确认 pidfd 指向的进程仍未被回收，此时 pid 不可能被复用，
+// 读到的就是触发事件的进程；进程已退出时返回未知
+func (r *uidResolver) lookup(pid int32, pidfd int) (uint32, bool) {
+	if pid <= 0 {
+		return 0, false
+	}
+	if pidfd >= 0 {
+		uid := readUid(pid)
+		if uid < 0 || unix.PidfdSendSignal(pidfd, 0, nil, 0) != nil {
+			return 0, false
+		}
+		return uint32(uid), true
+	}
+	uid, found := r.cache.Get(pid)
+	if !found {
+		uid = readUid(pid)
+		r.cache.Add(pid, uid)
+	}
+	if uid < 0 {
+		return 0, false
+	}
+	return uint32(uid), true
+}
+
+func readUid(pid int32) int64 {
+	var data []byte
+	var err error
+	// 加入其他挂载命名空间后 /proc 可能属于其他 pid 命名空间，使用宿主机的 /proc
+	if proc := nsenter.ProcFd(); proc >= 0 {
+		var fd int
+		if fd, err = unix.Openat(proc, fmt.Sprintf("%d/status", pid), unix.O_RDONLY|unix.O_CLOEXEC, 0); err == nil {
+			f := os.NewFile(uintptr(fd), "status")
+			data, err = io.ReadAll(f)
+			_ = f.Close()
+		}
+	} else {
+		data, err = os.ReadFile(fmt.Sprintf("/proc/%d/status", pid))
+	}
+	if err != nil {
+		return -1
+	}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		// Uid:	<real>	<effective>	<saved>	<fs>
+		if rest, ok := bytes.CutPrefix(sc.Bytes(), []byte("Uid:")); ok {
+			fields := bytes.Fields(rest)
+			if len(fields) == 0 {
+				return -1
+			}
+			uid, err := strconv.ParseUint(string(fields[0]), 10, 32)
+			if err != nil {
+				return -1
+			}
+			return int64(uid)
+		}
+	}
+	return -1
+}
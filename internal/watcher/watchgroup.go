@@ -0,0 +1,72 @@
+package watcher
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/armon/go-radix"
+	"github.com/caoenergy/watchman/internal/settings"
+)
+
+// ErrUnknownGroup 监控组未在 watch-groups 中定义
+var ErrUnknownGroup = errors.New("unknown watch group")
+
+// watchGroup 命名监控组的路径规则，匹配方式与全局过滤相同；只读，无需加锁
+type watchGroup struct {
+	name   string
+	prefix *radix.Tree
+	glob   *globMatcher
+	regex  *regexMatcher
+}
+
+// newWatchGroups 返回按组名与按插件名索引的监控组
+func newWatchGroups(groups []settings.WatchGroup) (map[string]*watchGroup, map[string]*watchGroup, error) {
+	byName := make(map[string]*watchGroup, len(groups))
+	byPlugin := make(map[string]*watchGroup)
+	for _, g := range groups {
+		prefix, glob, regex, _, err := buildFilter(g.Paths)
+		if err != nil {
+			return nil, nil, fmt.Errorf("watch group %s: %w", g.Name, err)
+		}
+		wg := &watchGroup{name: g.Name, prefix: prefix, glob: glob, regex: regex}
+		byName[g.Name] = wg
+		for _, p := range g.Plugins {
+			byPlugin[p] = wg
+		}
+	}
+	return byName, byPlugin, nil
+}
+
+func (g *watchGroup) match(path string) bool {
+	if _, _, ok := g.prefix.LongestPrefix(path); ok {
+		return true
+	}
+	if _, ok := g.glob.match(path); ok {
+		return true
+	}
+	_, ok := g.regex.match(path)
+	return ok
+}
+
+// contains 按改写前的路径判断事件是否属于本组；没有路径的合成事件(如 SESSION_START、OVERFLOW)属于所有组，
+// RENAME 的任一端在组内即可
+func (g *watchGroup) contains(info *EventInfo) bool {
+	path, oldPath := info.Path, info.OldPath
+	if info.origPath != "" {
+		path, oldPath = info.origPath, info.origOldPath
+	}
+	if path == "" {
+		return true
+	}
+	return g.match(path) || oldPath != "" && g.match(oldPath)
+}
+
+// AddGroupListener 同 AddListener，但只接收 group 路径下的事件；group 未定义时返回 ErrUnknownGroup
+func (wm *Watchman) AddGroupListener(group, identify string, listener Listener) error {
+	g, ok := wm.watchGroups[group]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownGroup, group)
+	}
+	wm.addEntry(listenerEntry{identify: identify, listener: Adapt(listener), group: g})
+	return nil
+}
@@ -3,20 +3,28 @@ package watcher
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
 	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"hash/fnv"
+	"io"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
 	wmp "github.com/caoenergy/watchman-plugin"
+	"github.com/caoenergy/watchman/internal/clock"
+	"github.com/caoenergy/watchman/internal/retry"
 	"github.com/caoenergy/watchman/internal/settings"
+	"github.com/caoenergy/watchman/platform/linux/nsenter"
 
 	"github.com/armon/go-radix"
 	lru "github.com/hashicorp/golang-lru/v2/expirable"
@@ -24,26 +32,199 @@ import (
 )
 
 type Watchman struct {
-	ffd             int // fanotifyFd
-	rfd             int // rootFd
+	ffd             int             // fanotifyFd
+	rfd             int             // rootFd
+	fidOnly         bool            // FAN_REPORT_FID：事件只携带对象自身的 handle，见 report-mode
+	mounts          *mountRoots     // "/" 以外被标记的文件系统，按 fsid 选择 OpenByHandleAt 的 mount_fd
+	fsidMounts      sync.Map        // [8]byte → 挂载点，见 MountPoint
+	inotify         *inotifyWatcher // 非空时 ffd 为 inotify fd，见 watcher.backend
+	wakefd          int             // eventfd，唤醒阻塞在 poll 上的 captureEvents，见 Interrupt
 	fdcManager      *lru.LRU[string, string]
-	fpcManager      *lru.LRU[string, string]
+	fdcNegative     *lru.LRU[string, struct{}] // 已确认无法解析的 handle，见 cache.fd-negative-ttl-ms
+	fdIndex         *fdPathIndex               // fdcManager 的路径索引，目录移动后按前缀失效
+	lostWarnAt      time.Time                  // 上次告警删除事件丢失的时间，只在事件循环中访问
+	fpcManager      *lru.LRU[string, *pathState]
+	fdcSize         int // fdcManager 与 fpcManager 的容量，见 CacheStats
+	fpcSize         int
+	fpTtl           time.Duration
+	dedupKeyMode    string // 去重键策略，见 KeyPath 等
+	adaptiveDedup   bool
 	filter          *radix.Tree
+	globFilter      *globMatcher
+	regexFilter     *regexMatcher
+	excludeFilter   *radix.Tree // 排除前缀，与 filter 一起受 filterMu 保护
+	excludeNames    []string    // 排除的文件名规则，受 filterMu 保护
+	namePatterns    []string
+	extensions      map[string]bool // 不带点的小写扩展名，nil 表示不限制
+	files           bool            // 见 watcher.event-targets
+	dirs            bool
+	minSize         int64 // 见 watcher.min-size，与 maxSize 均为 0 时不 stat
+	maxSize         int64
+	watchGroups     map[string]*watchGroup // 见 AddGroupListener，初始化后只读
+	pluginGroups    map[string]*watchGroup // 按插件名
+	symlinks        *symlinkIndex          // 可选，将树外链接目标的事件映射回树内链接路径
+	symlinkRoots    []string
+	nameAnywhere    bool
+	writtenPaths    map[settings.WatchPath][]settings.WatchPath // 见 ConfigPaths，受 reloadMu 保护
 	filterMu        sync.RWMutex
+	markMode        string // 见 MarkModeFilesystem，inode 模式下 ReloadFilter 需同步增删标记
+	markEvents      uint64
+	reloadMu        sync.Mutex // 串行化 ReloadFilter，保证新增/删除路径的计算基于最新规则
 	eventChan       chan Event
-	eventBufferSize int
-	listeners       map[string]Listener
+	readBuf         *readBuffer     // 见 watcher.buffer-max-kb
+	listeners       []listenerEntry // 按注册顺序保存，投递时依次调用
 	listenerMu      sync.RWMutex
+	watchCtx        context.Context // Watch 的 ctx，受 listenerMu 保护，传给 ContextListener
+	overflowHooks   []func()        // 受 listenerMu 保护，见 OnOverflow
 	stopOnce        sync.Once
+	interruptOnce   sync.Once
+	watching        atomic.Bool
+	processDone     chan struct{} // processEvents 返回时关闭
+	drainAbort      chan struct{} // StopAndDrain 超时时关闭，processEvents 放弃剩余事件
+	abortOnce       sync.Once
 	plugins         []*wmp.Handler
+	pluginInfos     []PluginInfo
+	pluginQueues    map[string]*pluginQueue // 按插件名，启用 SetPluginQueue 后注册的插件才有
+	pluginQueueCfg  *pluginQueueConfig
+	pluginMu        sync.RWMutex
+	history         *eventRing // 可选，见 RecentEvents
+	exprFilter      *exprFilter
+	enricher        *enricher       // 可选，按路径前缀从映射文件附加 Attrs
+	synthChan       chan *EventInfo // 内部合成的事件（如 WRITER_EXIT），跳过过滤与去重直接投递
+	exitTracker     *exitTracker
+	stats           *stats
+	rawLog          bool // 调试：记录 resolve 之前的原始事件
+	closers         []io.Closer
+	flushers        []Flusher
+	closersMu       sync.Mutex
+	dispatchWorkers int
+	resolveWorkers  int // >1 时 capture 阶段按批并行解析 handle
+	maxDepth        int // 相对命中规则的最大层级，0 表示不限制
+	relativePaths   bool
+	dispatchQueue   int
+	shardByDir      bool               // dispatch.shard-by 为 dir
+	dispatcher      *shardedDispatcher // dispatch.workers > 1 时启用
+	lockstep        bool               // dispatch.mode 为 lockstep，见 Lockstep
+	clock           clock.Clock
+	mono            monoClock        // 为投递的事件填充 Monotonic
+	ephemeral       *ephemeralFilter // 可选，合并临时文件的 CREATE+DELETE
+	moves           *movePairer      // 可选，没有 FAN_RENAME 时将 MOVED_FROM/MOVED_TO 配对为 RENAME
+	debounce        *debouncer       // 可选，按 (路径, 类型) 合并连续事件，见 watcher.debounce-ms
+	dedupOff        bool             // cache.fp-mode 为 debounce 时由 debouncer 取代 fp-ttl 去重
+	recorder        *recorder        // 可选，记录原始 read 缓冲区用于回放
+	resolveLimit    *resolveLimiter
+	translation     *pathTranslation // 可选，过滤之后将路径改写为宿主机视角
+	self            selfExclude      // watchman 自身写入的文件，见 ExcludeSelf
+	fsTypes         *fsTypeFilter    // 可选，丢弃指定文件系统类型的事件
+	session         *session         // 可选，启动/退出时投递 SESSION_START/SESSION_END
+	scanner         *scanner         // 可选，启动时或溢出后遍历监控目录合成 CREATE
+	bulk            *bulkDetector    // 可选，批量操作期间按目录汇总为 BULK_CHANGE
+	uids            *uidResolver     // 可选，按 pid 查询触发事件的用户
 }
 
 type Event struct {
 	Mask   uint64
 	IsDir  bool
 	Handle []byte
+	Pid    int32 // 触发事件的进程
+	Pidfd  int   // 启用 FAN_REPORT_PIDFD 时的 pidfd，否则为 -1
+	// OldHandle FAN_RENAME 事件的原位置记录(OLD_DFID_NAME)，Handle 为新位置
+	OldHandle []byte
+	// Fsid 事件所在文件系统，取自 info 记录头，可用 linux.FsidMountPoint 换算为挂载点
+	Fsid [8]byte
+	// Cookie 关联同一次重命名的 MOVED_FROM 与 MOVED_TO(见 movePairer)：inotify 取自事件，fanotify 由 captureEvents 分配；0 表示不配对
+	Cookie uint32
+
+	resolved *resolution // resolve-workers > 1 时由 capture 阶段预先解析
+}
+
+// EventInfo 是解析完成后的事件，供表达式过滤等按字段判断的场景使用。
+type EventInfo struct {
+	Type string // 事件类型，如 CREATE、CLOSE_WRITE，多个以 '|' 连接
+	Dir  string // 所在目录
+	Name string // 文件名
+	Path string // 完整路径
+	// OldPath RENAME 事件的原路径（Path 为新路径），其他事件为空
+	OldPath string
+	IsDir   bool   // 是否为目录
+	Mask    uint64 // 原始事件掩码
+	Pid     int32  // 触发事件的进程
+	// Fsid 事件所在文件系统(见 Event.Fsid)，合成事件为零值；Watchman.MountPoint 返回其挂载点
+	Fsid [8]byte
+	// Uid 触发事件的进程的真实 uid，仅开启 report-uid 时填充；进程已退出等无法确定时为 nil
+	Uid  *uint32
+	Time time.Time // 事件处理时间(挂钟时间，系统时钟调整时可能回退或跳变)
+	// Monotonic 与 Time 对应的 CLOCK_MONOTONIC 纳秒，不受系统时钟调整影响，可与同一主机上其他数据流按时间合并排序
+	Monotonic int64
+	// Root/RelPath 开启 relative-paths 时填充：命中规则对应的实际根目录，及 Path 相对它的路径(与根相同时为 ".")
+	Root    string
+	RelPath string
+	// MatchedRule 命中的监控路径（前缀或通配模式），仅由文件名规则命中时为空
+	MatchedRule string
+	// Attrs 监听器之间传递的附加数据（如分类结果），按注册顺序在前的监听器写入、在后的读取。
+	// 同一事件的监听器顺序调用，每个事件有独立的 Attrs，无需加锁。
+	Attrs map[string]any
+
+	stat     *FileStat // 见 FileStat
+	statDone bool
+	// 路径改写前的 Path/OldPath，监控组按它匹配；未开启 path-translation 时为空
+	origPath, origOldPath string
+}
+
+// SetAttr 写入附加数据，Attrs 为空时自动创建
+func (e *EventInfo) SetAttr(key string, value any) {
+	if e.Attrs == nil {
+		e.Attrs = make(map[string]any)
+	}
+	e.Attrs[key] = value
+}
+
+// Attr 读取附加数据
+func (e *EventInfo) Attr(key string) (any, bool) {
+	v, ok := e.Attrs[key]
+	return v, ok
+}
+
+// HostPath 返回本命名空间中的事件路径：开启 path-translation 时为改写前的 Path，否则即 Path。
+// 需要访问文件本身(stat、读取内容)的监听器应使用它而不是 Path
+func (e *EventInfo) HostPath() string {
+	if e.origPath != "" {
+		return e.origPath
+	}
+	return e.Path
 }
 
+type listenerEntry struct {
+	identify string
+	listener EventListener
+	// filtered 为 true 时只投递 mask 与事件掩码相交、或类型属于 synthetic 的事件；AddListener 等注册的监听器订阅全部类型
+	filtered  bool
+	mask      uint64
+	synthetic []string    // 没有 fanotify 掩码的合成事件类型，如 WRITER_EXIT、BULK_CHANGE
+	group     *watchGroup // 非空时只投递该监控组路径下的事件，见 AddGroupListener
+}
+
+// accepts 判断事件是否属于监听器订阅的类型
+func (e listenerEntry) accepts(info *EventInfo) bool {
+	if e.group != nil && !e.group.contains(info) {
+		return false
+	}
+	if !e.filtered || info.Mask&e.mask != 0 {
+		return true
+	}
+	return slices.Contains(e.synthetic, info.Type)
+}
+
+// EventListener 接收完整事件，可读写 Attrs 与后续监听器协作；调用约束同 Listener。
+type EventListener func(info *EventInfo)
+
+// ContextListener 可返回错误的监听器，接收完整事件(含 Mask、Pid 及解析后的路径)；调用约束同 Listener。
+// ctx 随 Watch 的 ctx 取消，供网络 I/O 等使用；返回的错误会被记录日志并计入 Stats.ListenerErrors。
+type ContextListener func(ctx context.Context, info *EventInfo) error
+
+// ErrListener 同 Listener，但可返回错误；错误记录警告日志并计入 Stats.ListenerErrors 与 Stats.ListenerLastErrors
+type ErrListener func(eventType, dir, filename string, isDir bool) error
+
 // Listener 接收事件回调。实现方应尽快返回，避免阻塞事件处理；若有耗时 I/O 请自行起 goroutine 或投递到自有队列。
 type Listener func(eventType, dir, filename string, isDir bool)
 
@@ -54,85 +235,577 @@ const (
 	EventInfoFidLen = 12
 	// struct file_handle 头部：handle_bytes(4) + handle_type(4)
 	FileHandleLen = 8
+	TargetFiles   = "files"
+	TargetDirs    = "dirs"
+	TargetBoth    = "both"
+
+	// handle 失效时丢失路径的删除类事件，见 Stats.LostDeletes
+	lostDeleteEvents = unix.FAN_DELETE | unix.FAN_DELETE_SELF | unix.FAN_MOVED_FROM
+	// 解析失败缓存的条目上限，已删除的 handle 通常很快不再出现，无需与 fd-size 一样大
+	fdNegativeSize = 4096
 )
 
-func Initialize(setting *settings.Settings) (*Watchman, error) {
+// initFanotify 按 report-mode、writer-exit 与 report-pidfd 初始化 fanotify，内核不支持时依次降级，返回实际生效的 fidOnly 与 pidfd
+func initFanotify(setting *settings.Settings) (int, bool, bool, error) {
 	// FAN_REPORT_DFID_NAME requires Linux kernel 5.9 or higher.
-	ffd, err := unix.FanotifyInit(unix.FAN_REPORT_DFID_NAME|unix.FAN_CLOEXEC, unix.O_RDONLY)
+	initFlags := uint(unix.FAN_REPORT_DFID_NAME | unix.FAN_CLOEXEC)
+	reportMode := setting.Watchman.Watcher.ReportMode
+	fidOnly := reportMode == ReportModeFid
+	if fidOnly {
+		// FAN_REPORT_FID requires Linux kernel 5.1 or higher.
+		initFlags = unix.FAN_REPORT_FID | unix.FAN_CLOEXEC
+	}
+	pidfd := setting.Watchman.Watcher.WriterExit || setting.Watchman.Watcher.ReportPidfd
+	if pidfd {
+		// FAN_REPORT_PIDFD requires Linux kernel 5.15 or higher.
+		initFlags |= unix.FAN_REPORT_PIDFD
+	}
+	ffd, err := unix.FanotifyInit(initFlags, unix.O_RDONLY)
+	if err != nil && pidfd && errors.Is(err, unix.EINVAL) {
+		slog.Warn("FAN_REPORT_PIDFD unsupported, writer-exit disabled and report-uid falls back to plain pid", "err", err)
+		pidfd = false
+		initFlags &^= unix.FAN_REPORT_PIDFD
+		ffd, err = unix.FanotifyInit(initFlags, unix.O_RDONLY)
+	}
+	if err != nil && reportMode == ReportModeAuto && errors.Is(err, unix.EINVAL) {
+		slog.Warn("FAN_REPORT_DFID_NAME unsupported (kernel < 5.9), falling back to FAN_REPORT_FID: events carry the path of "+
+			"the changed object without a file name, directory entry events (CREATE/DELETE/MOVED_*) report the parent directory", "err", err)
+		fidOnly = true
+		initFlags = initFlags&^unix.FAN_REPORT_DFID_NAME | unix.FAN_REPORT_FID
+		ffd, err = unix.FanotifyInit(initFlags, unix.O_RDONLY)
+	}
+	return ffd, fidOnly, pidfd, err
+}
+
+func Initialize(setting *settings.Settings) (*Watchman, error) {
+	var ffd int
+	var fidOnly, pidfd bool
+	var ino *inotifyWatcher
+	var err error
+	backend := setting.Watchman.Watcher.Backend
+	if backend != BackendInotify {
+		ffd, fidOnly, pidfd, err = initFanotify(setting)
+		if err != nil && backend == BackendAuto && fanotifyUnavailable(err) {
+			slog.Warn("fanotify unavailable, falling back to inotify backend", "err", err)
+			backend, fidOnly, pidfd = BackendInotify, false, false
+		}
+	}
+	writerExit := pidfd && setting.Watchman.Watcher.WriterExit
+	markEvents := EventMask(setting.Watchman.Watcher.Events)
+	if backend == BackendInotify {
+		if ino, err = newInotifyWatcher(markEvents); err == nil {
+			ffd = ino.fd
+		}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("init: %w", err)
 	}
+	// 没有 FAN_RENAME 时改为订阅两半并配对；fid 模式的目录项事件不带文件名，无法得到两端路径，只订阅两半
+	pairMoves := markEvents&unix.FAN_RENAME != 0 && !fidOnly && (ino != nil || !renameSupported())
+	if ino == nil && markEvents&unix.FAN_RENAME != 0 && (fidOnly || pairMoves) {
+		slog.Warn("FAN_RENAME unsupported (kernel < 5.17 or report-mode fid), watching MOVED_FROM/MOVED_TO instead",
+			"pairing", pairMoves, "window_ms", setting.Watchman.Watcher.RenameWindowMs)
+		markEvents = markEvents&^unix.FAN_RENAME | unix.FAN_MOVED_FROM | unix.FAN_MOVED_TO
+	}
 
-	if err = unix.FanotifyMark(ffd, unix.FAN_MARK_ADD|unix.FAN_MARK_FILESYSTEM,
-		unix.FAN_CREATE|unix.FAN_DELETE|unix.FAN_DELETE_SELF|unix.FAN_CLOSE_WRITE|unix.FAN_MOVED_TO|unix.FAN_ONDIR|unix.FAN_EVENT_ON_CHILD,
-		unix.AT_FDCWD, "/"); err != nil {
+	roots := make([]string, len(setting.Watchman.Watcher.Paths))
+	for i, p := range setting.Watchman.Watcher.Paths {
+		roots[i] = pathRoot(p)
+	}
+	mounts := newMountRoots()
+	if ino != nil {
+		for _, root := range roots {
+			ino.addTree(root, false)
+		}
+		slog.Info("using inotify backend", "watches", ino.watchCount())
+	} else if err = addMarks(ffd, setting.Watchman.Watcher.MarkMode, roots, markEvents, mounts); err != nil {
+		mounts.close()
 		_ = unix.Close(ffd)
-		return nil, fmt.Errorf("mark: %w", err)
+		return nil, err
 	}
 
 	rfd, err := unix.Open("/", unix.O_DIRECTORY|unix.O_RDONLY|unix.O_CLOEXEC, 0)
 	if err != nil {
+		mounts.close()
 		_ = unix.Close(ffd)
 		return nil, fmt.Errorf("open root: %w", err)
 	}
-	filter := radix.New()
+	filter, globFilter, regexFilter, prefixes, err := buildFilter(setting.Watchman.Watcher.Paths)
+	if err != nil {
+		_ = unix.Close(ffd)
+		_ = unix.Close(rfd)
+		mounts.close()
+		return nil, err
+	}
+	watchGroups, pluginGroups, err := newWatchGroups(setting.Watchman.WatchGroups)
+	if err != nil {
+		_ = unix.Close(ffd)
+		_ = unix.Close(rfd)
+		mounts.close()
+		return nil, err
+	}
 	for _, p := range setting.Watchman.Watcher.Paths {
-		filter.Insert(p, true)
-		slog.Info("添加监控路径", "path", p)
+		slog.Info("添加监控路径", "path", p.Path, "match-mode", p.Mode())
+	}
+	warnMissing(setting.Watchman.Watcher.Paths)
+	excludeTree, excludeNames := splitExclude(setting.Watchman.Watcher.Exclude)
+	var symlinks *symlinkIndex
+	if setting.Watchman.Watcher.FollowSymlinks {
+		symlinks = newSymlinkIndex(prefixes)
+	}
+	if setting.Watchman.Watcher.NameAnywhere {
+		slog.Warn("name-anywhere enabled, name-patterns apply to the whole filesystem and event volume may increase substantially",
+			"patterns", setting.Watchman.Watcher.NamePatterns)
 	}
 	eventBufferSize := setting.Watchman.Watcher.BufferSize
 	if eventBufferSize <= 0 {
 		eventBufferSize = 64
 	}
+	ef, err := newExprFilter(setting.Watchman.Watcher.FilterExpr)
+	if err != nil {
+		_ = unix.Close(ffd)
+		_ = unix.Close(rfd)
+		mounts.close()
+		return nil, fmt.Errorf("filter expr: %w", err)
+	}
+	var enrich *enricher
+	if f := setting.Watchman.Watcher.EnrichFile; f != "" {
+		if enrich, err = newEnricher(f); err != nil {
+			_ = unix.Close(ffd)
+			_ = unix.Close(rfd)
+			mounts.close()
+			return nil, fmt.Errorf("enrich file: %w", err)
+		}
+	}
+	var session *session
+	if setting.Watchman.Watcher.SessionEvents {
+		session = newSession(setting)
+	}
+	synthChan := make(chan *EventInfo, 1024)
+	scan := setting.Watchman.Watcher.Scan
+	clk := clock.Clock(clock.Real{})
+	var ephemeral *ephemeralFilter
+	if ms := setting.Watchman.Watcher.EphemeralWindowMs; ms > 0 {
+		ephemeral = newEphemeralFilter(time.Duration(ms)*time.Millisecond, clk)
+	}
+	var moves *movePairer
+	if pairMoves {
+		moves = newMovePairer(time.Duration(setting.Watchman.Watcher.RenameWindowMs)*time.Millisecond, clk)
+	}
+	var tracker *exitTracker
+	if writerExit {
+		tracker = newExitTracker(synthChan, clk)
+	}
+	wakefd, err := unix.Eventfd(0, unix.EFD_CLOEXEC|unix.EFD_NONBLOCK)
+	if err != nil {
+		_ = unix.Close(ffd)
+		_ = unix.Close(rfd)
+		mounts.close()
+		return nil, fmt.Errorf("eventfd: %w", err)
+	}
+	fdIndex := newFdPathIndex()
+	debounce := newDebouncer(time.Duration(setting.Watchman.Watcher.DebounceMs)*time.Millisecond, false, clk)
+	if setting.Watchman.Cache.FpMode == FpModeDebounce {
+		debounce = newDebouncer(time.Duration(setting.Watchman.Cache.FpQuietMs)*time.Millisecond, true, clk)
+	}
 	return &Watchman{
 		ffd:             ffd,
+		inotify:         ino,
 		rfd:             rfd,
-		fdcManager:      lru.NewLRU[string, string](setting.Watchman.Cache.FdSize, nil, time.Duration(setting.Watchman.Cache.FdTtl)*time.Second),
-		fpcManager:      lru.NewLRU[string, string](setting.Watchman.Cache.FpSize, nil, time.Duration(setting.Watchman.Cache.FpTtl)*time.Second),
+		fidOnly:         fidOnly,
+		mounts:          mounts,
+		wakefd:          wakefd,
+		processDone:     make(chan struct{}),
+		drainAbort:      make(chan struct{}),
+		fdIndex:         fdIndex,
+		fdcManager:      lru.NewLRU[string, string](setting.Watchman.Cache.FdSize, fdIndex.remove, time.Duration(setting.Watchman.Cache.FdTtl)*time.Second),
+		fdcNegative:     lru.NewLRU[string, struct{}](fdNegativeSize, nil, time.Duration(setting.Watchman.Cache.FdNegativeTtlMs)*time.Millisecond),
+		fpcManager:      lru.NewLRU[string, *pathState](setting.Watchman.Cache.FpSize, nil, time.Duration(setting.Watchman.Cache.FpTtl)*time.Second),
+		fdcSize:         setting.Watchman.Cache.FdSize,
+		fpcSize:         setting.Watchman.Cache.FpSize,
+		fpTtl:           time.Duration(setting.Watchman.Cache.FpTtl) * time.Second,
+		dedupKeyMode:    setting.Watchman.Cache.FpKey,
+		adaptiveDedup:   setting.Watchman.Cache.FpAdaptive,
 		filter:          filter,
+		writtenPaths:    newWrittenPaths(setting.Watchman.Watcher.Paths, setting.WrittenPaths()),
+		excludeFilter:   excludeTree,
+		excludeNames:    excludeNames,
+		markMode:        setting.Watchman.Watcher.MarkMode,
+		markEvents:      markEvents,
+		globFilter:      globFilter,
+		watchGroups:     watchGroups,
+		pluginGroups:    pluginGroups,
+		regexFilter:     regexFilter,
+		namePatterns:    setting.Watchman.Watcher.NamePatterns,
+		extensions:      newExtensionSet(setting.Watchman.Watcher.Extensions),
+		files:           setting.Watchman.Watcher.EventTargets != TargetDirs,
+		dirs:            setting.Watchman.Watcher.EventTargets != TargetFiles,
+		minSize:         setting.Watchman.Watcher.MinSize,
+		maxSize:         setting.Watchman.Watcher.MaxSize,
+		nameAnywhere:    setting.Watchman.Watcher.NameAnywhere,
+		symlinks:        symlinks,
+		symlinkRoots:    prefixes,
 		eventChan:       make(chan Event, 4096),
-		eventBufferSize: eventBufferSize,
-		listeners:       make(map[string]Listener),
+		readBuf:         newReadBuffer(eventBufferSize, setting.Watchman.Watcher.BufferMaxKB),
 		plugins:         make([]*wmp.Handler, 0),
+		pluginQueues:    make(map[string]*pluginQueue),
+		history:         newEventRing(setting.Watchman.History.Size),
+		exprFilter:      ef,
+		enricher:        enrich,
+		synthChan:       synthChan,
+		exitTracker:     tracker,
+		stats:           newStats(),
+		dispatchWorkers: setting.Watchman.Dispatch.Workers,
+		resolveWorkers:  setting.Watchman.Watcher.ResolveWorkers,
+		maxDepth:        setting.Watchman.Watcher.MaxRelativeDepth,
+		relativePaths:   setting.Watchman.Watcher.RelativePaths,
+		dispatchQueue:   setting.Watchman.Dispatch.QueueSize,
+		shardByDir:      setting.Watchman.Dispatch.ShardBy == "dir",
+		lockstep:        setting.Watchman.Dispatch.Mode == "lockstep",
+		clock:           clk,
+		mono:            newMonoClock(clk.Now()),
+		ephemeral:       ephemeral,
+		moves:           moves,
+		debounce:        debounce,
+		dedupOff:        setting.Watchman.Cache.FpMode == FpModeDebounce,
+		resolveLimit:    newResolveLimiter(setting.Watchman.Watcher.MaxInflightResolves),
+		translation: newPathTranslation(setting.Watchman.Watcher.PathTranslation.Strip,
+			setting.Watchman.Watcher.PathTranslation.Prepend),
+		session: session,
+		fsTypes: newFsTypeFilter(setting.ExcludeFsTypeMagics()),
+		scanner: newScanner(scan.Initial, scan.OnOverflow, scan.MaxEvents, scan.Rate),
+		uids:    newUidResolver(setting.Watchman.Watcher.ReportUid),
+		bulk: newBulkDetector(setting.Watchman.Watcher.Bulk.Threshold,
+			time.Duration(setting.Watchman.Watcher.Bulk.WindowMs)*time.Millisecond, clk, synthChan),
 	}, nil
 }
 
+// Interrupt 停止读取 fanotify 事件：通过 wakefd 唤醒阻塞在 poll 上的 captureEvents 使其退出，退出时由它关闭
+// eventChan 让 processEvents 处理完积压后退出(关闭 ffd 并不能唤醒阻塞中的 read)。监听器与 sink 仍可用，
+// 待 Watch 的 goroutine 结束后再调用 Stop 释放，保证 SESSION_END 等退出时投递的事件能送达。
+func (wm *Watchman) Interrupt() {
+	wm.interruptOnce.Do(wm.wake)
+}
+
+func (wm *Watchman) wake() {
+	var one [8]byte
+	one[0] = 1 // eventfd 计数，本机字节序；只关心可读，值无所谓
+	_, _ = unix.Write(wm.wakefd, one[:])
+}
+
+// StopAndDrain 平滑停止：不再读取新事件，等待已读取的事件(eventChan 积压、暂存的临时文件事件及 SESSION_END)
+// 处理并投递完毕后再调用 Stop 释放资源。ctx 到期时放弃剩余事件，返回 ctx.Err()；此时正在执行的监听器
+// 可能与 Stop 并发，不会等待其返回。未调用 Watch 时等同于 Stop。Stop 仍为立即停止，不处理积压。
+func (wm *Watchman) StopAndDrain(ctx context.Context) error {
+	wm.Interrupt()
+	var err error
+	if wm.watching.Load() {
+		select {
+		case <-wm.processDone:
+		case <-ctx.Done():
+			err = ctx.Err()
+			slog.Warn("drain deadline exceeded, dropping queued events", "queued", len(wm.eventChan))
+			wm.abortOnce.Do(func() { close(wm.drainAbort) })
+		}
+	}
+	wm.Stop()
+	return err
+}
+
 func (wm *Watchman) Stop() {
 	wm.stopOnce.Do(func() {
-		if wm != nil {
-			// 先关 ffd，使 captureEvents 的 Read 返回并退出；再关 channel 让 processEvents 退出；最后关 rfd
-			_ = unix.Close(wm.ffd)
-			wm.ffd = -1
-			close(wm.eventChan)
-			_ = unix.Close(wm.rfd)
-			wm.rfd = -1
-		}
-		if wm.plugins != nil {
-			for _, p := range wm.plugins {
-				_ = (*p).Close()
+		// 先停止读取并关 ffd，再关 rfd
+		wm.Interrupt()
+		_ = unix.Close(wm.ffd)
+		_ = unix.Close(wm.wakefd)
+		_ = unix.Close(wm.rfd)
+		wm.mounts.close()
+		if wm.exitTracker != nil {
+			wm.exitTracker.close()
+		}
+		wm.pluginMu.Lock()
+		for _, q := range wm.pluginQueues {
+			q.close()
+		}
+		for _, p := range wm.plugins {
+			_ = (*p).Close()
+		}
+		if wm.pluginQueueCfg != nil {
+			_ = wm.pluginQueueCfg.deadLetter.Close()
+		}
+		wm.pluginMu.Unlock()
+		wm.closersMu.Lock()
+		// 先交付攒批中的事件，再关闭它们可能写入的 sink
+		for _, f := range wm.flushers {
+			if err := f.Flush(); err != nil {
+				slog.Warn("flush on stop failed", "err", err)
 			}
 		}
+		for _, c := range wm.closers {
+			_ = c.Close()
+		}
+		wm.closersMu.Unlock()
 	})
 }
 
-func (wm *Watchman) RegisterPlugin(p *wmp.Handler) {
+// PluginInfo 已加载插件的元数据
+type PluginInfo struct {
+	Name     string    `json:"name"`
+	Version  string    `json:"version,omitempty"` // 插件实现 Version() string 时提供
+	Path     string    `json:"path"`              // .so 文件路径
+	LoadedAt time.Time `json:"loaded_at"`
+}
+
+// SetPluginQueue 为之后注册的插件各建一个长度为 size 的队列，由独立 worker 调用插件：
+// 插件返回错误(见 ErrorHandler)或 panic 时按 policy 重试，仍失败则写入 deadLetterFile(为空时写错误日志，该文件的写入不上报)；
+// 队列满时丢弃事件，lockstep 模式下改为阻塞。各队列状态见 Stats.Sinks["plugin:<name>"]。须在加载插件前调用。
+func (wm *Watchman) SetPluginQueue(size int, policy retry.Policy, deadLetterFile string) error {
+	dl, err := newDeadLetterLog(deadLetterFile)
+	if err != nil {
+		return fmt.Errorf("dead letter file: %w", err)
+	}
+	if deadLetterFile != "" {
+		wm.ExcludeSelf(deadLetterFile)
+	}
+	wm.pluginMu.Lock()
+	defer wm.pluginMu.Unlock()
+	wm.pluginQueueCfg = &pluginQueueConfig{size: size, policy: policy, deadLetter: dl}
+	return nil
+}
+
+// RegisterPlugin 注册插件并以插件名作为 identify 添加监听器，path 为插件文件路径。
+// 插件名与已注册的监听器冲突时返回 ErrDuplicateListener，插件不会被登记。
+func (wm *Watchman) RegisterPlugin(p *wmp.Handler, path string) error {
+	name := (*p).Name()
+	wm.pluginMu.RLock()
+	cfg := wm.pluginQueueCfg
+	wm.pluginMu.RUnlock()
+	var q *pluginQueue
+	// 在 watch-groups 的 plugins 中列出的插件只接收该组的事件
+	group := wm.pluginGroups[name]
+	if cfg == nil {
+		if err := wm.addEntryUnique(listenerEntry{identify: name, listener: Adapt((*p).Handle), group: group}); err != nil {
+			return err
+		}
+	} else {
+		q = newPluginQueue(name, *p, *cfg, wm.clock, wm.lockstep, wm.ErrorReporterFor(name))
+		if err := wm.addEntryUnique(listenerEntry{identify: name, listener: q.handle, group: group}); err != nil {
+			q.close()
+			return err
+		}
+		wm.AddStatsSource("plugin:"+name, q.stats)
+	}
+	info := PluginInfo{Name: name, Path: path, LoadedAt: wm.clock.Now()}
+	if v, ok := (*p).(interface{ Version() string }); ok {
+		info.Version = v.Version()
+	}
+	wm.pluginMu.Lock()
 	wm.plugins = append(wm.plugins, p)
-	wm.AddListener((*p).Name(), (*p).Handle)
+	wm.pluginInfos = append(wm.pluginInfos, info)
+	if q != nil {
+		wm.pluginQueues[name] = q
+	}
+	wm.pluginMu.Unlock()
+	return nil
+}
+
+// UnregisterPlugin 移除插件对应的监听器并调用其 Close。Go 插件无法从进程中卸载，
+// 同一路径的 .so 也不能再次加载新版本，需换用新文件名。
+func (wm *Watchman) UnregisterPlugin(name string) bool {
+	wm.pluginMu.Lock()
+	i := slices.IndexFunc(wm.pluginInfos, func(info PluginInfo) bool { return info.Name == name })
+	if i < 0 {
+		wm.pluginMu.Unlock()
+		return false
+	}
+	p := wm.plugins[i]
+	q := wm.pluginQueues[name]
+	wm.plugins = slices.Delete(wm.plugins, i, i+1)
+	wm.pluginInfos = slices.Delete(wm.pluginInfos, i, i+1)
+	delete(wm.pluginQueues, name)
+	wm.pluginMu.Unlock()
+	wm.RemoveListener(name)
+	if q != nil {
+		q.close()
+		wm.removeStatsSource("plugin:" + name)
+	}
+	_ = (*p).Close()
+	return true
 }
 
+// Plugins 返回已加载插件的元数据，按加载顺序
+func (wm *Watchman) Plugins() []PluginInfo {
+	wm.pluginMu.RLock()
+	defer wm.pluginMu.RUnlock()
+	return slices.Clone(wm.pluginInfos)
+}
+
+// ErrDuplicateListener identify 已被注册
+var ErrDuplicateListener = errors.New("listener identify already registered")
+
 func (wm *Watchman) AddListener(identify string, listener Listener) {
+	wm.AddEventListener(identify, Adapt(listener))
+}
+
+// AddListenerForEvents 同 AddListener，但只接收 events 中的事件类型(名称同 settings.EventTypes)，
+// 不订阅的事件在投递时直接跳过，不进入监听器；未知名称记录警告后忽略
+func (wm *Watchman) AddListenerForEvents(identify string, events []string, listener Listener) {
+	entry := listenerEntry{identify: identify, listener: Adapt(listener), filtered: true}
+	for _, name := range events {
+		if m, ok := markableEvents[name]; ok {
+			entry.mask |= m
+		} else if slices.Contains(settings.EventTypes, name) {
+			entry.synthetic = append(entry.synthetic, name)
+		} else {
+			slog.Warn("unknown event type ignored", "identify", identify, "event", name)
+		}
+	}
+	wm.addEntry(entry)
+}
+
+// AddListenerUnique 同 AddListener，但 identify 已存在时返回 ErrDuplicateListener 而不是替换
+func (wm *Watchman) AddListenerUnique(identify string, listener Listener) error {
+	return wm.AddEventListenerUnique(identify, Adapt(listener))
+}
+
+// AddContextListener 注册可返回错误的监听器，identify 已存在时替换
+func (wm *Watchman) AddContextListener(identify string, listener ContextListener) {
+	wm.AddEventListener(identify, wm.adaptContext(identify, listener))
+}
+
+// AddErrListener 注册返回错误的监听器，identify 已存在时替换
+func (wm *Watchman) AddErrListener(identify string, listener ErrListener) {
+	wm.AddContextListener(identify, func(_ context.Context, info *EventInfo) error {
+		return listener(info.Type, info.Dir, info.Name, info.IsDir)
+	})
+}
+
+// adaptContext 将 ContextListener 转换为 EventListener，错误按 identify 归类上报
+func (wm *Watchman) adaptContext(identify string, listener ContextListener) EventListener {
+	report := wm.ErrorReporterFor(identify)
+	return func(info *EventInfo) {
+		wm.listenerMu.RLock()
+		ctx := wm.watchCtx
+		wm.listenerMu.RUnlock()
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		if err := listener(ctx, info); err != nil {
+			slog.Warn("listener failed", "listener", identify, "path", info.Path, "rule", info.MatchedRule, "err", err)
+			report(info, err)
+		}
+	}
+}
+
+// Adapt 将旧式 Listener 转换为 EventListener
+func Adapt(listener Listener) EventListener {
+	return func(info *EventInfo) {
+		listener(info.Type, info.Dir, info.Name, info.IsDir)
+	}
+}
+
+// Flusher 缓存事件的监听器(如 listener.Buffered)，Stop 时在关闭资源前调用 Flush
+type Flusher interface {
+	Flush() error
+}
+
+// AddFlusher 注册在 Stop 时刷新的监听器，按注册顺序刷新：包装其他 Flusher 的(如 listener.HashEnricher)须先于被包装者注册
+func (wm *Watchman) AddFlusher(f Flusher) {
+	wm.closersMu.Lock()
+	defer wm.closersMu.Unlock()
+	wm.flushers = append(wm.flushers, f)
+}
+
+// EventOverflow 内核队列溢出时投递给监听器的合成事件，Path 为空，不经过过滤与去重；收到后监听器看到的事件已不完整，需自行对账
+const EventOverflow = "OVERFLOW"
+
+// OnOverflow 注册内核队列溢出(FAN_Q_OVERFLOW)时的回调，每次溢出都会按注册顺序调用，次数见 Stats.Overflows。
+// 回调在读取 fanotify 的 goroutine 中同步执行，须尽快返回，耗时的对账(如遍历监控目录)请自行起 goroutine；
+// 仅需重新扫描监控路径时可直接配置 watcher.scan.on-overflow
+func (wm *Watchman) OnOverflow(fn func()) {
 	wm.listenerMu.Lock()
 	defer wm.listenerMu.Unlock()
-	wm.listeners[identify] = listener
+	wm.overflowHooks = append(wm.overflowHooks, fn)
+}
+
+func (wm *Watchman) overflowed() {
+	wm.listenerMu.RLock()
+	hooks := wm.overflowHooks
+	wm.listenerMu.RUnlock()
+	for _, fn := range hooks {
+		fn()
+	}
+}
+
+// AddCloser 注册随 Stop 一起关闭的资源（如带后台队列的 sink）
+func (wm *Watchman) AddCloser(c io.Closer) {
+	wm.closersMu.Lock()
+	defer wm.closersMu.Unlock()
+	wm.closers = append(wm.closers, c)
+}
+
+// AddEventListener 注册监听器。监听器按注册顺序调用；identify 已存在时原位替换(记录警告)，顺序不变。
+func (wm *Watchman) AddEventListener(identify string, listener EventListener) {
+	wm.addEntry(listenerEntry{identify: identify, listener: listener})
+}
+
+func (wm *Watchman) addEntry(entry listenerEntry) {
+	wm.listenerMu.Lock()
+	defer wm.listenerMu.Unlock()
+	if i := wm.listenerIndex(entry.identify); i >= 0 {
+		slog.Warn("listener replaced", "identify", entry.identify)
+		wm.listeners[i] = entry
+		return
+	}
+	wm.listeners = append(wm.listeners, entry)
+}
+
+// AddEventListenerUnique 同 AddEventListener，但 identify 已存在时返回 ErrDuplicateListener 而不是替换
+func (wm *Watchman) AddEventListenerUnique(identify string, listener EventListener) error {
+	return wm.addEntryUnique(listenerEntry{identify: identify, listener: listener})
+}
+
+func (wm *Watchman) addEntryUnique(entry listenerEntry) error {
+	wm.listenerMu.Lock()
+	defer wm.listenerMu.Unlock()
+	if wm.listenerIndex(entry.identify) >= 0 {
+		return fmt.Errorf("%w: %s", ErrDuplicateListener, entry.identify)
+	}
+	wm.listeners = append(wm.listeners, entry)
+	return nil
+}
+
+// listenerIndex 调用方需持有 listenerMu
+func (wm *Watchman) listenerIndex(identify string) int {
+	return slices.IndexFunc(wm.listeners, func(e listenerEntry) bool {
+		return e.identify == identify
+	})
+}
+
+// Listeners 返回已注册监听器的 identify，按调用顺序
+func (wm *Watchman) Listeners() []string {
+	wm.listenerMu.RLock()
+	defer wm.listenerMu.RUnlock()
+	ids := make([]string, len(wm.listeners))
+	for i, e := range wm.listeners {
+		ids[i] = e.identify
+	}
+	return ids
 }
 
 func (wm *Watchman) RemoveListener(identify string) {
 	wm.listenerMu.Lock()
 	defer wm.listenerMu.Unlock()
-	delete(wm.listeners, identify)
+	wm.listeners = slices.DeleteFunc(wm.listeners, func(e listenerEntry) bool {
+		return e.identify == identify
+	})
 }
 
 func (wm *Watchman) Watch(ctx context.Context, wg *sync.WaitGroup) {
+	wm.watching.Store(true)
+	wm.listenerMu.Lock()
+	wm.watchCtx = ctx
+	wm.listenerMu.Unlock()
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
@@ -143,16 +816,67 @@ func (wm *Watchman) Watch(ctx context.Context, wg *sync.WaitGroup) {
 		defer wg.Done()
 		wm.processEvents(ctx)
 	}()
+	if wm.exitTracker != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wm.exitTracker.run(ctx)
+		}()
+	}
+	if wm.enricher != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wm.enricher.watch(ctx, wm.clock.After)
+		}()
+	}
+	if wm.scanner != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wm.runScanner(ctx)
+		}()
+	}
+	if wm.bulk != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			wm.bulk.run(ctx)
+		}()
+	}
 }
 
 func (wm *Watchman) captureEvents(ctx context.Context) {
-	buffer := make([]byte, wm.eventBufferSize*1024)
+	// eventChan 只由发送方关闭，避免 Stop 并发关闭时向已关闭的 channel 发送
+	defer close(wm.eventChan)
+	// ctx 取消时同样唤醒 poll
+	defer context.AfterFunc(ctx, wm.wake)()
+	fds := []unix.PollFd{{Fd: int32(wm.ffd), Events: unix.POLLIN}, {Fd: int32(wm.wakefd), Events: unix.POLLIN}}
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		default:
+			// 阻塞在 poll 而不是 read 上，Interrupt 写 wakefd 即可唤醒
+			n, err := unix.Poll(fds, wm.readBuf.pollTimeout())
+			if err != nil {
+				if errors.Is(err, unix.EINTR) {
+					continue
+				}
+				return
+			}
+			if n == 0 {
+				wm.readBuf.idle()
+				continue
+			}
+			if fds[1].Revents != 0 || fds[0].Revents&(unix.POLLERR|unix.POLLNVAL) != 0 {
+				return
+			}
+			if fds[0].Revents&unix.POLLIN == 0 {
+				continue
+			}
 			// 读取事件数据，可能读取到多个事件
+			buffer := wm.readBuf.buf
 			read, err := unix.Read(wm.ffd, buffer)
 			if err != nil {
 				if errors.Is(err, unix.EBADF) || errors.Is(err, unix.EINTR) {
@@ -160,135 +884,726 @@ func (wm *Watchman) captureEvents(ctx context.Context) {
 				}
 				continue
 			}
+			wm.readBuf.observe(read)
+			if wm.recorder != nil && wm.inotify == nil {
+				wm.recorder.write(buffer[:read], wm.clock.Now())
+			}
 
-			data := buffer[:read]
-			// 循环处理每个事件
-			for len(data) >= EventMetadataLen {
-				// 事件长度
-				eventLen := binary.LittleEndian.Uint32(data[0:4])
-				if int(eventLen) > len(data) || eventLen == 0 {
-					break
-				}
-				// 检查事件版本, 只处理版本为3的事件
-				if data[4] != unix.FANOTIFY_METADATA_VERSION {
-					data = data[eventLen:]
-					continue
-				}
-				// 读取事件掩码
-				mask := binary.LittleEndian.Uint64(data[8:16])
+			var batch []Event
+			parse := parseEvents
+			if wm.inotify != nil {
+				parse = wm.inotify.parse
+			}
+			parse(buffer[:read], func(event Event) bool {
 				// 检查溢出标志
-				if mask&unix.FAN_Q_OVERFLOW != 0 {
+				if event.Mask&unix.FAN_Q_OVERFLOW != 0 {
+					wm.stats.overflows.Add(1)
 					slog.Warn("queue overflow - events lost")
-					data = data[eventLen:]
-					continue
+					wm.scanner.overflowed()
+					wm.overflowed()
+					// 仍经 eventChan 投递，由 handleEvent 转为 OVERFLOW 事件，保持与前后事件的顺序
+					batch = append(batch, event)
+					return true
 				}
-				// 读取事件数据
-				eventData := data[EventMetadataLen:eventLen]
-
-				var handle []byte
-				if len(eventData) >= EventInfoFidLen+FileHandleLen {
-					handle = make([]byte, len(eventData))
-					copy(handle, eventData)
+				wm.stats.captured.Add(1)
+				batch = append(batch, event)
+				return true
+			})
+			if wm.inotify == nil {
+				if wm.moves != nil {
+					wm.moves.assign(batch)
 				}
-
+				wm.resolveBatch(batch)
+			}
+			for i, event := range batch {
 				select {
 				case <-ctx.Done():
+					for _, e := range batch[i:] {
+						if e.Pidfd >= 0 {
+							_ = unix.Close(e.Pidfd)
+						}
+					}
 					return
-				case wm.eventChan <- Event{
-					Mask:   mask,
-					IsDir:  (mask & unix.FAN_ONDIR) != 0,
-					Handle: handle,
-				}:
+				case wm.eventChan <- event:
 				}
-				// 移动到下一个事件
-				data = data[eventLen:]
 			}
 		}
 	}
 }
 
+// parseEvents 解析一次 read 得到的缓冲区，对每个事件调用 fn，fn 返回 false 时停止
+func parseEvents(data []byte, fn func(Event) bool) {
+	// 循环处理每个事件
+	for len(data) >= EventMetadataLen {
+		// 事件长度
+		eventLen := binary.LittleEndian.Uint32(data[0:4])
+		if int(eventLen) > len(data) || int(eventLen) < EventMetadataLen {
+			break
+		}
+		// 检查事件版本, 只处理版本为3的事件
+		if data[4] != unix.FANOTIFY_METADATA_VERSION {
+			data = data[eventLen:]
+			continue
+		}
+		// 读取事件掩码
+		mask := binary.LittleEndian.Uint64(data[8:16])
+		// 读取事件数据
+		handle, oldHandle, pidfd := parseInfoRecords(data[EventMetadataLen:eventLen])
+		event := Event{
+			Mask:      mask,
+			IsDir:     (mask & unix.FAN_ONDIR) != 0,
+			Handle:    handle,
+			OldHandle: oldHandle,
+			Pid:       int32(binary.LittleEndian.Uint32(data[20:24])),
+			Pidfd:     pidfd,
+		}
+		if len(handle) >= EventInfoFidLen {
+			copy(event.Fsid[:], handle[4:EventInfoFidLen])
+		}
+		if !fn(event) {
+			return
+		}
+		// 移动到下一个事件
+		data = data[eventLen:]
+	}
+}
+
+// parseInfoRecords 解析事件元数据之后的 info 记录，返回 FID 类记录（含 header）、FAN_RENAME 的原位置记录
+// 和 pidfd（无则为 -1）；FAN_RENAME 事件以新位置记录作为 FID 类记录
+func parseInfoRecords(data []byte) ([]byte, []byte, int) {
+	var handle, oldHandle []byte
+	pidfd := -1
+	for len(data) >= 4 {
+		infoType := data[0]
+		infoLen := int(binary.LittleEndian.Uint16(data[2:4]))
+		if infoLen < 4 || infoLen > len(data) {
+			break
+		}
+		record := data[:infoLen]
+		switch infoType {
+		case unix.FAN_EVENT_INFO_TYPE_FID, unix.FAN_EVENT_INFO_TYPE_DFID_NAME, unix.FAN_EVENT_INFO_TYPE_DFID,
+			unix.FAN_EVENT_INFO_TYPE_NEW_DFID_NAME:
+			if handle == nil && len(record) >= EventInfoFidLen+FileHandleLen {
+				handle = make([]byte, len(record))
+				copy(handle, record)
+			}
+		case unix.FAN_EVENT_INFO_TYPE_OLD_DFID_NAME:
+			if oldHandle == nil && len(record) >= EventInfoFidLen+FileHandleLen {
+				oldHandle = make([]byte, len(record))
+				copy(oldHandle, record)
+			}
+		case unix.FAN_EVENT_INFO_TYPE_PIDFD:
+			if len(record) >= 8 {
+				pidfd = int(int32(binary.LittleEndian.Uint32(record[4:8])))
+			}
+		}
+		data = data[infoLen:]
+	}
+	if pidfd < 0 {
+		// FAN_NOPIDFD / FAN_EPIDFD
+		pidfd = -1
+	}
+	return handle, oldHandle, pidfd
+}
+
 func (wm *Watchman) processEvents(ctx context.Context) {
+	defer close(wm.processDone)
+	if wm.dispatchWorkers > 1 {
+		wm.dispatcher = newShardedDispatcher(wm.dispatchWorkers, wm.dispatchQueue, wm.shardByDir, wm.deliver)
+		defer wm.dispatcher.close()
+	}
+	if wm.session != nil {
+		wm.emitSession(EventSessionStart)
+		// 在暂存的临时文件事件投递之后、分片 worker 关闭之前执行，保证是最后一个事件
+		defer wm.emitSession(EventSessionEnd)
+	}
+	var debounced chan *EventInfo
+	if wm.debounce != nil {
+		debounced = wm.debounce.release
+		// 在暂存的临时文件事件交给 debouncer 之后执行
+		defer func() {
+			for _, info := range wm.debounce.drain() {
+				wm.emit(info)
+			}
+		}()
+	}
+	var released chan *EventInfo
+	if wm.ephemeral != nil {
+		released = wm.ephemeral.release
+		defer func() {
+			for _, info := range wm.ephemeral.drain() {
+				wm.settle(info)
+			}
+		}()
+	}
+	var moved chan *heldMove
+	if wm.moves != nil {
+		moved = wm.moves.release
+		defer func() {
+			for _, info := range wm.moves.drain() {
+				wm.settle(info)
+			}
+		}()
+	}
 	for {
 		select {
 		case <-ctx.Done():
 			return
+		case <-wm.drainAbort:
+			return
+		case info := <-wm.synthChan:
+			wm.dispatch(info)
+		case info := <-released:
+			if wm.ephemeral.expired(info) {
+				wm.settle(info)
+			}
+		case h := <-moved:
+			if wm.moves.expired(h) {
+				wm.settle(h.info)
+			}
+		case info := <-debounced:
+			if wm.debounce.expired(info) {
+				wm.emit(info)
+			}
 		case event, ok := <-wm.eventChan:
 			if !ok {
 				return
 			}
-			directory, filename, ok := wm.resolve(event.Handle)
-			if !ok || (directory == "" || filename == "") {
-				continue
-			}
-			fullPath := filepath.Join(directory, filename)
-			if event.IsDir {
-				continue
-			}
+			wm.handleEvent(&event)
+		}
+	}
+}
 
-			wm.filterMu.RLock()
-			_, _, matched := wm.filter.LongestPrefix(fullPath)
-			wm.filterMu.RUnlock() // 尽快释放锁，不要用 defer 因为会拉长锁时间
-			if !matched {
-				continue
-			}
-			eventType := wm.maskToString(event.Mask)
-			if _, ok = wm.fpcManager.Get(fullPath); ok {
-				continue
+// handleEvent 解析、过滤、去重并投递单个事件；event.Pidfd 未被接管时在返回前关闭
+func (wm *Watchman) handleEvent(event *Event) {
+	if event.Mask&unix.FAN_Q_OVERFLOW != 0 {
+		wm.dispatch(&EventInfo{Type: EventOverflow, Mask: event.Mask, Time: wm.clock.Now(), Attrs: map[string]any{"synthetic": true}})
+		return
+	}
+	if event.Pidfd >= 0 {
+		defer func() {
+			if event.Pidfd >= 0 {
+				_ = unix.Close(event.Pidfd)
 			}
-			wm.fpcManager.Add(fullPath, eventType)
+		}()
+	}
+	fsKnown := true
+	if wm.fsTypes != nil {
+		var excluded bool
+		if fsKnown, excluded = wm.fsTypes.lookup(event.Handle); excluded {
+			wm.stats.filtered.Add(1)
+			return
+		}
+	}
+	var directory, filename string
+	var ok bool
+	if event.resolved != nil {
+		directory, filename, ok = event.resolved.dir, event.resolved.name, event.resolved.ok
+	} else {
+		directory, filename, ok = wm.resolve(event.Handle)
+	}
+	if wm.rawLog {
+		wm.logRaw(event, directory, filename, ok)
+	}
+	if ok && filename == "" && wm.fidOnly && directory != "/" {
+		// 没有文件名时以 handle 解析出的对象自身为事件路径：内容类事件为文件，目录项类事件为其所在目录
+		directory, filename = filepath.Dir(directory), filepath.Base(directory)
+	} else if ok && filename == "." && directory != "/" {
+		// 目录自身的事件(如 DELETE_SELF)以 "." 为文件名
+		directory, filename = filepath.Dir(directory), filepath.Base(directory)
+	}
+	if !ok && wm.inotify == nil && event.Mask&lostDeleteEvents != 0 && wm.staleHandle(event.Handle) {
+		// 删除类事件的 handle 在缓存命中前已失效(通常是所在目录随后也被删除)，缓存中没有其路径，无法知道删除了什么；
+		// 与溢出一样请求一次遍历(on-overflow)，由监听器按遍历结果对账
+		lost := wm.stats.lostDeletes.Add(1)
+		if now := wm.clock.Now(); now.Sub(wm.lostWarnAt) >= time.Second {
+			// 整棵目录被删除时会连续丢失，每秒最多告警一次
+			wm.lostWarnAt = now
+			slog.Warn("delete events lost, their handles went stale before the paths were cached; enable watcher.scan.on-overflow to reconcile",
+				"total", lost)
+		}
+		wm.scanner.overflowed()
+		return
+	}
+	if !ok || (directory == "" || filename == "") {
+		return
+	}
+	if !fsKnown && wm.fsTypes.learn(event.Handle, directory) {
+		wm.stats.filtered.Add(1)
+		return
+	}
+	fullPath := filepath.Join(directory, filename)
+	if event.IsDir && !wm.dirs || !event.IsDir && !wm.files {
+		wm.stats.filtered.Add(1)
+		return
+	}
+	if wm.self.has(fullPath) {
+		wm.stats.filtered.Add(1)
+		return
+	}
 
-			wm.listenerMu.RLock()
-			snapshot := make(map[string]Listener, len(wm.listeners))
-			for k, v := range wm.listeners {
-				snapshot[k] = v
-			}
-			wm.listenerMu.RUnlock()
-			for _, l := range snapshot {
-				l(eventType, directory, filename, event.IsDir)
-			}
+	rule, matched := wm.matchPath(fullPath, filename, event.IsDir)
+	if !matched && wm.symlinks != nil {
+		if linked, ok := wm.symlinks.translate(fullPath); ok {
+			fullPath, directory, filename = linked, filepath.Dir(linked), filepath.Base(linked)
+			rule, matched = wm.matchPath(fullPath, filename, event.IsDir)
 		}
 	}
+	mask := event.Mask
+	var oldPath string
+	if mask&unix.FAN_RENAME != 0 {
+		fullPath, directory, filename, oldPath, rule, matched, mask = wm.pairRename(event, fullPath, rule, matched)
+	}
+	var from *EventInfo
+	if wm.moves != nil && event.Cookie != 0 && mask&unix.FAN_MOVED_TO != 0 {
+		if from = wm.moves.take(event.Cookie); from != nil {
+			// 新位置被过滤时原位置单独投递为 MOVED_FROM
+			defer func() {
+				if from != nil {
+					logPairing("unpaired", event.Cookie, from.Path, fullPath, wm.moves.window)
+					wm.settle(from)
+				}
+			}()
+			oldPath, mask = from.HostPath(), mask&^unix.FAN_MOVED_TO|unix.FAN_RENAME
+		}
+	}
+	// name-anywhere 命中时没有对应的规则，不做层级限制
+	if !matched || (wm.maxDepth > 0 && rule != "" && relativeDepth(fullPath, wm.ruleBase(rule)) > wm.maxDepth) {
+		wm.stats.filtered.Add(1)
+		return
+	}
+	if wm.symlinks != nil && mask&unix.FAN_CREATE != 0 {
+		wm.symlinks.observe(fullPath, wm.symlinkRoots)
+	}
+	eventType := maskToString(mask)
+	info := &EventInfo{
+		Type:    eventType,
+		Dir:     directory,
+		Name:    filename,
+		Path:    fullPath,
+		OldPath: oldPath,
+		IsDir:   event.IsDir,
+		Mask:    mask,
+		Pid:     event.Pid,
+		Fsid:    event.Fsid,
+		Time:    wm.clock.Now(),
+
+		MatchedRule: rule,
+	}
+	if wm.uids != nil {
+		if uid, ok := wm.uids.lookup(event.Pid, event.Pidfd); ok {
+			info.Uid = &uid
+		}
+	}
+	if !wm.decorate(info, rule) {
+		wm.stats.filtered.Add(1)
+		return
+	}
+	if from != nil {
+		logPairing("paired", event.Cookie, from.Path, info.Path, wm.moves.window)
+		from = nil
+	}
+	if wm.moves != nil && event.Cookie != 0 && mask&unix.FAN_MOVED_FROM != 0 {
+		if displaced := wm.moves.hold(event.Cookie, info); displaced != nil {
+			wm.settle(displaced)
+		}
+		return
+	}
+	if wm.exitTracker != nil && event.Pidfd >= 0 && event.Mask&unix.FAN_CLOSE_WRITE != 0 {
+		if wm.exitTracker.track(event.Pidfd, info) {
+			event.Pidfd = -1
+		}
+	}
+	if wm.ephemeral != nil && wm.ephemeral.hold(info) {
+		return
+	}
+	wm.settle(info)
 }
 
-func (wm *Watchman) resolve(data []byte) (string, string, bool) {
-	if len(data) < EventInfoFidLen {
-		return "", "", false
+// settle 开启 debounce 时暂存事件等待安静期，否则直接 emit
+func (wm *Watchman) settle(info *EventInfo) {
+	if wm.debounce == nil {
+		wm.emit(info)
+		return
+	}
+	if wm.debounce.hold(info) {
+		wm.stats.debounced.Add(1)
 	}
+}
 
-	infoType := data[0]
-	handleData := data[EventInfoFidLen:]
-	if len(handleData) < FileHandleLen {
-		return "", "", false
+// decorate 对已命中路径规则的事件做表达式与大小过滤，并附加属性、相对路径与路径改写；被过滤时返回 false
+func (wm *Watchman) decorate(info *EventInfo, rule string) bool {
+	if wm.exprFilter != nil && !wm.exprFilter.match(info) {
+		return false
+	}
+	if (wm.minSize > 0 || wm.maxSize > 0) && !info.IsDir {
+		if st := info.FileStat(); st != nil && (st.Size < wm.minSize || wm.maxSize > 0 && st.Size > wm.maxSize) {
+			return false
+		}
+	}
+	if wm.enricher != nil {
+		wm.enricher.apply(info)
+	}
+	// name-anywhere 命中时没有对应的规则，不填充
+	if wm.relativePaths && rule != "" {
+		info.Root, info.RelPath = relativeTo(info.Path, wm.ruleBase(rule))
 	}
+	// 过滤始终基于本命名空间的路径，改写只影响上报内容
+	if wm.translation != nil {
+		info.origPath, info.origOldPath = info.Path, info.OldPath
+		info.Path = wm.translation.apply(info.Path)
+		info.Dir = filepath.Dir(info.Path)
+		if info.Root != "" {
+			info.Root = wm.translation.apply(info.Root)
+		}
+		if info.OldPath != "" {
+			info.OldPath = wm.translation.apply(info.OldPath)
+		}
+	}
+	return true
+}
 
-	handleBytes := binary.LittleEndian.Uint32(handleData[0:4])
-	handleType := int32(binary.LittleEndian.Uint32(handleData[4:8]))
+// emit 去重后投递已通过过滤的事件
+func (wm *Watchman) emit(info *EventInfo) {
+	if !wm.dedupOff && wm.duplicate(wm.dedupKey(info), info.Type, info.Time) {
+		wm.stats.deduped.Add(1)
+		return
+	}
+	if wm.bulk != nil && wm.bulk.observe(info) {
+		wm.stats.bulked.Add(1)
+		return
+	}
+	wm.stats.recordDispatch(info.Type, info.MatchedRule)
+	wm.dispatch(info)
+}
 
-	if int(handleBytes) > len(handleData)-FileHandleLen {
-		return "", "", false
+// SetClock 替换时间来源，须在 Watch 之前调用；测试中用 clock.Fake 驱动依赖时间的逻辑。
+// 注意 fd/路径缓存的 TTL 由 LRU 内部计时，不受影响。
+func (wm *Watchman) SetClock(c clock.Clock) {
+	wm.clock = c
+	wm.mono = newMonoClock(c.Now())
+	if wm.exitTracker != nil {
+		wm.exitTracker.clock = c
+	}
+	if wm.ephemeral != nil {
+		wm.ephemeral.clock = c
+	}
+	if wm.moves != nil {
+		wm.moves.clock = c
 	}
+	if wm.debounce != nil {
+		wm.debounce.clock = c
+	}
+	if wm.bulk != nil {
+		wm.bulk.clock = c
+	}
+}
 
-	handleRaw := handleData[FileHandleLen : FileHandleLen+int(handleBytes)]
-	cacheKey := wm.generateCacheKey(handleType, handleRaw)
+// Lockstep 报告是否要求各 sink 按相同顺序接收事件。为 true 时自带队列的 sink 应在队列满时阻塞而不是丢弃，
+// 事件被所有监听器接收后才会投递下一个。
+func (wm *Watchman) Lockstep() bool {
+	return wm.lockstep
+}
 
-	basePath, ok := wm.fdcManager.Get(cacheKey)
+// Clock 返回当前时间来源，供中间件等共享
+func (wm *Watchman) Clock() clock.Clock {
+	return wm.clock
+}
 
+// EnableRawLog 开启原始事件日志，用于排查 handle 无法解析的问题
+func (wm *Watchman) EnableRawLog() {
+	wm.rawLog = true
+}
+
+func (wm *Watchman) logRaw(event *Event, directory, filename string, resolved bool) {
+	attrs := []any{
+		"mask", maskToString(event.Mask),
+		"pid", event.Pid,
+		"handle", base64.StdEncoding.EncodeToString(event.Handle),
+		"resolved", resolved,
+		"dir", directory,
+		"name", filename,
+	}
+	if len(event.Handle) >= EventInfoFidLen {
+		attrs = append(attrs, "info_type", event.Handle[0], "fsid", hex.EncodeToString(event.Handle[4:EventInfoFidLen]))
+	}
+	slog.Info("raw event", attrs...)
+}
+
+// ExportPaths 返回当前生效的监控路径（前缀、通配与正则），已排序
+func (wm *Watchman) ExportPaths() []string {
+	wm.filterMu.RLock()
+	defer wm.filterMu.RUnlock()
+	paths := make([]string, 0, wm.filter.Len()+len(wm.globFilter.sources)+len(wm.regexFilter.sources))
+	wm.filter.Walk(func(p string, _ interface{}) bool {
+		paths = append(paths, p)
+		return false
+	})
+	paths = append(paths, wm.globFilter.sources...)
+	paths = append(paths, wm.regexFilter.sources...)
+	slices.Sort(paths)
+	return paths
+}
+
+// warnMissing filesystem 标记下路径写错(如 /dat)时不会报错而是永远匹配不到事件，逐个提示
+func warnMissing(paths []settings.WatchPath) {
+	for _, p := range settings.MissingPaths(paths) {
+		slog.Warn("watch path does not exist, no events will match it until it is created", "path", p)
+	}
+}
+
+// ExportWatchPaths 与 ExportPaths 相同但带匹配方式，用于写回配置文件；匹配方式与按字符串推断的一致时不填 MatchMode
+func (wm *Watchman) ExportWatchPaths() []settings.WatchPath {
+	wm.filterMu.RLock()
+	defer wm.filterMu.RUnlock()
+	var paths []settings.WatchPath
+	add := func(p, mode string) {
+		wp := settings.WatchPath{Path: p, MatchMode: mode}
+		if (settings.WatchPath{Path: p}).Mode() == mode {
+			wp.MatchMode = ""
+		}
+		paths = append(paths, wp)
+	}
+	wm.filter.Walk(func(p string, _ interface{}) bool {
+		add(p, settings.MatchPrefix)
+		return false
+	})
+	for _, p := range wm.globFilter.sources {
+		add(p, settings.MatchGlob)
+	}
+	for _, p := range wm.regexFilter.sources {
+		add(p, settings.MatchRegex)
+	}
+	slices.SortFunc(paths, func(a, b settings.WatchPath) int { return strings.Compare(a.Path, b.Path) })
+	return paths
+}
+
+// buildFilter 按匹配方式拆分监控路径：前缀放入 radix 树，通配与正则分别编译为匹配器；prefixes 为其中的前缀路径
+func buildFilter(paths []settings.WatchPath) (*radix.Tree, *globMatcher, *regexMatcher, []string, error) {
+	tree := radix.New()
+	var globs, regexes, prefixes []string
+	for _, p := range paths {
+		switch p.Mode() {
+		case settings.MatchGlob:
+			globs = append(globs, p.Path)
+		case settings.MatchRegex:
+			regexes = append(regexes, p.Path)
+		default:
+			tree.Insert(p.Path, true)
+			prefixes = append(prefixes, p.Path)
+		}
+	}
+	re, err := newRegexMatcher(regexes)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	return tree, newGlobMatcher(globs), re, prefixes, nil
+}
+
+// pathRoot 返回监控路径对应的目录，用于标记、扫描等需要实际目录的场合：前缀为自身，
+// 通配为第一个通配段之前的目录，正则见 regexRoot
+func pathRoot(p settings.WatchPath) string {
+	switch p.Mode() {
+	case settings.MatchGlob:
+		return staticPrefix(p.Path)
+	case settings.MatchRegex:
+		return regexRoot(p.Path)
+	}
+	return p.Path
+}
+
+// ruleBase 返回计算相对层级与相对路径时使用的规则：正则规则取 regexRoot，其余原样返回
+func (wm *Watchman) ruleBase(rule string) string {
+	wm.filterMu.RLock()
+	defer wm.filterMu.RUnlock()
+	if root, ok := wm.regexFilter.roots[rule]; ok {
+		return root
+	}
+	return rule
+}
+
+// matchPath 判断路径是否命中监控规则，并返回命中的监控路径（仅由文件名规则命中时为空）。
+// 默认需命中前缀/通配路径，且配置了 name-patterns 时文件名也须命中；
+// name-anywhere 模式下文件名规则独立生效，文件系统任意位置的同名文件都会上报。
+func (wm *Watchman) matchPath(fullPath, filename string, isDir bool) (string, bool) {
+	wm.filterMu.RLock()
+	rule, _, matched := wm.filter.LongestPrefix(fullPath)
+	if !matched {
+		rule, matched = wm.globFilter.match(fullPath)
+	}
+	if !matched {
+		rule, matched = wm.regexFilter.match(fullPath)
+	}
+	excluded := wm.excluded(fullPath, filename, rule)
+	wm.filterMu.RUnlock() // 尽快释放锁，不要用 defer 因为会拉长锁时间
+	if excluded || !isDir && !matchExtension(wm.extensions, filename) {
+		return "", false
+	}
+	if len(wm.namePatterns) == 0 {
+		return rule, matched
+	}
+	if matched && matchName(wm.namePatterns, filename) {
+		return rule, true
+	}
+	if wm.nameAnywhere {
+		return rule, matched || matchName(wm.namePatterns, filename)
+	}
+	return "", false
+}
+
+// splitExclude 将排除规则分为前缀树(绝对路径)与文件名规则
+func splitExclude(exclude []string) (*radix.Tree, []string) {
+	t := radix.New()
+	var names []string
+	for _, p := range exclude {
+		if filepath.IsAbs(p) {
+			t.Insert(p, true)
+		} else {
+			names = append(names, p)
+		}
+	}
+	return t, names
+}
+
+// excluded 判断路径是否被排除，调用方持有 filterMu。优先级：取路径命中的最长排除前缀，
+// 比命中的监控路径更长(更具体)时排除，因此 /data 内排除 /data/tmp 后，配置 /data/tmp/keep 可重新纳入其子树；
+// 通配与正则监控路径按其所在目录(见 pathRoot)比较，仅由文件名规则命中(rule 为空)时任意排除前缀都生效。
+// 排除前缀按路径段匹配，/data/tmp 不会排除 /data/tmpfile；文件名规则不论层级，命中即排除。
+func (wm *Watchman) excluded(fullPath, filename, rule string) bool {
+	if matchName(wm.excludeNames, filename) {
+		return true
+	}
+	if wm.excludeFilter.Len() == 0 {
+		return false
+	}
+	if root, ok := wm.regexFilter.roots[rule]; ok {
+		rule = root
+	} else if isGlob(rule) {
+		rule = staticPrefix(rule)
+	}
+	longest := -1
+	wm.excludeFilter.WalkPath(fullPath, func(p string, _ interface{}) bool {
+		if len(p) == len(fullPath) || fullPath[len(p)] == '/' || p == "/" {
+			longest = len(p)
+		}
+		return false
+	})
+	return longest > len(rule)
+}
+
+// newExtensionSet 将 watcher.extensions 统一为不带点的小写形式，为空时返回 nil
+func newExtensionSet(exts []string) map[string]bool {
+	if len(exts) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(exts))
+	for _, e := range exts {
+		set[strings.ToLower(strings.TrimPrefix(e, "."))] = true
+	}
+	return set
+}
+
+func matchExtension(set map[string]bool, filename string) bool {
+	return set == nil || set[strings.ToLower(strings.TrimPrefix(filepath.Ext(filename), "."))]
+}
+
+func matchName(patterns []string, filename string) bool {
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, filename); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// dispatch 投递事件：未启用分片时在事件循环中直接调用监听器，否则交给路径对应的 worker
+func (wm *Watchman) dispatch(info *EventInfo) {
+	info.Monotonic = wm.mono.of(info.Time)
+	if wm.history != nil {
+		wm.history.record(info)
+	}
+	if wm.dispatcher != nil {
+		wm.dispatcher.submit(info)
+		return
+	}
+	wm.deliver(info)
+}
+
+// deliver 按注册顺序依次调用监听器，保证 Attrs 的读写顺序确定
+func (wm *Watchman) deliver(info *EventInfo) {
+	wm.listenerMu.RLock()
+	snapshot := slices.Clone(wm.listeners)
+	wm.listenerMu.RUnlock()
+	for _, e := range snapshot {
+		if e.accepts(info) {
+			wm.call(e, info)
+		}
+	}
+}
+
+// call 调用单个监听器；监听器 panic 时记录为该监听器在事件命中规则下的一次失败，不影响后续监听器
+func (wm *Watchman) call(e listenerEntry, info *EventInfo) {
+	defer func() {
+		if r := recover(); r != nil {
+			wm.stats.recordListenerError(e.identify, info, r, wm.clock.Now())
+			slog.Error("listener panicked", "listener", e.identify, "path", info.Path, "rule", info.MatchedRule, "panic", r)
+		}
+	}()
+	e.listener(info)
+}
+
+func (wm *Watchman) resolve(data []byte) (string, string, bool) {
+	cacheKey, fsid, handleType, handleRaw, ok := wm.handleKey(data)
 	if !ok {
+		return "", "", false
+	}
+	infoType := data[0]
+	handleData := data[EventInfoFidLen:]
+	handleBytes := len(handleRaw)
+
+	if _, dead := wm.fdcNegative.Get(cacheKey); dead {
+		wm.stats.resolveNeg.Add(1)
+		return "", "", false
+	}
+	basePath, ok := wm.fdcManager.Get(cacheKey)
+
+	if ok {
+		wm.stats.resolveHits.Add(1)
+	} else {
+		wm.stats.resolveMisses.Add(1)
 		fh := unix.NewFileHandle(handleType, handleRaw)
-		fd, err := unix.OpenByHandleAt(wm.rfd, fh, unix.O_PATH|unix.O_CLOEXEC)
+		wm.resolveLimit.acquire()
+		fd, err := unix.OpenByHandleAt(wm.mounts.fd(fsid, wm.rfd), fh, unix.O_PATH|unix.O_CLOEXEC)
 		if err != nil {
+			wm.resolveLimit.release()
+			wm.stats.resolveErrors.Add(1)
+			// 只缓存 inode 已不存在的失败，EMFILE 等暂时性错误下次仍重试
+			switch {
+			case errors.Is(err, unix.ESTALE) || errors.Is(err, unix.ENOENT):
+				// 事件发生后对象即被删除，属于正常情况
+				wm.stats.resolveStale.Add(1)
+				wm.fdcNegative.Add(cacheKey, struct{}{})
+				slog.Debug("file handle is stale, object removed before resolve", "err", err)
+			case errors.Is(err, unix.EMFILE) || errors.Is(err, unix.ENFILE):
+				slog.Warn("resolve failed, out of file descriptors; raise the fd limit or lower watcher.max-inflight-resolves", "err", err)
+			default:
+				slog.Warn("resolve failed", "err", err)
+			}
 			return "", "", false
 		}
-		basePath, err = os.Readlink(fmt.Sprintf("/proc/self/fd/%d", fd))
+		basePath, err = fdPath(fd)
 		_ = unix.Close(fd)
+		wm.resolveLimit.release()
 		if err != nil {
 			return "", "", false
 		}
 		wm.fdcManager.Add(cacheKey, basePath)
+		wm.fdIndex.add(cacheKey, basePath)
 	}
 
-	if infoType == unix.FAN_EVENT_INFO_TYPE_DFID_NAME {
-		nameOffset := FileHandleLen + int(handleBytes)
+	if infoType == unix.FAN_EVENT_INFO_TYPE_DFID_NAME || infoType == unix.FAN_EVENT_INFO_TYPE_OLD_DFID_NAME ||
+		infoType == unix.FAN_EVENT_INFO_TYPE_NEW_DFID_NAME {
+		nameOffset := FileHandleLen + handleBytes
 		if len(handleData) > nameOffset {
 			rest := handleData[nameOffset:]
 			if i := bytes.IndexByte(rest, 0); i >= 0 {
@@ -307,8 +1622,35 @@ func (wm *Watchman) resolve(data []byte) (string, string, bool) {
 	return basePath, "", true
 }
 
-func (wm *Watchman) generateCacheKey(handleType int32, handleRaw []byte) string {
+// handleKey 解析 FID 类记录中的 handle，返回缓存键与打开 handle 所需的字段；记录不完整时 ok 为 false
+func (wm *Watchman) handleKey(data []byte) (key string, fsid []byte, handleType int32, handleRaw []byte, ok bool) {
+	if len(data) < EventInfoFidLen {
+		return "", nil, 0, nil, false
+	}
+	handleData := data[EventInfoFidLen:]
+	if len(handleData) < FileHandleLen {
+		return "", nil, 0, nil, false
+	}
+	handleBytes := binary.LittleEndian.Uint32(handleData[0:4])
+	handleType = int32(binary.LittleEndian.Uint32(handleData[4:8]))
+	if int(handleBytes) > len(handleData)-FileHandleLen {
+		return "", nil, 0, nil, false
+	}
+	handleRaw = handleData[FileHandleLen : FileHandleLen+int(handleBytes)]
+	fsid = data[4:EventInfoFidLen]
+	return wm.generateCacheKey(fsid, handleType, handleRaw), fsid, handleType, handleRaw, true
+}
+
+// staleHandle handle 是否已确认无法解析(对象已删除，见 fdcNegative)
+func (wm *Watchman) staleHandle(data []byte) bool {
+	key, _, _, _, ok := wm.handleKey(data)
+	return ok && wm.fdcNegative.Contains(key)
+}
+
+// generateCacheKey handle 只在同一文件系统内唯一，键中包含 fsid
+func (wm *Watchman) generateCacheKey(fsid []byte, handleType int32, handleRaw []byte) string {
 	h := fnv.New64a()
+	_, _ = h.Write(fsid)
 	var typeBuf [4]byte
 	binary.LittleEndian.PutUint32(typeBuf[:], uint32(handleType))
 	_, _ = h.Write(typeBuf[:])
@@ -316,7 +1658,21 @@ func (wm *Watchman) generateCacheKey(handleType int32, handleRaw []byte) string
 	return fmt.Sprintf("%016x", h.Sum64())
 }
 
-func (wm *Watchman) maskToString(mask uint64) string {
+// fdPath 通过 /proc/self/fd 取 fd 对应的路径。加入其他挂载命名空间后那里的 /proc 不属于本进程的 pid 命名空间，
+// 改用加入前保留的宿主机 /proc；readlink 的结果仍相对于目标命名空间的根目录。
+func fdPath(fd int) (string, error) {
+	if proc := nsenter.ProcFd(); proc >= 0 {
+		buf := make([]byte, unix.PathMax)
+		n, err := unix.Readlinkat(proc, fmt.Sprintf("self/fd/%d", fd), buf)
+		if err != nil {
+			return "", err
+		}
+		return string(buf[:n]), nil
+	}
+	return os.Readlink(fmt.Sprintf("/proc/self/fd/%d", fd))
+}
+
+func maskToString(mask uint64) string {
 	var events []string
 	if mask&unix.FAN_CREATE != 0 {
 		events = append(events, "CREATE")
@@ -333,6 +1689,18 @@ func (wm *Watchman) maskToString(mask uint64) string {
 	if mask&unix.FAN_MOVED_TO != 0 {
 		events = append(events, "MOVED_TO")
 	}
+	if mask&unix.FAN_MOVED_FROM != 0 {
+		events = append(events, "MOVED_FROM")
+	}
+	if mask&unix.FAN_RENAME != 0 {
+		events = append(events, "RENAME")
+	}
+	if mask&unix.FAN_MODIFY != 0 {
+		events = append(events, "MODIFY")
+	}
+	if mask&unix.FAN_ATTRIB != 0 {
+		events = append(events, "ATTRIB")
+	}
 	if len(events) == 0 {
 		return fmt.Sprintf("0x%x", mask)
 	}
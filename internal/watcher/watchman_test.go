@@ -0,0 +1,44 @@
+package watcher
+
+import (
+	"slices"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+// 监听器按注册顺序调用；替换保持原位，移除后重新注册排到末尾
+func TestListenerOrder(t *testing.T) {
+	wm := &Watchman{}
+	var calls []string
+	add := func(id string) {
+		wm.AddEventListener(id, func(*EventInfo) { calls = append(calls, id) })
+	}
+	check := func(want ...string) {
+		t.Helper()
+		calls = nil
+		wm.dispatch(&EventInfo{Type: "CREATE", Mask: unix.FAN_CREATE, Path: "/a"})
+		if !slices.Equal(calls, want) {
+			t.Errorf("called %v, want %v", calls, want)
+		}
+		if ids := wm.Listeners(); !slices.Equal(ids, want) {
+			t.Errorf("Listeners() = %v, want %v", ids, want)
+		}
+	}
+	for _, id := range []string{"a", "b", "c", "d"} {
+		add(id)
+	}
+	check("a", "b", "c", "d")
+	add("b")
+	check("a", "b", "c", "d")
+	wm.RemoveListener("a")
+	check("b", "c", "d")
+	add("a")
+	check("b", "c", "d", "a")
+	if err := wm.AddEventListenerUnique("c", func(*EventInfo) {}); err == nil {
+		t.Error("AddEventListenerUnique accepted a duplicate identify")
+	}
+	wm.AddListenerForEvents("e", []string{"CREATE"}, func(string, string, string, bool) { calls = append(calls, "e") })
+	wm.RemoveListener("c")
+	check("b", "d", "a", "e")
+}
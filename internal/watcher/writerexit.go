@@ -0,0 +1,130 @@
+package watcher
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/caoenergy/watchman/internal/clock"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// 同时跟踪的写入进程上限，避免占满 fd
+	maxTrackedWriters = 1024
+	// 每轮 poll 的超时，新加入的 pidfd 在下一轮生效
+	writerPollTimeoutMs = 200
+)
+
+// exitTracker 利用 FAN_REPORT_PIDFD 提供的 pidfd 跟踪写入文件的进程，进程退出时为其写过的每个路径
+// 合成一个 WRITER_EXIT 事件，用于"进程 X 完成了文件 Y 的生成"一类场景。
+type exitTracker struct {
+	mu     sync.Mutex
+	procs  map[int32]*writerProc
+	out    chan<- *EventInfo
+	closed bool
+	clock  clock.Clock
+}
+
+type writerProc struct {
+	pidfd int
+	paths map[string]*EventInfo
+}
+
+func newExitTracker(out chan<- *EventInfo, clk clock.Clock) *exitTracker {
+	return &exitTracker{procs: make(map[int32]*writerProc), out: out, clock: clk}
+}
+
+// track 记录 info.Pid 写入了 info.Path；返回 true 表示接管了 pidfd，调用方不能再关闭它
+func (t *exitTracker) track(pidfd int, info *EventInfo) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return false
+	}
+	if p, ok := t.procs[info.Pid]; ok {
+		p.paths[info.Path] = info
+		return false
+	}
+	if len(t.procs) >= maxTrackedWriters {
+		slog.Debug("writer tracking limit reached", "pid", info.Pid, "path", info.Path)
+		return false
+	}
+	t.procs[info.Pid] = &writerProc{pidfd: pidfd, paths: map[string]*EventInfo{info.Path: info}}
+	return true
+}
+
+func (t *exitTracker) run(ctx context.Context) {
+	for ctx.Err() == nil {
+		t.mu.Lock()
+		fds := make([]unix.PollFd, 0, len(t.procs))
+		pids := make([]int32, 0, len(t.procs))
+		for pid, p := range t.procs {
+			fds = append(fds, unix.PollFd{Fd: int32(p.pidfd), Events: unix.POLLIN})
+			pids = append(pids, pid)
+		}
+		t.mu.Unlock()
+		if len(fds) == 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-t.clock.After(writerPollTimeoutMs * time.Millisecond):
+			}
+			continue
+		}
+		n, err := unix.Poll(fds, writerPollTimeoutMs)
+		if err != nil || n == 0 {
+			continue
+		}
+		for i, fd := range fds {
+			// pidfd 可读表示进程已退出
+			if fd.Revents&(unix.POLLIN|unix.POLLHUP|unix.POLLERR|unix.POLLNVAL) != 0 {
+				t.exited(ctx, pids[i])
+			}
+		}
+	}
+}
+
+func (t *exitTracker) exited(ctx context.Context, pid int32) {
+	t.mu.Lock()
+	p, ok := t.procs[pid]
+	if ok {
+		delete(t.procs, pid)
+		_ = unix.Close(p.pidfd)
+	}
+	t.mu.Unlock()
+	if !ok {
+		return
+	}
+	for _, written := range p.paths {
+		select {
+		case <-ctx.Done():
+			return
+		// 命中规则、相对路径与改写前的路径沿用写入时的事件，监控组与路由按同样的方式处理
+		case t.out <- &EventInfo{
+			Type:        "WRITER_EXIT",
+			Dir:         written.Dir,
+			Name:        written.Name,
+			Path:        written.Path,
+			Pid:         pid,
+			Time:        t.clock.Now(),
+			Root:        written.Root,
+			RelPath:     written.RelPath,
+			MatchedRule: written.MatchedRule,
+			origPath:    written.origPath,
+		}:
+		}
+	}
+}
+
+func (t *exitTracker) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	for pid, p := range t.procs {
+		_ = unix.Close(p.pidfd)
+		delete(t.procs, pid)
+	}
+}
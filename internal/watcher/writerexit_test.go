@@ -0,0 +1,65 @@
+package watcher
+
+import (
+	"context"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/caoenergy/watchman/internal/clock"
+	"golang.org/x/sys/unix"
+)
+
+func TestWriterExitUsesClockAndOriginatingEvent(t *testing.T) {
+	cmd := exec.Command("sleep", "30")
+	if err := cmd.Start(); err != nil {
+		t.Skip(err)
+	}
+	defer func() { _ = cmd.Process.Kill() }()
+	pidfd, err := unix.PidfdOpen(cmd.Process.Pid, 0)
+	if err != nil {
+		t.Skipf("pidfd_open: %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+	fc := clock.NewFake(now)
+	out := make(chan *EventInfo, 1)
+	tracker := newExitTracker(out, fc)
+	defer tracker.close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go tracker.run(ctx)
+	// 让 run 先进入没有跟踪进程时的等待
+	time.Sleep(50 * time.Millisecond)
+
+	written := &EventInfo{Type: "CLOSE_WRITE", Dir: "/data/in", Name: "f", Path: "/data/in/f", Pid: int32(cmd.Process.Pid),
+		MatchedRule: "/host/data", Root: "/data", RelPath: "in/f", origPath: "/host/data/in/f"}
+	if !tracker.track(pidfd, written) {
+		t.Fatal("track did not take the pidfd")
+	}
+	_ = cmd.Process.Kill()
+	_ = cmd.Wait()
+	// 没有跟踪的进程时按注入的时钟等待，真实时间经过一轮 poll 超时后仍不会开始 poll
+	select {
+	case info := <-out:
+		t.Fatalf("WRITER_EXIT %s delivered before the fake clock advanced", info.Path)
+	case <-time.After(3 * writerPollTimeoutMs * time.Millisecond):
+	}
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case info := <-out:
+			if info.Type != "WRITER_EXIT" || info.Path != written.Path || info.MatchedRule != written.MatchedRule ||
+				info.HostPath() != written.origPath || info.RelPath != written.RelPath {
+				t.Fatalf("got %+v", info)
+			}
+			if !info.Time.After(now) {
+				t.Fatalf("event time %v not taken from the fake clock", info.Time)
+			}
+			return
+		case <-deadline:
+			t.Fatal("timed out waiting for WRITER_EXIT")
+		case <-time.After(10 * time.Millisecond):
+			fc.Advance(writerPollTimeoutMs * time.Millisecond)
+		}
+	}
+}
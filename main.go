@@ -2,23 +2,50 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log/slog"
 	"os"
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/caoenergy/watchman/cmd"
 	"github.com/caoenergy/watchman/internal/listener"
 )
 
 func main() {
+	raw := flag.Bool("raw", false, "log raw events (mask, handle, fsid) before resolving, for debugging")
+	debug := flag.Bool("debug", false, "enable debug logging (rename pairing, stale handles, buffer resizing)")
+	jsonOut := flag.Bool("json", false, "print events to stdout as JSON lines instead of plain paths")
+	record := flag.String("record", "", "write raw fanotify read buffers to `file` for later replay")
+	drainTimeout := flag.Duration("drain-timeout", 5*time.Second, "on SIGINT/SIGTERM, wait up to this long for already captured events to reach listeners; 0 stops immediately")
+	flag.Parse()
+	if *debug {
+		slog.SetLogLoggerLevel(slog.LevelDebug)
+	}
+	if flag.NArg() > 0 {
+		if err := cmd.Exec(flag.Arg(0), flag.Args()[1:]); err != nil {
+			slog.Error("subcommand failed", "name", flag.Arg(0), "err", err)
+			os.Exit(-1)
+		}
+		return
+	}
 	wm, err := cmd.Initialize()
 	if err != nil {
 		slog.Error("failed to initialize app", "err", err)
 		os.Exit(-1)
 	}
 	defer wm.Stop()
+	if *raw {
+		wm.EnableRawLog()
+	}
+	if *record != "" {
+		if err := wm.EnableRecord(*record); err != nil {
+			slog.Error("failed to enable recording", "err", err)
+			os.Exit(-1)
+		}
+	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	sigChan := make(chan os.Signal, 1)
@@ -26,11 +53,71 @@ func main() {
 
 	go func() {
 		sig := <-sigChan
-		slog.Info("received signal, triggering shutdown", "signal", sig)
+		slog.Info("received signal, triggering shutdown", "signal", sig, "drain_timeout", *drainTimeout)
+		if *drainTimeout <= 0 {
+			cancel()
+			wm.Interrupt() // 唤醒 captureEvents 使其退出并关闭 eventChan，processEvents 随之退出，否则会死锁；资源由 defer 的 Stop 释放
+			return
+		}
+		// 停止读取新事件，已读取的事件投递完毕后再退出；排空期间再次收到信号则立即停止
+		dctx, dcancel := context.WithTimeout(ctx, *drainTimeout)
+		go func() {
+			select {
+			case <-sigChan:
+				dcancel()
+			case <-dctx.Done():
+			}
+		}()
+		if err := wm.StopAndDrain(dctx); err != nil {
+			slog.Warn("shutdown before all captured events were delivered", "err", err)
+		}
+		dcancel()
 		cancel()
-		wm.Stop() // 关闭 ffd/eventChan，让 captureEvents 和 processEvents 能退出，否则会死锁
 	}()
-	wm.AddListener("logging", listener.LoggingHandler)
+	// SIGUSR1: 输出运行时统计
+	statsChan := make(chan os.Signal, 1)
+	signal.Notify(statsChan, syscall.SIGUSR1)
+	go func() {
+		for range statsChan {
+			st := wm.Stats()
+			slog.Info("stats", "captured", st.Captured, "overflows", st.Overflows, "filtered", st.Filtered,
+				"deduped", st.Deduped, "dispatched", st.Dispatched, "bulked", st.Bulked, "debounced", st.Debounced, "bulk_dirs", st.BulkDirs, "queue_len", st.QueueLen, "buffer_kb", st.BufferKB,
+				"resolve_inflight", st.ResolveInflight, "resolve_max_inflight", st.ResolveMaxInflight,
+				"resolve_miss_rate", st.ResolveMissRate, "resolve_opens_per_sec", st.ResolveOpensPerSec,
+				"resolve_open_errors", st.ResolveOpenErrors, "resolve_negative_hits", st.ResolveNegativeHits,
+				"resolve_stale", st.ResolveStale, "lost_deletes", st.LostDeletes,
+				"by_type", st.ByType, "by_prefix", st.ByPrefix, "adaptive_ttl", st.AdaptiveTTL,
+				"listener_errors", st.ListenerErrors, "sinks", st.Sinks)
+			for _, p := range wm.Plugins() {
+				slog.Info("plugin", "name", p.Name, "version", p.Version, "path", p.Path, "loaded_at", p.LoadedAt)
+			}
+		}
+	}()
+	// SIGUSR2: 将运行时的监控路径写回配置文件，重启后保持
+	persistChan := make(chan os.Signal, 1)
+	signal.Notify(persistChan, syscall.SIGUSR2)
+	go func() {
+		for range persistChan {
+			if err := cmd.PersistPaths(wm); err != nil {
+				slog.Error("failed to persist watch paths", "err", err)
+			}
+		}
+	}()
+	// SIGHUP: 重新读取配置文件中的监控路径，不重启、不丢弃 fanotify 队列
+	reloadChan := make(chan os.Signal, 1)
+	signal.Notify(reloadChan, syscall.SIGHUP)
+	go func() {
+		for range reloadChan {
+			if err := cmd.ReloadPaths(wm); err != nil {
+				slog.Error("failed to reload watch paths, keeping current paths", "err", err)
+			}
+		}
+	}()
+	if *jsonOut {
+		wm.AddEventListener("logging", listener.JSONHandler(os.Stdout))
+	} else {
+		wm.AddListener("logging", listener.LoggingHandler)
+	}
 	var wg sync.WaitGroup
 	wm.Watch(ctx, &wg)
 	wg.Wait()
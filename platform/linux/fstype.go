@@ -0,0 +1,41 @@
+package linux
+
+import "golang.org/x/sys/unix"
+
+// fsMagic 常见文件系统类型名与 statfs f_type 魔数的对应关系；ext2/ext3/ext4 共用同一魔数
+var fsMagic = map[string]int64{
+	"autofs":     unix.AUTOFS_SUPER_MAGIC,
+	"bpf":        unix.BPF_FS_MAGIC,
+	"btrfs":      unix.BTRFS_SUPER_MAGIC,
+	"cgroup":     unix.CGROUP_SUPER_MAGIC,
+	"cgroup2":    unix.CGROUP2_SUPER_MAGIC,
+	"cifs":       unix.CIFS_SUPER_MAGIC,
+	"debugfs":    unix.DEBUGFS_MAGIC,
+	"devpts":     unix.DEVPTS_SUPER_MAGIC,
+	"efivarfs":   unix.EFIVARFS_MAGIC,
+	"ext4":       unix.EXT4_SUPER_MAGIC,
+	"f2fs":       unix.F2FS_SUPER_MAGIC,
+	"fuse":       unix.FUSE_SUPER_MAGIC,
+	"hugetlbfs":  unix.HUGETLBFS_MAGIC,
+	"nfs":        unix.NFS_SUPER_MAGIC,
+	"nsfs":       unix.NSFS_MAGIC,
+	"overlay":    unix.OVERLAYFS_SUPER_MAGIC,
+	"proc":       unix.PROC_SUPER_MAGIC,
+	"pstore":     unix.PSTOREFS_MAGIC,
+	"ramfs":      unix.RAMFS_MAGIC,
+	"securityfs": unix.SECURITYFS_MAGIC,
+	"smb2":       unix.SMB2_SUPER_MAGIC,
+	"squashfs":   unix.SQUASHFS_MAGIC,
+	"sysfs":      unix.SYSFS_MAGIC,
+	"tmpfs":      unix.TMPFS_MAGIC,
+	"tracefs":    unix.TRACEFS_MAGIC,
+	"vfat":       unix.MSDOS_SUPER_MAGIC,
+	"xfs":        unix.XFS_SUPER_MAGIC,
+	"zonefs":     unix.ZONEFS_MAGIC,
+}
+
+// FsMagic 返回文件系统类型名对应的 statfs 魔数
+func FsMagic(name string) (int64, bool) {
+	m, ok := fsMagic[name]
+	return m, ok
+}
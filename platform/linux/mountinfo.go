@@ -0,0 +1,101 @@
+package linux
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// MountPoint 按 /proc/self/mountinfo 返回 path(先解析符号链接)所在的挂载点，即最长的、在路径分段边界上匹配的挂载目录
+func MountPoint(path string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", err
+	}
+	mounts, err := mountPoints()
+	if err != nil {
+		return "", err
+	}
+	best := ""
+	for _, mp := range mounts {
+		if len(mp) > len(best) && (mp == "/" || resolved == mp || strings.HasPrefix(resolved, mp+"/")) {
+			best = mp
+		}
+	}
+	if best == "" {
+		return "", fmt.Errorf("no mount found for %s", resolved)
+	}
+	return best, nil
+}
+
+// FsidMountPoint 返回 fsid 对应文件系统的挂载点；同一文件系统有多个挂载(如 bind mount)时取 mountinfo 中的第一个。
+// 需要对每个挂载点 statfs，不适合在事件循环中调用
+func FsidMountPoint(fsid [8]byte) (string, error) {
+	mounts, err := mountPoints()
+	if err != nil {
+		return "", err
+	}
+	for _, mp := range mounts {
+		if id, err := Fsid(mp); err == nil && id == fsid {
+			return mp, nil
+		}
+	}
+	return "", fmt.Errorf("no mount found for fsid %x", fsid)
+}
+
+// Fsid 返回 path 所在文件系统的 fsid，字节布局与 fanotify 事件信息头中的 __kernel_fsid_t 一致
+func Fsid(path string) ([8]byte, error) {
+	var st unix.Statfs_t
+	var fsid [8]byte
+	if err := unix.Statfs(path, &st); err != nil {
+		return fsid, err
+	}
+	binary.NativeEndian.PutUint32(fsid[0:4], uint32(st.Fsid.Val[0]))
+	binary.NativeEndian.PutUint32(fsid[4:8], uint32(st.Fsid.Val[1]))
+	return fsid, nil
+}
+
+// mountPoints 按 /proc/self/mountinfo 中的顺序返回所有挂载点
+func mountPoints() ([]string, error) {
+	f, err := os.Open("/proc/self/mountinfo")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+	var mounts []string
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		// 36 35 98:0 /mnt1 /mnt/parent rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		mounts = append(mounts, unescapeMount(fields[4]))
+	}
+	return mounts, sc.Err()
+}
+
+// unescapeMount 还原 mountinfo 中以八进制转义的空格、制表符、换行与反斜杠(如 \040)
+func unescapeMount(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
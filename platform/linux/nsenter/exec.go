@@ -0,0 +1,64 @@
+package nsenter
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	envNsFd   = "_WATCHMAN_MNTNS_FD"
+	envConfFd = "_WATCHMAN_CONF_FD"
+)
+
+// Exec 打开命名空间文件 nsPath(如 /proc/<pid>/ns/mnt)和配置文件后重新执行自身，成功时不返回。
+// 目标命名空间中看不到宿主机的配置文件，因此以 fd 传递，见 ConfigFile。
+func Exec(nsPath, configPath string) error {
+	if Entered() {
+		return errors.New("already in target mount namespace")
+	}
+	if !cgoEnabled {
+		return errors.New("mount namespace support requires a cgo build")
+	}
+	ns, err := unix.Open(nsPath, unix.O_RDONLY, 0)
+	if err != nil {
+		return &os.PathError{Op: "open", Path: nsPath, Err: err}
+	}
+	conf, err := unix.Open(configPath, unix.O_RDWR, 0)
+	if err != nil {
+		_ = unix.Close(ns)
+		return &os.PathError{Op: "open", Path: configPath, Err: err}
+	}
+	env := append(os.Environ(), envNsFd+"="+strconv.Itoa(ns), envConfFd+"="+strconv.Itoa(conf))
+	err = unix.Exec("/proc/self/exe", os.Args, env)
+	_ = unix.Close(ns)
+	_ = unix.Close(conf)
+	return err
+}
+
+// ConfigFile 返回父进程传入的配置文件，未通过 Exec 启动时为 nil
+func ConfigFile() *os.File {
+	if !Entered() {
+		return nil
+	}
+	fd, err := strconv.Atoi(os.Getenv(envConfFd))
+	if err != nil {
+		return nil
+	}
+	return configFile(fd)
+}
+
+var (
+	confOnce sync.Once
+	confFile *os.File
+)
+
+func configFile(fd int) *os.File {
+	confOnce.Do(func() {
+		confFile = os.NewFile(uintptr(fd), "config")
+	})
+	return confFile
+}
@@ -0,0 +1,54 @@
+//go:build linux && cgo
+
+// Package nsenter 在 Go 运行时启动前加入指定的挂载命名空间。
+//
+// setns(CLONE_NEWNS) 要求调用进程为单线程，而 Go 运行时启动后已有多个线程，因此由 C 构造函数在 main 之前完成：
+// 父进程打开命名空间文件(不带 CLOEXEC)后以环境变量传递 fd 并重新执行自身，子进程在构造函数中 setns。
+// 需要 CAP_SYS_ADMIN 与 CAP_SYS_CHROOT。
+package nsenter
+
+/*
+#define _GNU_SOURCE
+#include <fcntl.h>
+#include <sched.h>
+#include <stdio.h>
+#include <stdlib.h>
+#include <unistd.h>
+
+int watchman_ns_entered = 0;
+int watchman_proc_fd = -1;
+
+__attribute__((constructor)) static void watchman_nsenter(void) {
+	const char *ns = getenv("_WATCHMAN_MNTNS_FD");
+	if (ns == NULL || *ns == '\0') {
+		return;
+	}
+	int fd = atoi(ns);
+	// 目标命名空间中的 /proc 属于其他 pid 命名空间，/proc/self 不可用；先保留宿主机的 /proc
+	int proc = open("/proc", O_PATH | O_DIRECTORY | O_CLOEXEC);
+	if (proc < 0) {
+		perror("watchman: open /proc");
+		_exit(1);
+	}
+	if (setns(fd, CLONE_NEWNS) != 0) {
+		perror("watchman: setns");
+		_exit(1);
+	}
+	close(fd);
+	watchman_proc_fd = proc;
+	watchman_ns_entered = 1;
+}
+*/
+import "C"
+
+const cgoEnabled = true
+
+// Entered 报告当前进程是否已由构造函数加入目标挂载命名空间
+func Entered() bool {
+	return C.watchman_ns_entered != 0
+}
+
+// ProcFd 返回加入命名空间前打开的宿主机 /proc 目录 fd，未加入时为 -1
+func ProcFd() int {
+	return int(C.watchman_proc_fd)
+}
@@ -0,0 +1,13 @@
+//go:build !linux || !cgo
+
+package nsenter
+
+const cgoEnabled = false
+
+func Entered() bool {
+	return false
+}
+
+func ProcFd() int {
+	return -1
+}